@@ -4,7 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/MeneDev/dockmoor/dockfmt"
+	_ "github.com/MeneDev/dockmoor/dockfmt/circleci"
+	_ "github.com/MeneDev/dockmoor/dockfmt/compose"
 	_ "github.com/MeneDev/dockmoor/dockfmt/dockerfile"
+	_ "github.com/MeneDev/dockmoor/dockfmt/githubactions"
+	_ "github.com/MeneDev/dockmoor/dockfmt/gitlabci"
+	_ "github.com/MeneDev/dockmoor/dockfmt/helm"
+	_ "github.com/MeneDev/dockmoor/dockfmt/kubernetes"
+	_ "github.com/MeneDev/dockmoor/dockfmt/kustomize"
+	_ "github.com/MeneDev/dockmoor/dockfmt/travisci"
 	"github.com/jessevdk/go-flags"
 	"github.com/sirupsen/logrus"
 	"io"
@@ -23,6 +31,11 @@ type mainOptions struct {
 	LogLevel    string `required:"no" short:"l" long:"log-level" description:"Sets the log-level" choice:"NONE" choice:"ERROR" choice:"WARN" choice:"INFO" choice:"DEBUG" default:"WARN"`
 	ShowVersion bool   `required:"no" long:"version" description:"Show version and exit"`
 
+	ConfigOptions struct {
+		Config  flags.Filename `required:"no" long:"config" description:"Read the configuration from this file instead of the .dockmoor.yml found in the working directory or its parents"`
+		Profile string         `required:"no" long:"profile" env:"DOCKMOOR_PROFILE" description:"Apply the named profile of the configuration"`
+	} `group:"Configuration Options" description:"Options given on the command line replace the configuration"`
+
 	Help struct {
 		Help          bool `short:"h" long:"help" description:"Show help and exit"`
 		Manpage       bool `required:"no" long:"manpage" description:"Show man page and exit"`
@@ -36,6 +49,7 @@ type mainOptions struct {
 	formatProvider dockfmt.FormatProvider
 	stdout         io.Writer
 	stdin          io.ReadCloser
+	config         *projectConfig
 }
 
 var osStdout io.Writer = os.Stdout
@@ -119,6 +133,18 @@ func main() {
 		log.Errorf("Could not add list command: %s", err)
 	}
 
+	if _, err := addPinCommand(mainOptions, AddCommand); err != nil {
+		log.Errorf("Could not add pin command: %s", err)
+	}
+
+	if _, err := addUpdateCommand(mainOptions, AddCommand); err != nil {
+		log.Errorf("Could not add update command: %s", err)
+	}
+
+	if _, err := addCheckCommand(mainOptions, AddCommand); err != nil {
+		log.Errorf("Could not add check command: %s", err)
+	}
+
 	exitCode := doMain(mainOptions)
 	osExit(exitCode)
 }
@@ -187,6 +213,19 @@ func CommandFromArgs(mainOptions *mainOptions, args []string) (theCommand flags.
 		return
 	}
 
+	configOptions := mainOptions.ConfigOptions
+	config, configErr := loadConfig(string(configOptions.Config), configOptions.Profile)
+	if config != nil {
+		if isMissingInputFile(optsErr) && len(config.Include) > 0 {
+			argsWithIncludes := append(append([]string{}, args...), config.includeArguments()...)
+			cmdArgs, optsErr = parser.ParseArgs(argsWithIncludes)
+		}
+		if optsErr == nil {
+			configErr = config.apply(mainOptions)
+		}
+	}
+	mainOptions.config = config
+
 	level := logrus.WarnLevel
 	log.SetLevel(level)
 	if mainOptions.LogLevel == "NONE" {
@@ -202,6 +241,13 @@ func CommandFromArgs(mainOptions *mainOptions, args []string) (theCommand flags.
 		}
 	}
 
+	if configErr != nil {
+		log.Errorf("Error in configuration: %s", configErr)
+		theCommand = nil
+		exitCode = ExitInvalidParams
+		return
+	}
+
 	if optsErr != nil {
 		log.Errorf("Error in parameters: %s", optsErr)
 		exitCode = ExitInvalidParams
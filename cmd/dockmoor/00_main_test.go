@@ -177,4 +177,6 @@ func TestMainReportsAddingListCommandErrors(t *testing.T)  {
 	assert.Contains(t, stdoutBuf.String(), "level=error")
 	assert.Contains(t, stdoutBuf.String(), "Could not add list command")
 	assert.Contains(t, stdoutBuf.String(), "Could not add contains command")
+	assert.Contains(t, stdoutBuf.String(), "Could not add pin command")
+	assert.Contains(t, stdoutBuf.String(), "Could not add update command")
 }
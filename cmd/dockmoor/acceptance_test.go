@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"github.com/MeneDev/dockmoor/dockres"
 	"github.com/jessevdk/go-flags"
 	"github.com/mattn/go-shellwords"
 	"github.com/stretchr/testify/assert"
@@ -285,3 +287,770 @@ func shell(t *testing.T, argsLine string, values interface{}) (stdout string, ex
 	assert.True(t, exitCodeSet, "Expected exitCode to be set (no call to osExit)")
 	return
 }
+
+func TestListOutdatedAndDockerfile(t *testing.T) {
+	org := resolverFactories["dockerd"]
+	defer func() {
+		resolverFactories["dockerd"] = org
+	}()
+	resolverFactories["dockerd"] = func(*MatchingOptions) (dockres.Resolver, error) {
+		return resolverFake{tags: map[string][]string{
+			"docker.io/menedev/testimagea": {"1.0.0", "1.0.1", "1.1.0", "1.1.1", "2.0.0", "latest"},
+		}}, nil
+	}
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "Dockerfile")
+	dockerfile :=
+		`FROM menedev/testimagea:1.0.0
+FROM menedev/testimagea:1.1
+FROM menedev/testimagea:2.0.0
+FROM menedev/testimagea:latest`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(dockerfile), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list --outdated {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "menedev/testimagea:1.0.0\nmenedev/testimagea:1.1\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListKubernetesManifestWithCustomImagePath(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "manifests.yaml")
+	manifests :=
+		`apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+      - image: nginx:1.15
+---
+apiVersion: tekton.dev/v1alpha1
+kind: Task
+spec:
+  steps:
+  - image: golang:1.11
+`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(manifests), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.File}}`, struct {
+		File string
+	}{tmpfn})
+
+	assert.Equal(t, "nginx:1.15\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --k8s-image-path '{.spec.steps[*].image}' {{.File}}`, struct {
+		File string
+	}{tmpfn})
+
+	assert.Equal(t, "nginx:1.15\ngolang:1.11\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --k8s-image-path '{.spec..image}' {{.File}}`, struct {
+		File string
+	}{tmpfn})
+
+	assert.Contains(t, stdout, "Recursive descent is not supported")
+	assert.Equal(t, ExitInvalidParams, code)
+}
+
+func TestListGitlabCI(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, ".gitlab-ci.yml")
+	pipeline :=
+		`image: ruby:2.5
+services:
+  - postgres:10
+build:
+  image:
+    name: golang:1.11
+  script: go build
+`
+
+	if err := ioutil.WriteFile(tmpfn, []byte(pipeline), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Dir}}`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, "ruby:2.5\npostgres:10\ngolang:1.11\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListUnpinnedActionsOfGithubWorkflows(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	workflows := filepath.Join(dir, ".github", "workflows")
+	os.MkdirAll(workflows, 0777)
+	workflow :=
+		`on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container: golang:1.11
+    steps:
+      - uses: actions/checkout@a81bbbf8298c0fa03ea29cdc473d45769f953675
+      - uses: actions/setup-go@v1
+      - uses: docker://alpine:3.8
+`
+
+	if err := ioutil.WriteFile(filepath.Join(workflows, "ci.yml"), []byte(workflow), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list --unpinned {{.Dir}}`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, "golang:1.11\nalpine:3.8\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --unpinned --match-actions {{.Dir}}`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, "golang:1.11\nactions/setup-go@v1\nalpine:3.8\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListCircleCIAndTravisCI(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".circleci/config.yml": "version: 2.1\njobs:\n  build:\n    docker:\n      - image: cimg/go:1.15\n",
+		".travis.yml":          "language: go\nenv: IMAGE=postgres:10\nscript: docker pull $IMAGE\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0777)
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Dir}}`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, "cimg/go:1.15\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --travis-docker-pull {{.Dir}}`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, "cimg/go:1.15\npostgres:10\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListHelmValues(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	content := "image:\n  registry: quay.io\n  repository: prometheus/node-exporter\n  tag: v1.0.1\n" +
+		"proxy:\n  repository: envoyproxy/envoy\n  version: v1.16.0\n"
+	path := filepath.Join(dir, "values.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.File}}`, struct {
+		File string
+	}{path})
+
+	assert.Equal(t, "quay.io/prometheus/node-exporter:v1.0.1\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --helm-key tag=version {{.File}}`, struct {
+		File string
+	}{path})
+
+	assert.Equal(t, "quay.io/prometheus/node-exporter:v1.0.1\nenvoyproxy/envoy:v1.16.0\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --helm-key label=version {{.File}}`, struct {
+		File string
+	}{path})
+
+	assert.Contains(t, stdout, "the field must be one of")
+	assert.Equal(t, ExitInvalidParams, code)
+}
+
+func TestListKustomizeImages(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"base/pod.yaml":              "apiVersion: v1\nkind: Pod\nspec:\n  containers:\n    - image: nginx:1.19\n    - image: redis:5\n",
+		"overlay/kustomization.yaml": "resources:\n  - ../base/pod.yaml\nimages:\n  - name: nginx\n    newTag: 1.19.2\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0777)
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Dir}}/overlay`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, "nginx:1.19.2\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list --kustomize-resources {{.Dir}}/overlay`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, "nginx:1.19.2\nredis:5\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListLatestIgnoresStageReferences(t *testing.T) {
+	tmpfn := dockerfile("FROM golang:1.11 AS build\nFROM build\nFROM alpine\n")
+	defer os.Remove(tmpfn)
+
+	stdout, code := shell(t, `dockmoor list --latest {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "alpine\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListWithPosition(t *testing.T) {
+	tmpfn := dockerfile("FROM golang:1.11 AS build\nCOPY --from=alpine:3.8 /etc/ssl /etc/ssl\nFROM  nginx\n")
+	defer os.Remove(tmpfn)
+
+	stdout, code := shell(t, `dockmoor list --with-position {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, tmpfn+":1:6: golang:1.11\n"+tmpfn+":2:13: alpine:3.8\n"+tmpfn+":3:7: nginx\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestWithPositionIsOnlyOfferedByList(t *testing.T) {
+	stdout, _ := shell(t, `dockmoor list --help`, struct{}{})
+	assert.Contains(t, stdout, "--with-position")
+
+	for _, command := range []string{"contains", "pin", "update"} {
+		stdout, _ := shell(t, `dockmoor `+command+` --help`, struct{}{})
+		assert.NotContains(t, stdout, "--with-position", command)
+	}
+}
+
+func TestListMultipleFilesAndDirectories(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"web/Dockerfile":      "FROM nginx:1.15\n",
+		"web/README.md":       "# The web server\n",
+		"docker-compose.yml":  "services:\n  db:\n    image: postgres:10\n",
+		"tools/Dockerfile":    "FROM golang:1.11\n",
+		".git/Dockerfile.old": "FROM alpine\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0777)
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Dir}}`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, "postgres:10\ngolang:1.11\nnginx:1.15\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor list {{.Dockerfile}} '{{.Dir}}/*/Dockerfile'`, struct {
+		Dockerfile string
+		Dir        string
+	}{filepath.Join(dir, "web", "Dockerfile"), dir})
+
+	assert.Equal(t, "nginx:1.15\ngolang:1.11\nnginx:1.15\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	stdout, code = shell(t, `dockmoor contains --untagged {{.Dir}}`, struct {
+		Dir string
+	}{dir})
+
+	assert.Equal(t, ExitNotFound, code)
+}
+
+func TestListReportsInvalidFilesButProcessesTheRest(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	readme := filepath.Join(dir, "README.md")
+	ioutil.WriteFile(readme, []byte("# Readme\n"), 0666)
+	df := filepath.Join(dir, "Dockerfile")
+	ioutil.WriteFile(df, []byte("FROM nginx\n"), 0666)
+
+	stdout, code := shell(t, `dockmoor list {{.Readme}} {{.Dockerfile}}`, struct {
+		Readme     string
+		Dockerfile string
+	}{readme, df})
+
+	assert.Contains(t, stdout, "level=error")
+	assert.Contains(t, stdout, "Invalid format of "+readme)
+	assert.Contains(t, stdout, "nginx\n")
+	assert.Equal(t, ExitInvalidFormat, code)
+
+	stdout, code = shell(t, `dockmoor list {{.Dir}}/missing {{.Dockerfile}}`, struct {
+		Dir        string
+		Dockerfile string
+	}{dir, df})
+
+	assert.Contains(t, stdout, "Could not open file")
+	assert.Contains(t, stdout, "nginx\n")
+	assert.Equal(t, ExitCouldNotOpenFile, code)
+}
+
+func TestPinAcceptsOnlyOneInputFile(t *testing.T) {
+	df := dockerfile("FROM nginx\n")
+	defer os.Remove(df)
+
+	stdout, code := shell(t, `dockmoor pin {{.Dockerfile}} {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{df})
+
+	assert.Contains(t, stdout, ErrSingleInputFile.Error())
+	assert.Equal(t, ExitInvalidParams, code)
+}
+
+func TestListOutputJson(t *testing.T) {
+	tmpfn := dockerfile("FROM nginx:1.15\nFROM alpine\n")
+	defer os.Remove(tmpfn)
+
+	stdout, code := shell(t, `dockmoor list --output json {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	var entries []map[string]interface{}
+	err := json.Unmarshal([]byte(stdout), &entries)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, map[string]interface{}{
+		"file":     tmpfn,
+		"format":   "Dockerfile",
+		"original": "nginx:1.15",
+		"name":     "docker.io/library/nginx",
+		"domain":   "docker.io",
+		"path":     "library/nginx",
+		"tag":      "1.15",
+		"digest":   "",
+		"position": map[string]interface{}{"line": 1.0, "column": 6.0, "endLine": 1.0, "endColumn": 16.0},
+	}, entries[0])
+	assert.Equal(t, "alpine", entries[1]["original"])
+
+	stdout, code = shell(t, `dockmoor list --output json --untagged {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+	assert.Equal(t, "[\n  {\n    \"file\": \""+tmpfn+"\",", strings.SplitN(stdout, "\n    \"format\"", 2)[0])
+
+	tagged := dockerfile("FROM nginx:1.15\n")
+	defer os.Remove(tagged)
+
+	stdout, code = shell(t, `dockmoor list --output json --untagged {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tagged})
+
+	assert.Equal(t, ExitNotFound, code)
+	assert.Equal(t, "[]\n", stdout)
+}
+
+func TestListOutputNdjson(t *testing.T) {
+	tmpfn := dockerfile("FROM nginx:1.15\nFROM alpine\n")
+	defer os.Remove(tmpfn)
+
+	stdout, code := shell(t, `dockmoor list --output ndjson {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	assert.Len(t, lines, 2)
+	for i, original := range []string{"nginx:1.15", "alpine"} {
+		var entry map[string]interface{}
+		err := json.Unmarshal([]byte(lines[i]), &entry)
+		assert.Nil(t, err)
+		assert.Equal(t, original, entry["original"])
+		assert.Equal(t, tmpfn, entry["file"])
+	}
+}
+
+func sarifResults(t *testing.T, stdout string) []interface{} {
+	var log map[string]interface{}
+	err := json.Unmarshal([]byte(stdout), &log)
+	assert.Nil(t, err)
+	if err != nil {
+		return nil
+	}
+
+	run := log["runs"].([]interface{})[0].(map[string]interface{})
+	return run["results"].([]interface{})
+}
+
+func TestListOutputSarif(t *testing.T) {
+	tmpfn := dockerfile("FROM nginx:1.15\nFROM alpine\n")
+	defer os.Remove(tmpfn)
+
+	stdout, code := shell(t, `dockmoor list --latest --output sarif {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+
+	results := sarifResults(t, stdout)
+	assert.Len(t, results, 1)
+	result := results[0].(map[string]interface{})
+	assert.Equal(t, "latest", result["ruleId"])
+
+	location := result["locations"].([]interface{})[0].(map[string]interface{})["physicalLocation"].(map[string]interface{})
+	assert.Equal(t, filepath.ToSlash(tmpfn), location["artifactLocation"].(map[string]interface{})["uri"])
+	assert.Equal(t, map[string]interface{}{"startLine": 2.0, "startColumn": 6.0, "endLine": 2.0, "endColumn": 12.0}, location["region"])
+}
+
+func TestContainsOutputSarif(t *testing.T) {
+	tmpfn := dockerfile("FROM nginx:1.15\nFROM alpine\n")
+	defer os.Remove(tmpfn)
+
+	stdout, code := shell(t, `dockmoor contains --unpinned --output sarif {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+	assert.Len(t, sarifResults(t, stdout), 2)
+
+	stdout, code = shell(t, `dockmoor contains --unpinned {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+	assert.Equal(t, "", stdout)
+}
+
+func TestListWhereExpression(t *testing.T) {
+	tmpfn := dockerfile(`FROM nginx
+FROM nginx:1.15
+FROM internal.example.com/app
+FROM internal.example.com/tool:1.0@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf
+FROM alpine:3.8@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf
+`)
+	defer os.Remove(tmpfn)
+
+	expectations := map[string]string{
+		`unpinned`:           "nginx\nnginx:1.15\ninternal.example.com/app\n",
+		`latest or untagged`: "nginx\ninternal.example.com/app\n",
+		`(unpinned or latest) and not domain("internal.example.com")`: "nginx\nnginx:1.15\n",
+		`not unpinned and tag("3.8", "1.0")`:                          "internal.example.com/tool:1.0@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf\nalpine:3.8@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf\n",
+	}
+
+	for expression, expected := range expectations {
+		t.Run(expression, func(t *testing.T) {
+			// the expression is not passed as value, html/template would escape the quotes
+			stdout, code := shell(t, `dockmoor list --where '`+expression+`' {{.Dockerfile}}`, struct {
+				Dockerfile string
+			}{tmpfn})
+
+			assert.Equal(t, expected, stdout)
+			assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+		})
+	}
+
+	stdout, code := shell(t, `dockmoor list --untagged --where 'domain("internal.example.com")' {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "internal.example.com/app\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestInvalidWhereExpressionReportsColumn(t *testing.T) {
+	tmpfn := dockerfile("FROM nginx\n")
+	defer os.Remove(tmpfn)
+
+	stdout, code := shell(t, `dockmoor contains --where 'unpinned or lates' {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Contains(t, stdout, "Unknown predicate 'lates' at column 13")
+	assert.Equal(t, ExitInvalidParams, code)
+
+	stdout, code = shell(t, `dockmoor contains --where 'latest("x")' {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Contains(t, stdout, "latest takes no arguments at column 1")
+	assert.Equal(t, ExitInvalidParams, code)
+}
+
+func TestListPatterns(t *testing.T) {
+	tmpfn := dockerfile(`FROM myorg/app:1.15-alpine
+FROM myorg/team/app:1.15.3-alpine
+FROM registry.corp.example.com/tool:2.0
+FROM nginx:1.15
+`)
+	defer os.Remove(tmpfn)
+
+	expectations := map[string]string{
+		`--name 'myorg/*'`:                     "myorg/app:1.15-alpine\n",
+		`--name 'myorg/**'`:                    "myorg/app:1.15-alpine\nmyorg/team/app:1.15.3-alpine\n",
+		`--domain '*.corp.example.com'`:        "registry.corp.example.com/tool:2.0\n",
+		`--tag-regex '^\d+\.\d+-alpine$'`:      "myorg/app:1.15-alpine\n",
+		`--name-regex '^myorg/' --tag '1.15*'`: "myorg/app:1.15-alpine\nmyorg/team/app:1.15.3-alpine\n",
+		`--where 'name-regex("^nginx$")'`:      "nginx:1.15\n",
+	}
+
+	for options, expected := range expectations {
+		t.Run(options, func(t *testing.T) {
+			// the options are not passed as value, html/template would escape the quotes
+			stdout, code := shell(t, `dockmoor list `+options+` {{.Dockerfile}}`, struct {
+				Dockerfile string
+			}{tmpfn})
+
+			assert.Equal(t, expected, stdout)
+			assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+		})
+	}
+}
+
+func TestInvalidPatternsAreReported(t *testing.T) {
+	tmpfn := dockerfile("FROM nginx\n")
+	defer os.Remove(tmpfn)
+
+	stdout, code := shell(t, `dockmoor contains --tag-regex '^(\d+' {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Contains(t, stdout, "Invalid regular expression")
+	assert.Equal(t, ExitInvalidParams, code)
+
+	stdout, code = shell(t, `dockmoor contains --name 'myorg/[a-z' {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Contains(t, stdout, "Invalid glob 'myorg/[a-z': missing ]")
+	assert.Equal(t, ExitInvalidParams, code)
+}
+
+func TestListTagSemver(t *testing.T) {
+	tmpfn := dockerfile(`FROM golang:1.11.5-alpine
+FROM golang:1.12
+FROM golang:v2.1
+FROM golang:latest
+`)
+	defer os.Remove(tmpfn)
+
+	// html/template would escape <, so the ranges avoid it
+	expectations := map[string]string{
+		`--tag-semver '1.0 - 1.11 || >=2.0.0-0'`:                       "golang:1.11.5-alpine\ngolang:v2.1\n",
+		`--tag-semver '1.0 - 1.11 || >=2.0.0-0' --non-semver match`:    "golang:1.11.5-alpine\ngolang:v2.1\ngolang:latest\n",
+		`--tag-semver '1.0 - 1.11 || >=2.0.0-0' --non-semver no-match`: "golang:1.11.5-alpine\ngolang:v2.1\n",
+		`--where 'tag-semver("~1.12", "^2")'`:                          "golang:1.12\ngolang:v2.1\n",
+	}
+
+	for options, expected := range expectations {
+		t.Run(options, func(t *testing.T) {
+			// the options are not passed as value, html/template would escape the quotes
+			stdout, code := shell(t, `dockmoor list `+options+` {{.Dockerfile}}`, struct {
+				Dockerfile string
+			}{tmpfn})
+
+			assert.Equal(t, expected, stdout)
+			assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+		})
+	}
+
+	stdout, code := shell(t, `dockmoor list --tag-semver '>=1' --non-semver error {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Contains(t, stdout, tmpfn+":4:6: Tag of golang:latest is not a semantic version")
+	assert.NotContains(t, stdout, "golang:1.12\n")
+	assert.Equal(t, ExitInvalidFormat, code)
+
+	stdout, code = shell(t, `dockmoor list --tag-semver '>=1.12 || latest' {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Contains(t, stdout, "Invalid version range '>=1.12 || latest': 'latest' is not a version")
+	assert.Equal(t, ExitInvalidParams, code)
+}
+
+func TestListWithConfiguration(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".dockmoor.yml": `
+include: [ docker/ ]
+exclude: [ vendor ]
+predicates:
+  unpinned: true
+  domain: [ "*.corp.example.com" ]
+profiles:
+  eol:
+    predicates:
+      domain: [ docker.io ]
+      tag-semver: "1.0 - 1.11"
+`,
+		"docker/Dockerfile":        "FROM registry.corp.example.com/app:1.0\nFROM golang:1.11\nFROM golang:1.12\n",
+		"docker/vendor/Dockerfile": "FROM registry.corp.example.com/vendored:1.0\n",
+		"Dockerfile":               "FROM registry.corp.example.com/root:1.0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0777)
+		ioutil.WriteFile(path, []byte(content), 0666)
+	}
+
+	values := struct {
+		Config     string
+		Dockerfile string
+	}{filepath.Join(dir, ".dockmoor.yml"), filepath.Join(dir, "Dockerfile")}
+
+	stdout, code := shell(t, `dockmoor list --config {{.Config}}`, values)
+	assert.Equal(t, "registry.corp.example.com/app:1.0\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	stdout, code = shell(t, `dockmoor list --config {{.Config}} --domain docker.io`, values)
+	assert.Equal(t, "golang:1.11\ngolang:1.12\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	stdout, code = shell(t, `dockmoor list --config {{.Config}} --profile eol`, values)
+	assert.Equal(t, "golang:1.11\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	stdout, code = shell(t, `dockmoor list --config {{.Config}} {{.Dockerfile}}`, values)
+	assert.Equal(t, "registry.corp.example.com/root:1.0\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	stdout, code = shell(t, `dockmoor list --config {{.Config}} --profile unknown`, values)
+	assert.Contains(t, stdout, "Error in configuration: Unknown profile 'unknown'")
+	assert.Equal(t, ExitInvalidParams, code)
+}
+
+func TestCommandLinePredicatesReplaceConfiguredPredicatesOfTheirGroup(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, ".dockmoor.yml")
+	ioutil.WriteFile(config, []byte("predicates:\n  latest: true\n  domain: [ docker.io ]\n"), 0666)
+	tmpfn := dockerfile("FROM nginx\nFROM alpine:3.8\nFROM quay.io/coreos/etcd:3.8\n")
+	defer os.Remove(tmpfn)
+
+	values := struct {
+		Config     string
+		Dockerfile string
+	}{config, tmpfn}
+
+	stdout, code := shell(t, `dockmoor list --config {{.Config}} {{.Dockerfile}}`, values)
+	assert.Equal(t, "nginx\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	stdout, code = shell(t, `dockmoor list --config {{.Config}} --tag 3.8 {{.Dockerfile}}`, values)
+	assert.Equal(t, "alpine:3.8\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	stdout, code = shell(t, `dockmoor list --config {{.Config}} --domain quay.io --tag 3.8 {{.Dockerfile}}`, values)
+	assert.Equal(t, "quay.io/coreos/etcd:3.8\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+}
+
+func TestInvalidConfigurationOptionIsReported(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, ".dockmoor.yml")
+	ioutil.WriteFile(config, []byte("registry:\n  resolver: somewhere\n"), 0666)
+	tmpfn := dockerfile("FROM nginx\n")
+	defer os.Remove(tmpfn)
+
+	stdout, code := shell(t, `dockmoor contains --config {{.Config}} {{.Dockerfile}}`, struct {
+		Config     string
+		Dockerfile string
+	}{config, tmpfn})
+
+	assert.Contains(t, stdout, "Error in configuration: Invalid value `somewhere' for option `--resolver'")
+	assert.Equal(t, ExitInvalidParams, code)
+}
+
+func TestCheckRules(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, ".dockmoor.yml")
+	ioutil.WriteFile(config, []byte(`
+rules:
+  no-latest:
+    severity: error
+    where: latest
+    message: Use a fixed tag
+  must-be-pinned:
+    severity: warning
+    where: unpinned
+  allowed-registries:
+    where: not domain("docker.io", "*.corp.example.com")
+`), 0666)
+
+	values := struct {
+		Config     string
+		Dockerfile string
+	}{Config: config}
+
+	values.Dockerfile = dockerfile("FROM nginx:1.15\nFROM registry.corp.example.com/app:1.0\n")
+	defer os.Remove(values.Dockerfile)
+
+	stdout, code := shell(t, `dockmoor check --config {{.Config}} {{.Dockerfile}}`, values)
+	assert.Equal(t, values.Dockerfile+":1:6: warning: nginx:1.15: violates must-be-pinned (must-be-pinned)\n"+
+		values.Dockerfile+":2:6: warning: registry.corp.example.com/app:1.0: violates must-be-pinned (must-be-pinned)\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Warnings do not fail")
+
+	values.Dockerfile = dockerfile("FROM nginx\nFROM quay.io/coreos/etcd:v3.3\n")
+	defer os.Remove(values.Dockerfile)
+
+	stdout, code = shell(t, `dockmoor check --config {{.Config}} --rule no-latest --rule allowed-registries {{.Dockerfile}}`, values)
+	assert.Equal(t, values.Dockerfile+":1:6: error: nginx: Use a fixed tag (no-latest)\n"+
+		values.Dockerfile+":2:6: error: quay.io/coreos/etcd:v3.3: violates allowed-registries (allowed-registries)\n", stdout)
+	assert.Equal(t, ExitPolicyViolation, code)
+
+	stdout, code = shell(t, `dockmoor check --config {{.Config}} --output sarif {{.Dockerfile}}`, values)
+	results := sarifResults(t, stdout)
+	assert.Len(t, results, 4)
+	assert.Equal(t, ExitPolicyViolation, code)
+
+	stdout, code = shell(t, `dockmoor check --config {{.Config}} --rule unknown {{.Dockerfile}}`, values)
+	assert.Contains(t, stdout, "Unknown rule 'unknown'")
+	assert.Equal(t, ExitInvalidParams, code)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jessevdk/go-flags"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+var ErrNoRules = errors.Errorf("No rules configured, add rules to %s", configFileName)
+
+// CheckOptions control which rules are evaluated and how violations are printed
+type CheckOptions struct {
+	Rules  []string `required:"no" long:"rule" description:"Evaluate only the named rules of the configuration. Can be given multiple times"`
+	Output string   `required:"no" long:"output" description:"Print violations as text or as SARIF 2.1.0 report" choice:"text" choice:"sarif" default:"text"`
+}
+
+type checkOptions struct {
+	MatchingOptions
+
+	CheckOptions CheckOptions `group:"Check Options" description:"Control which rules are evaluated and how violations are printed"`
+
+	rules      []checkRule
+	errorCount int
+}
+
+// checkRule is a rule of the configuration. Image references matching the predicate violate the rule
+type checkRule struct {
+	name      string
+	severity  string
+	message   string
+	predicate dockproc.Predicate
+}
+
+var severities = []string{"error", "warning", "note"}
+
+func addCheckCommand(mainOptions *mainOptions, adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+	var checkOptions checkOptions
+	checkOptions.mainOpts = mainOptions
+	checkOptions.mode = matchOnly
+	checkOptions.output = &checkOptions.CheckOptions.Output
+
+	return adder(mainOptions, "check",
+		"Check image references against the rules of the configuration.",
+		"Check image references against the rules of the configuration. Each image reference matching the where expression of a rule is reported as violation with the severity of the rule. Predicates limit the image references that are checked. Returns exit code 0 when no rule with severity error is violated and all files are of valid format, non-null otherwise",
+		&checkOptions)
+}
+
+func (co *checkOptions) ExecuteWithExitCode(args []string) (ExitCode, error) {
+	log := co.Log()
+
+	errVerify := verifyMatchOptions(&co.MatchingOptions)
+	if errVerify != nil {
+		log.Errorf("Invalid options: %s\n", errVerify.Error())
+
+		parser := flags.NewParser(&struct{}{}, flags.HelpFlag)
+		command, _ := addCheckCommand(co.mainOpts, AddCommand)
+		if command != nil {
+			parser.ParseArgs([]string{command.Name, "--help"})
+		}
+
+		parser.WriteHelp(co.mainOpts.stdout)
+		return ExitInvalidParams, errVerify
+	}
+
+	rules, err := co.createRules()
+	if err != nil {
+		log.Errorf("Invalid rules: %s", err.Error())
+		return ExitInvalidParams, err
+	}
+	co.rules = rules
+
+	if co.outputFormat() == "sarif" {
+		co.report = sarifReportNew()
+	}
+
+	exitCode, err := co.processInputFiles(co.checkFormatProcessor)
+
+	if co.report != nil {
+		errOutput := co.report.write(co.Stdout())
+		if errOutput != nil {
+			log.Errorf("Could not write output: %s", errOutput.Error())
+			err = multierror.Append(err, errOutput).ErrorOrNil()
+		}
+	}
+
+	switch {
+	case exitCode != ExitSuccess && exitCode != ExitNotFound:
+		return exitCode, err
+	case co.errorCount > 0:
+		return ExitPolicyViolation, err
+	default:
+		return ExitSuccess, err
+	}
+}
+
+// createRules creates the rules of the configuration, sorted by name. With --rule only the named rules
+func (co *checkOptions) createRules() ([]checkRule, error) {
+	var configured map[string]configRule
+	if config := co.mainOptions().config; config != nil {
+		configured = config.Rules
+	}
+	if len(configured) == 0 {
+		return nil, ErrNoRules
+	}
+
+	names := co.CheckOptions.Rules
+	if len(names) == 0 {
+		for name := range configured {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var result *multierror.Error
+	rules := make([]checkRule, 0, len(names))
+	for _, name := range names {
+		rule, ok := configured[name]
+		if !ok {
+			result = multierror.Append(result, errors.Errorf("Unknown rule '%s'", name))
+			continue
+		}
+
+		checkRule, err := co.createRule(name, rule)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		rules = append(rules, checkRule)
+	}
+
+	return rules, result.ErrorOrNil()
+}
+
+func isSeverity(severity string) bool {
+	for _, s := range severities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
+
+func (co *checkOptions) createRule(name string, rule configRule) (checkRule, error) {
+	severity := rule.Severity
+	if severity == "" {
+		severity = "error"
+	}
+	if !isSeverity(severity) {
+		return checkRule{}, errors.Errorf("Rule '%s' has invalid severity '%s', use one of %s", name, severity, strings.Join(severities, ", "))
+	}
+
+	if rule.Where == "" {
+		return checkRule{}, errors.Errorf("Rule '%s' has no where expression", name)
+	}
+
+	predicate, err := dockproc.ParseExpression(rule.Where, co.expressionFactories())
+	if err != nil {
+		return checkRule{}, errors.Errorf("Rule '%s': %s", name, err.Error())
+	}
+
+	return checkRule{name: name, severity: severity, message: rule.Message, predicate: predicate}, nil
+}
+
+// checkFormatProcessor reports the violations of the image references in one file. The exit code is
+// ExitSuccess when there are violations, ExitNotFound otherwise
+func (co *checkOptions) checkFormatProcessor(formatProcessor dockfmt.FormatProcessor) (ExitCode, error) {
+	log := co.Log()
+
+	accumulator, err := dockproc.MatchesAccumulatorNew(co.getPredicate(), log, co.Stdout())
+	if err != nil {
+		return ExitUnknownError, err
+	}
+
+	err = accumulator.Accumulate(formatProcessor)
+	if err != nil {
+		log.Errorf("Error during accumulation: %s", err.Error())
+	}
+
+	if err := co.takeNonSemverErrors(); err != nil {
+		log.Errorf("Found tags that are no semantic version: %s", err.Error())
+		return ExitInvalidFormat, err
+	}
+
+	var results *multierror.Error
+	violations := 0
+	for _, r := range accumulator.Matches() {
+		for _, rule := range co.rules {
+			if !rule.predicate.Matches(r) {
+				continue
+			}
+
+			violations++
+			if rule.severity == "error" {
+				co.errorCount++
+			}
+
+			if co.report != nil {
+				co.report.addResult(rule.sarifRule(), rule.severity, r)
+				continue
+			}
+			results = multierror.Append(results, rule.print(co.Stdout(), r))
+		}
+	}
+
+	if violations == 0 {
+		return ExitNotFound, results.ErrorOrNil()
+	}
+	return ExitSuccess, results.ErrorOrNil()
+}
+
+// print prints the violation like file:line:column: error: nginx:latest: message (rule)
+func (rule checkRule) print(w io.Writer, r dockref.Reference) error {
+	prefix := ""
+	if location, ok := dockfmt.LocationOf(r); ok {
+		prefix = location.String() + ": "
+	}
+
+	message := rule.message
+	if message == "" {
+		message = "violates " + rule.name
+	}
+
+	_, err := fmt.Fprintf(w, "%s%s: %s: %s (%s)\n", prefix, rule.severity, r.Original(), message, rule.name)
+	return err
+}
+
+func (rule checkRule) sarifRule() sarifRule {
+	description := rule.message
+	if description == "" {
+		description = "Image reference violating " + rule.name
+	}
+
+	// the message is a format for the image reference
+	message := "Image reference %s violates " + rule.name
+	if rule.message != "" {
+		message += ": " + strings.Replace(rule.message, "%", "%%", -1)
+	}
+
+	return sarifRule{id: rule.name, name: rule.name, description: description, message: message}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func checkOptionsTestNew(rules map[string]configRule) *checkOptions {
+	mainOptions := mainOptionsTestNew()
+	mainOptions.config = &projectConfig{}
+	mainOptions.config.Rules = rules
+
+	co := &checkOptions{}
+	co.mainOpts = mainOptions.mainOptions
+	co.mode = matchOnly
+	return co
+}
+
+func TestCheckCreatesRulesSortedByName(t *testing.T) {
+	co := checkOptionsTestNew(map[string]configRule{
+		"no-latest":      {Where: "latest", Message: "Use a fixed tag"},
+		"must-be-pinned": {Severity: "warning", Where: "unpinned"},
+	})
+
+	rules, err := co.createRules()
+	assert.Nil(t, err)
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "must-be-pinned", rules[0].name)
+	assert.Equal(t, "warning", rules[0].severity)
+	assert.Equal(t, "no-latest", rules[1].name)
+	assert.Equal(t, "error", rules[1].severity)
+	assert.Equal(t, "Use a fixed tag", rules[1].message)
+
+	co.CheckOptions.Rules = []string{"no-latest"}
+	rules, err = co.createRules()
+	assert.Nil(t, err)
+	assert.Len(t, rules, 1)
+	assert.Equal(t, "no-latest", rules[0].name)
+}
+
+func TestCheckReportsInvalidRules(t *testing.T) {
+	co := checkOptionsTestNew(map[string]configRule{
+		"severe":     {Severity: "fatal", Where: "latest"},
+		"empty":      {},
+		"misspelled": {Where: "lates"},
+	})
+	co.CheckOptions.Rules = []string{"severe", "empty", "misspelled", "unknown"}
+
+	_, err := co.createRules()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Rule 'severe' has invalid severity 'fatal', use one of error, warning, note")
+	assert.Contains(t, err.Error(), "Rule 'empty' has no where expression")
+	assert.Contains(t, err.Error(), "Rule 'misspelled': Unknown predicate 'lates' at column 1 of 'lates'")
+	assert.Contains(t, err.Error(), "Unknown rule 'unknown'")
+}
+
+func TestCheckWithoutRules(t *testing.T) {
+	co := checkOptionsTestNew(nil)
+	_, err := co.createRules()
+	assert.Equal(t, ErrNoRules, err)
+
+	co.mainOpts.config = nil
+	_, err = co.createRules()
+	assert.Equal(t, ErrNoRules, err)
+}
+
+func TestCheckRulePrintsViolation(t *testing.T) {
+	rule := checkRule{name: "no-latest", severity: "error", message: "Use a fixed tag"}
+	ref, _ := dockref.FromOriginal("nginx:latest")
+
+	buffer := bytes.NewBuffer(nil)
+	assert.Nil(t, rule.print(buffer, ref))
+	assert.Equal(t, "error: nginx:latest: Use a fixed tag (no-latest)\n", buffer.String())
+
+	rule.message = "100% wrong"
+	assert.Equal(t, "Image reference %s violates no-latest: 100%% wrong", rule.sarifRule().message)
+}
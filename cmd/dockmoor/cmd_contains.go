@@ -4,10 +4,22 @@ import (
 	"github.com/jessevdk/go-flags"
 )
 
+// ContainsOptions control what the contains command prints
+type ContainsOptions struct {
+	Output string `required:"no" long:"output" description:"Print nothing or a SARIF 2.1.0 report of the matching image references" choice:"none" choice:"sarif" default:"none"`
+}
+
+type containsOptions struct {
+	MatchingOptions
+
+	ContainsOptions ContainsOptions `group:"Contains Options" description:"Control what is printed"`
+}
+
 func addContainsCommand(mainOptions *mainOptions, adder func (opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
-	var containsOptions MatchingOptions
+	var containsOptions containsOptions
 	containsOptions.mainOpts = mainOptions
 	containsOptions.mode = matchOnly
+	containsOptions.output = &containsOptions.ContainsOptions.Output
 
 	return adder(mainOptions, "contains",
 		"Test if a file contains image references with matching predicates.",
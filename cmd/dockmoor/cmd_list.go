@@ -1,16 +1,138 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/hashicorp/go-multierror"
 	"github.com/jessevdk/go-flags"
+	"io"
 )
 
+// ListOptions control how the list command prints matching image references
+type ListOptions struct {
+	WithPosition bool   `required:"no" long:"with-position" description:"Prefix each image reference with the position it was found at, like file:line:column: image"`
+	Output       string `required:"no" long:"output" description:"Print image references as text, as JSON array, as newline delimited JSON with one object per line or as SARIF 2.1.0 report" choice:"text" choice:"json" choice:"ndjson" choice:"sarif" default:"text"`
+
+	// entries are collected for --output json, which is printed once all files are processed
+	entries []listEntry
+}
+
+type listOptions struct {
+	MatchingOptions
+
+	ListOptions ListOptions `group:"List Options" description:"Control how matching image references are printed"`
+}
+
 func addListCommand(mainOptions *mainOptions, adder func (opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
-	var containsOptions MatchingOptions
-	containsOptions.mainOpts = mainOptions
-	containsOptions.mode = matchAndPrint
+	var listOptions listOptions
+	listOptions.mainOpts = mainOptions
+	listOptions.mode = matchAndPrint
+	listOptions.listOpts = &listOptions.ListOptions
+	listOptions.output = &listOptions.ListOptions.Output
 
 	return adder(mainOptions, "list",
 		"List image references with matching predicates.",
 		"List image references with matching predicates. Returns exit code 0 when the given input contains at least one image reference that satisfy the given conditions and is of valid format, non-null otherwise",
-		&containsOptions)
+		&listOptions)
+}
+
+// listEntry is the JSON representation of a matching image reference
+type listEntry struct {
+	File     string        `json:"file"`
+	Format   string        `json:"format"`
+	Original string        `json:"original"`
+	Name     string        `json:"name"`
+	Domain   string        `json:"domain"`
+	Path     string        `json:"path"`
+	Tag      string        `json:"tag"`
+	Digest   string        `json:"digest"`
+	Position *listPosition `json:"position"`
+}
+
+type listPosition struct {
+	Line      int `json:"line"`
+	Column    int `json:"column"`
+	EndLine   int `json:"endLine"`
+	EndColumn int `json:"endColumn"`
+}
+
+func listEntryNew(format dockfmt.Format, r dockref.Reference) listEntry {
+	entry := listEntry{
+		Original: r.Original(),
+		Name:     r.Name(),
+		Domain:   r.Domain(),
+		Path:     r.Path(),
+		Tag:      r.Tag(),
+		Digest:   r.DigestString(),
+	}
+
+	if format != nil {
+		entry.Format = format.Name()
+	}
+
+	if location, ok := dockfmt.LocationOf(r); ok {
+		entry.File = location.File
+		entry.Position = &listPosition{
+			Line:      location.Line,
+			Column:    location.Column,
+			EndLine:   location.EndLine,
+			EndColumn: location.EndColumn,
+		}
+	}
+
+	return entry
+}
+
+// print prints the matches of one file. Without options the original image references are printed
+func (lo *ListOptions) print(stdout io.Writer, format dockfmt.Format, matches []dockref.Reference) error {
+	if lo != nil && lo.Output == "sarif" {
+		// printed as report once all files are processed
+		return nil
+	}
+
+	var results *multierror.Error
+	for _, r := range matches {
+		var err error
+		switch {
+		case lo != nil && lo.Output == "json":
+			lo.entries = append(lo.entries, listEntryNew(format, r))
+		case lo != nil && lo.Output == "ndjson":
+			err = json.NewEncoder(stdout).Encode(listEntryNew(format, r))
+		default:
+			_, err = fmt.Fprintf(stdout, "%s\n", lo.text(r))
+		}
+		results = multierror.Append(results, err)
+	}
+	return results.ErrorOrNil()
+}
+
+func (lo *ListOptions) text(r dockref.Reference) string {
+	if lo == nil || !lo.WithPosition {
+		return r.Original()
+	}
+
+	location, ok := dockfmt.LocationOf(r)
+	if !ok {
+		return r.Original()
+	}
+	return fmt.Sprintf("%s: %s", location, r.Original())
+}
+
+// flush prints the entries collected for --output json
+func (lo *ListOptions) flush(stdout io.Writer) error {
+	if lo == nil || lo.Output != "json" {
+		return nil
+	}
+
+	entries := lo.entries
+	if entries == nil {
+		entries = make([]listEntry, 0)
+	}
+	lo.entries = nil
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
 }
@@ -34,7 +34,7 @@ func TestFilenameRequiredWithList(t *testing.T) {
 func TestListCallsFindExecute(t *testing.T) {
 	cmd, _, _, _ := testMain([]string{"list", "fileName"}, addListCommand)
 
-	_, ok := cmd.(*MatchingOptions)
+	_, ok := cmd.(*listOptions)
 	assert.True(t, ok)
 }
 
@@ -91,6 +91,7 @@ func TestListHelpContainsImplementedPredicates(t *testing.T) {
 
 	assert.Contains(t, buffer.String(), "--latest")
 	assert.Contains(t, buffer.String(), "--unpinned")
+	assert.Contains(t, buffer.String(), "--outdated")
 
 	assert.Equal(t, ExitSuccess, exitCode)
 }
@@ -104,9 +105,7 @@ func TestListHelpHidesUnimplementedPredicates(t *testing.T) {
 	mainOptions.SetStdout(buffer)
 	exitCode := doMain(mainOptions)
 
-	assert.NotContains(t, buffer.String(), "--outdated")
-	assert.NotContains(t, buffer.String(), "--name")
-	assert.NotContains(t, buffer.String(), "--domain")
+	assert.NotContains(t, buffer.String(), "--digest")
 
 	assert.Equal(t, ExitSuccess, exitCode)
 }
@@ -117,12 +116,17 @@ type FormatProcessorMock struct {
 	*mock.Mock
 
 	process func(imageNameProcessor dockfmt.ImageNameProcessor) error
+	format  dockfmt.Format
 }
 
 func (d *FormatProcessorMock) WithWriter(writer io.Writer) dockfmt.FormatProcessor {
 	panic("implement me")
 }
 
+func (d *FormatProcessorMock) Format() dockfmt.Format {
+	return d.format
+}
+
 func (d *FormatProcessorMock) Process(imageNameProcessor dockfmt.ImageNameProcessor) error {
 	return d.process(imageNameProcessor)
 }
@@ -149,3 +153,24 @@ func TestListCommandPrints(t *testing.T) {
 	assert.Contains(t, s, "nginx:latest")
 	assert.Contains(t, s, "nginx:1.2")
 }
+
+func TestListEntryContainsReferenceParts(t *testing.T) {
+	ref, _ := dockref.FromOriginal("quay.io/coreos/etcd:v3.3@sha256:2d0b5c9d3b8f4d0e5e9d9d6b7c7b55a0c6b2e1f3a4b5c6d7e8f9a0b1c2d3e4f5")
+	location := dockfmt.Location{File: "Dockerfile", Line: 1, Column: 6, EndLine: 1, EndColumn: 103}
+
+	format := new(FormatMock)
+	format.OnName().Return("Dockerfile")
+
+	entry := listEntryNew(format, dockfmt.LocatedReferenceNew(ref, location))
+	assert.Equal(t, listEntry{
+		File:     "Dockerfile",
+		Format:   "Dockerfile",
+		Original: ref.Original(),
+		Name:     "quay.io/coreos/etcd",
+		Domain:   "quay.io",
+		Path:     "coreos/etcd",
+		Tag:      "v3.3",
+		Digest:   "sha256:2d0b5c9d3b8f4d0e5e9d9d6b7c7b55a0c6b2e1f3a4b5c6d7e8f9a0b1c2d3e4f5",
+		Position: &listPosition{Line: 1, Column: 6, EndLine: 1, EndColumn: 103},
+	}, entry)
+}
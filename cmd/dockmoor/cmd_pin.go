@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/dockres"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jessevdk/go-flags"
+)
+
+type pinOptions struct {
+	MatchingOptions
+
+	OutputOptions OutputOptions `group:"Output Options" description:"Control where the result is written to"`
+}
+
+func addPinCommand(mainOptions *mainOptions, adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+	var pinOptions pinOptions
+	pinOptions.mainOpts = mainOptions
+	pinOptions.mode = matchOnly
+
+	return adder(mainOptions, "pin",
+		"Pin image references with matching predicates to their digest.",
+		"Pin image references with matching predicates to their digest. Image references that do not match are written unchanged. Returns exit code 0 when the given input contains at least one image reference that satisfy the given conditions and is of valid format, non-null otherwise",
+		&pinOptions)
+}
+
+func verifyPinOptions(po *pinOptions) error {
+	err := verifyMatchOptions(&po.MatchingOptions)
+	if err != nil {
+		return err
+	}
+
+	err = verifySingleInputFile(&po.MatchingOptions)
+	if err != nil {
+		return err
+	}
+
+	return verifyOutputOptions(&po.OutputOptions, po.Positional.InputFile)
+}
+
+func (po *pinOptions) ExecuteWithExitCode(args []string) (ExitCode, error) {
+	log := po.Log()
+
+	errVerify := verifyPinOptions(po)
+	if errVerify != nil {
+		log.Errorf("Invalid options: %s\n", errVerify.Error())
+
+		parser := flags.NewParser(&struct{}{}, flags.HelpFlag)
+		command, _ := addPinCommand(po.mainOpts, AddCommand)
+		if command != nil {
+			parser.ParseArgs([]string{command.Name, "--help"})
+		}
+
+		parser.WriteHelp(po.mainOpts.stdout)
+		return ExitInvalidParams, errVerify
+	}
+
+	resolver, err := po.resolver()
+	if err != nil {
+		log.Errorf("Could not create resolver: %s", err.Error())
+		return ExitResolveError, err
+	}
+
+	filePathInput := string(po.Positional.InputFile)
+	return po.withFormatProcessor(filePathInput, func(formatProcessor dockfmt.FormatProcessor) (ExitCode, error) {
+		return po.pinFormatProcessor(formatProcessor, resolver)
+	})
+}
+
+func (po *pinOptions) pinFormatProcessor(formatProcessor dockfmt.FormatProcessor, resolver dockres.Resolver) (exitCode ExitCode, err error) {
+	log := po.Log()
+	predicate := po.getPredicate()
+
+	var resolveErrors *multierror.Error
+	pinnedCount := 0
+	matchedCount := 0
+
+	var processor dockfmt.ImageNameProcessor = func(r dockref.Reference) (string, error) {
+		if !predicate.Matches(r) {
+			return r.Original(), nil
+		}
+		matchedCount++
+
+		pinned, err := dockres.Pin(resolver, r)
+		if err != nil {
+			log.Errorf("Could not pin '%s': %s", r.Original(), err.Error())
+			resolveErrors = multierror.Append(resolveErrors, err)
+			return r.Original(), nil
+		}
+
+		pinnedCount++
+		return pinned.Original(), nil
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	err = formatProcessor.WithWriter(buffer).Process(processor)
+	if err != nil {
+		log.Errorf("Error during processing: %s", err.Error())
+		return ExitInvalidFormat, err
+	}
+
+	err = po.takeNonSemverErrors()
+	if err != nil {
+		log.Errorf("Found tags that are no semantic version: %s", err.Error())
+		return ExitInvalidFormat, err
+	}
+
+	err = po.OutputOptions.writeOutput(po.Stdout(), po.Positional.InputFile, buffer.Bytes())
+	if err != nil {
+		log.Errorf("Could not write output: %s", err.Error())
+		return ExitCouldNotWriteFile, err
+	}
+
+	log.Infof("Pinned %d of %d matching image references", pinnedCount, matchedCount)
+
+	if resolveErrors != nil {
+		return ExitResolveError, resolveErrors.ErrorOrNil()
+	}
+
+	if matchedCount == 0 {
+		return ExitNotFound, nil
+	}
+
+	return ExitSuccess, nil
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/dockres"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const pinTestDigest = "sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"
+
+var _ dockres.Resolver = (*resolverFake)(nil)
+
+type resolverFake struct {
+	digests map[string]digest.Digest
+	tags    map[string][]string
+}
+
+func (r resolverFake) ListTags(ref dockref.Reference) ([]string, error) {
+	tags, ok := r.tags[ref.Name()]
+	if !ok {
+		return nil, errors.Errorf("Unknown repository %s", ref.Name())
+	}
+	return tags, nil
+}
+
+func (r resolverFake) FetchManifest(ref dockref.Reference) (dockres.Manifest, error) {
+	return dockres.Manifest{}, errors.New("not implemented")
+}
+
+func (r resolverFake) ResolveDigest(ref dockref.Reference) (digest.Digest, error) {
+	dig, ok := r.digests[ref.Name()+":"+ref.Tag()]
+	if !ok {
+		return "", errors.Errorf("Unknown image %s", ref.Original())
+	}
+	return dig, nil
+}
+
+func withFakeResolver(digests map[string]digest.Digest) func() {
+	return withResolverFake(resolverFake{digests: digests})
+}
+
+func withResolverFake(fake resolverFake) func() {
+	org := resolverFactories["dockerd"]
+	resolverFactories["dockerd"] = func(*MatchingOptions) (dockres.Resolver, error) {
+		return fake, nil
+	}
+	return func() {
+		resolverFactories["dockerd"] = org
+	}
+}
+
+func pinTestDockerfile(dir string, content string) string {
+	fileName := filepath.Join(dir, "Dockerfile")
+	if err := ioutil.WriteFile(fileName, []byte(content), 0666); err != nil {
+		log.Fatal(err)
+	}
+	return fileName
+}
+
+func TestFilenameRequiredWithPin(t *testing.T) {
+	_, _, exitCode, stdout := testMain([]string{"pin"}, addPinCommand)
+	assert.NotEqual(t, 0, exitCode)
+	assert.Contains(t, stdout.String(), "level=error")
+	assert.Contains(t, stdout.String(), "the required argument `InputFile` was not provided")
+}
+
+func TestPinCallsPinExecute(t *testing.T) {
+	cmd, _, _, _ := testMain([]string{"pin", "fileName"}, addPinCommand)
+
+	_, ok := cmd.(*pinOptions)
+	assert.True(t, ok)
+}
+
+func TestPinHelpContainsOptions(t *testing.T) {
+	os.Args = []string{"exe", "pin", "--help"}
+
+	mainOptions := mainOptionsACNew(addPinCommand)
+	exitCode := doMain(mainOptions)
+
+	stdout := mainOptions.stdout.(interface{ String() string }).String()
+	assert.Contains(t, stdout, "--latest")
+	assert.Contains(t, stdout, "--output-file")
+	assert.Contains(t, stdout, "--in-place")
+	assert.Equal(t, ExitSuccess, exitCode)
+}
+
+func TestPinOutputAndInPlaceAreExclusive(t *testing.T) {
+	po := &pinOptions{}
+	po.OutputOptions.InPlace = true
+	po.OutputOptions.OutputFile = "out"
+
+	assert.Equal(t, ErrOutputAndInPlace, verifyPinOptions(po))
+}
+
+func TestPinInPlaceRequiresFile(t *testing.T) {
+	po := &pinOptions{}
+	po.OutputOptions.InPlace = true
+	po.Positional.InputFile = "-"
+
+	assert.Equal(t, ErrInPlaceStdin, verifyPinOptions(po))
+}
+
+func TestPinWritesToStdout(t *testing.T) {
+	defer withFakeResolver(map[string]digest.Digest{
+		"docker.io/library/nginx:1.15": pinTestDigest,
+	})()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx:1.15\nRUN echo\n")
+
+	stdout, code := shell(t, `dockmoor pin {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "FROM nginx:1.15@"+pinTestDigest+"\nRUN echo\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	content, _ := ioutil.ReadFile(tmpfn)
+	assert.Equal(t, "FROM nginx:1.15\nRUN echo\n", string(content))
+}
+
+func TestPinOnlyPinsMatchingReferences(t *testing.T) {
+	defer withFakeResolver(map[string]digest.Digest{
+		"docker.io/library/nginx:":       pinTestDigest,
+		"docker.io/library/alpine:3.8":   pinTestDigest,
+		"docker.io/library/nginx:latest": pinTestDigest,
+	})()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx\nFROM alpine:3.8\n")
+
+	stdout, code := shell(t, `dockmoor pin --latest {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "FROM nginx@"+pinTestDigest+"\nFROM alpine:3.8\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+}
+
+func TestPinWritesToOutputFile(t *testing.T) {
+	defer withFakeResolver(map[string]digest.Digest{
+		"docker.io/library/nginx:1.15": pinTestDigest,
+	})()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx:1.15\n")
+	outfn := filepath.Join(dir, "Dockerfile.pinned")
+
+	stdout, code := shell(t, `dockmoor pin --output-file {{.Output}} {{.Dockerfile}}`, struct {
+		Dockerfile string
+		Output     string
+	}{tmpfn, outfn})
+
+	assert.Empty(t, stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	content, _ := ioutil.ReadFile(outfn)
+	assert.Equal(t, "FROM nginx:1.15@"+pinTestDigest+"\n", string(content))
+}
+
+func TestPinInPlace(t *testing.T) {
+	defer withFakeResolver(map[string]digest.Digest{
+		"docker.io/library/nginx:1.15": pinTestDigest,
+	})()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx:1.15\n")
+
+	stdout, code := shell(t, `dockmoor pin --in-place {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Empty(t, stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	content, _ := ioutil.ReadFile(tmpfn)
+	assert.Equal(t, "FROM nginx:1.15@"+pinTestDigest+"\n", string(content))
+}
+
+func TestPinReportsResolveErrors(t *testing.T) {
+	defer withFakeResolver(map[string]digest.Digest{
+		"docker.io/library/nginx:1.15": pinTestDigest,
+	})()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx:1.15\nFROM unknown:1.0\n")
+
+	stdout, code := shell(t, `dockmoor pin {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Contains(t, stdout, "FROM nginx:1.15@"+pinTestDigest+"\nFROM unknown:1.0\n")
+	assert.Contains(t, stdout, "Could not pin 'unknown:1.0'")
+	assert.Equal(t, ExitResolveError, code)
+}
+
+func TestPinWithoutMatchesExitsNotFound(t *testing.T) {
+	defer withFakeResolver(map[string]digest.Digest{})()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx:1.15\n")
+
+	stdout, code := shell(t, `dockmoor pin --latest {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "FROM nginx:1.15\n", stdout)
+	assert.Equal(t, ExitNotFound, code)
+}
+
+func TestPinCachesDigestsOfRegistries(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	orgCacheDirectory := cacheDirectory
+	orgFactory := resolverFactories["registry"]
+	defer func() {
+		cacheDirectory = orgCacheDirectory
+		resolverFactories["registry"] = orgFactory
+	}()
+	cacheDirectory = func() (string, error) {
+		return filepath.Join(dir, "cache"), nil
+	}
+	withRegistry := func(digests map[string]digest.Digest) {
+		resolverFactories["registry"] = func(*MatchingOptions) (dockres.Resolver, error) {
+			return resolverFake{digests: digests}, nil
+		}
+	}
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx:1.15\n")
+	pin := func(options string) (string, ExitCode) {
+		return shell(t, `dockmoor pin --resolver registry `+options+` {{.Dockerfile}}`, struct {
+			Dockerfile string
+		}{tmpfn})
+	}
+
+	withRegistry(map[string]digest.Digest{"docker.io/library/nginx:1.15": pinTestDigest})
+	stdout, code := pin("--offline")
+	assert.Contains(t, stdout, "digest of docker.io/library/nginx:1.15 is not cached")
+	assert.Equal(t, ExitResolveError, code)
+
+	stdout, code = pin("")
+	assert.Equal(t, "FROM nginx:1.15@"+pinTestDigest+"\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	withRegistry(map[string]digest.Digest{})
+	stdout, code = pin("--offline")
+	assert.Equal(t, "FROM nginx:1.15@"+pinTestDigest+"\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	stdout, code = pin("--cache-ttl 1h")
+	assert.Equal(t, "FROM nginx:1.15@"+pinTestDigest+"\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	stdout, code = pin("--refresh")
+	assert.Contains(t, stdout, "Unknown image nginx:1.15")
+	assert.Equal(t, ExitResolveError, code)
+
+	stdout, code = pin("--offline --refresh")
+	assert.Contains(t, stdout, ErrOfflineAndRefresh.Error())
+	assert.Equal(t, ExitInvalidParams, code)
+}
+
+func TestPinComposeFile(t *testing.T) {
+	defer withFakeResolver(map[string]digest.Digest{
+		"docker.io/library/nginx:1.15": pinTestDigest,
+	})()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "docker-compose.yml")
+	ioutil.WriteFile(tmpfn, []byte("services:\n  web:\n    image: \"nginx:1.15\" # frontend\n"), 0666)
+
+	stdout, code := shell(t, `dockmoor pin {{.ComposeFile}}`, struct {
+		ComposeFile string
+	}{tmpfn})
+
+	assert.Equal(t, "services:\n  web:\n    image: \"nginx:1.15@"+pinTestDigest+"\" # frontend\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+}
+
+func TestPinUpdatesArgDefault(t *testing.T) {
+	defer withFakeResolver(map[string]digest.Digest{
+		"docker.io/library/golang:1.11": pinTestDigest,
+	})()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "ARG GO_VERSION=1.10\nFROM golang:${GO_VERSION}\n")
+
+	stdout, code := shell(t, `dockmoor list --build-arg GO_VERSION=1.11 {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "golang:1.11\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	pinTestDockerfile(dir, "ARG GO_VERSION=1.11\nFROM golang:${GO_VERSION}\n")
+	stdout, code = shell(t, `dockmoor pin {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "ARG GO_VERSION=1.11@"+pinTestDigest+"\nFROM golang:${GO_VERSION}\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+}
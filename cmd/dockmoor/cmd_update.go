@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/dockres"
+	"github.com/hashicorp/go-multierror"
+	"github.com/jessevdk/go-flags"
+)
+
+type updateOptions struct {
+	MatchingOptions
+
+	UpdateOptions struct {
+		Strategy string `required:"no" long:"strategy" description:"Which newer versions to update to: the newest patch, minor or major version as specific as the current tag, or the latest version" choice:"patch" choice:"minor" choice:"major" choice:"latest" default:"minor"`
+		Pin      bool   `required:"no" long:"pin" description:"Pin updated image references to their digest. Image references that were pinned before are always pinned again"`
+	} `group:"Update Options" description:"Control how image references are updated"`
+
+	OutputOptions OutputOptions `group:"Output Options" description:"Control where the result is written to"`
+}
+
+func addUpdateCommand(mainOptions *mainOptions, adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+	var updateOptions updateOptions
+	updateOptions.mainOpts = mainOptions
+	updateOptions.mode = matchOnly
+
+	return adder(mainOptions, "update",
+		"Update tags of image references with matching predicates to newer versions.",
+		"Update tags of image references with matching predicates to the newest version allowed by the strategy. Image references that do not match or have no newer version are written unchanged. Returns exit code 0 when the given input contains at least one image reference that satisfy the given conditions and is of valid format, non-null otherwise",
+		&updateOptions)
+}
+
+func verifyUpdateOptions(uo *updateOptions) error {
+	err := verifyMatchOptions(&uo.MatchingOptions)
+	if err != nil {
+		return err
+	}
+
+	if uo.UpdateOptions.Strategy != "" {
+		_, err = dockproc.UpdateStrategyFromString(uo.UpdateOptions.Strategy)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = verifySingleInputFile(&uo.MatchingOptions)
+	if err != nil {
+		return err
+	}
+
+	return verifyOutputOptions(&uo.OutputOptions, uo.Positional.InputFile)
+}
+
+func (uo *updateOptions) strategy() dockproc.UpdateStrategy {
+	strategy, err := dockproc.UpdateStrategyFromString(uo.UpdateOptions.Strategy)
+	if err != nil {
+		return dockproc.UpdateMinor
+	}
+	return strategy
+}
+
+func (uo *updateOptions) ExecuteWithExitCode(args []string) (ExitCode, error) {
+	log := uo.Log()
+
+	errVerify := verifyUpdateOptions(uo)
+	if errVerify != nil {
+		log.Errorf("Invalid options: %s\n", errVerify.Error())
+
+		parser := flags.NewParser(&struct{}{}, flags.HelpFlag)
+		command, _ := addUpdateCommand(uo.mainOpts, AddCommand)
+		if command != nil {
+			parser.ParseArgs([]string{command.Name, "--help"})
+		}
+
+		parser.WriteHelp(uo.mainOpts.stdout)
+		return ExitInvalidParams, errVerify
+	}
+
+	filePathInput := string(uo.Positional.InputFile)
+	return uo.withFormatProcessor(filePathInput, uo.updateFormatProcessor)
+}
+
+func (uo *updateOptions) updateFormatProcessor(formatProcessor dockfmt.FormatProcessor) (exitCode ExitCode, err error) {
+	log := uo.Log()
+	predicate := uo.getPredicate()
+	tagSource := uo.tagSource()
+	strategy := uo.strategy()
+
+	var resolveErrors *multierror.Error
+	updatedCount := 0
+	matchedCount := 0
+
+	var processor dockfmt.ImageNameProcessor = func(r dockref.Reference) (string, error) {
+		if !predicate.Matches(r) {
+			return r.Original(), nil
+		}
+		matchedCount++
+
+		updated, err := uo.update(r, tagSource, strategy)
+		if err != nil {
+			log.Errorf("Could not update '%s': %s", r.Original(), err.Error())
+			resolveErrors = multierror.Append(resolveErrors, err)
+			return r.Original(), nil
+		}
+
+		if updated.Original() != r.Original() {
+			log.Infof("Updating %s to %s", r.Original(), updated.Original())
+			updatedCount++
+		}
+		return updated.Original(), nil
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	err = formatProcessor.WithWriter(buffer).Process(processor)
+	if err != nil {
+		log.Errorf("Error during processing: %s", err.Error())
+		return ExitInvalidFormat, err
+	}
+
+	err = uo.takeNonSemverErrors()
+	if err != nil {
+		log.Errorf("Found tags that are no semantic version: %s", err.Error())
+		return ExitInvalidFormat, err
+	}
+
+	err = uo.OutputOptions.writeOutput(uo.Stdout(), uo.Positional.InputFile, buffer.Bytes())
+	if err != nil {
+		log.Errorf("Could not write output: %s", err.Error())
+		return ExitCouldNotWriteFile, err
+	}
+
+	log.Infof("Updated %d of %d matching image references", updatedCount, matchedCount)
+
+	if resolveErrors != nil {
+		return ExitResolveError, resolveErrors.ErrorOrNil()
+	}
+
+	if matchedCount == 0 {
+		return ExitNotFound, nil
+	}
+
+	return ExitSuccess, nil
+}
+
+// update returns r with the tag chosen by the strategy, or r itself when there is no newer version.
+// The result is pinned when r was pinned or when --pin is given.
+func (uo *updateOptions) update(r dockref.Reference, tagSource dockproc.TagSource, strategy dockproc.UpdateStrategy) (dockref.Reference, error) {
+	if _, err := dockref.ParseTagVersion(r.Tag()); err != nil {
+		return r, nil
+	}
+
+	tags, err := tagSource.ListTags(r)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, found := dockproc.FindUpdate(r, tags, strategy)
+	if !found {
+		return r, nil
+	}
+
+	updated, err := r.WithTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.DigestString() == "" && !uo.UpdateOptions.Pin {
+		return updated, nil
+	}
+
+	resolver, err := uo.resolver()
+	if err != nil {
+		return nil, err
+	}
+
+	return dockres.Pin(resolver, updated)
+}
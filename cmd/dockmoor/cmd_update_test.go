@@ -0,0 +1,155 @@
+package main
+
+import (
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const updateTestDigest = "sha256:3e2b14e7b1b3a1e5f2a4d46c1c5a84b3d5e6a4c5e1f0a3b2c1d0e9f8a7b6c5d4"
+
+func updateTestResolver() resolverFake {
+	return resolverFake{
+		digests: map[string]digest.Digest{
+			"docker.io/library/nginx:1.15.3": updateTestDigest,
+			"docker.io/library/nginx:1.14.2": pinTestDigest,
+		},
+		tags: map[string][]string{
+			"docker.io/library/nginx":  {"latest", "1.14.0", "1.14.2", "1.15.0", "1.15.3", "2.0.0"},
+			"docker.io/library/alpine": {"latest", "3.7", "3.8"},
+		},
+	}
+}
+
+func TestFilenameRequiredWithUpdate(t *testing.T) {
+	_, _, exitCode, stdout := testMain([]string{"update"}, addUpdateCommand)
+	assert.NotEqual(t, 0, exitCode)
+	assert.Contains(t, stdout.String(), "level=error")
+	assert.Contains(t, stdout.String(), "the required argument `InputFile` was not provided")
+}
+
+func TestUpdateCallsUpdateExecute(t *testing.T) {
+	cmd, _, _, _ := testMain([]string{"update", "fileName"}, addUpdateCommand)
+
+	_, ok := cmd.(*updateOptions)
+	assert.True(t, ok)
+}
+
+func TestUpdateHelpContainsOptions(t *testing.T) {
+	os.Args = []string{"exe", "update", "--help"}
+
+	mainOptions := mainOptionsACNew(addUpdateCommand)
+	exitCode := doMain(mainOptions)
+
+	stdout := mainOptions.stdout.(interface{ String() string }).String()
+	assert.Contains(t, stdout, "--strategy")
+	assert.Contains(t, stdout, "--pin")
+	assert.Contains(t, stdout, "--in-place")
+	assert.Equal(t, ExitSuccess, exitCode)
+}
+
+func TestUpdateOutputAndInPlaceAreExclusive(t *testing.T) {
+	uo := &updateOptions{}
+	uo.OutputOptions.InPlace = true
+	uo.OutputOptions.OutputFile = "out"
+
+	assert.Equal(t, ErrOutputAndInPlace, verifyUpdateOptions(uo))
+}
+
+func TestUpdateStrategies(t *testing.T) {
+	defer withResolverFake(updateTestResolver())()
+
+	expectations := map[string]string{
+		"patch":  "FROM nginx:1.14.2\nFROM alpine:3.7\n",
+		"minor":  "FROM nginx:1.15.3\nFROM alpine:3.8\n",
+		"major":  "FROM nginx:2.0.0\nFROM alpine:3.8\n",
+		"latest": "FROM nginx:2.0.0\nFROM alpine:3.8\n",
+	}
+
+	for strategy, expected := range expectations {
+		t.Run(strategy, func(t *testing.T) {
+			dir, _ := ioutil.TempDir("", "dockmoor")
+			defer os.RemoveAll(dir)
+
+			tmpfn := pinTestDockerfile(dir, "FROM nginx:1.14.0\nFROM alpine:3.7\n")
+
+			stdout, code := shell(t, `dockmoor update --strategy {{.Strategy}} {{.Dockerfile}}`, struct {
+				Strategy   string
+				Dockerfile string
+			}{strategy, tmpfn})
+
+			assert.Equal(t, expected, stdout)
+			assert.Equal(t, ExitSuccess, code)
+		})
+	}
+}
+
+func TestUpdateRepinsPinnedReferences(t *testing.T) {
+	defer withResolverFake(updateTestResolver())()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx:1.14.0@"+pinTestDigest+"\n")
+
+	stdout, code := shell(t, `dockmoor update {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "FROM nginx:1.15.3@"+updateTestDigest+"\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+}
+
+func TestUpdatePinsWhenRequested(t *testing.T) {
+	defer withResolverFake(updateTestResolver())()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx:1.14.0\n")
+
+	stdout, code := shell(t, `dockmoor update --strategy patch --pin {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Equal(t, "FROM nginx:1.14.2@"+pinTestDigest+"\n", stdout)
+	assert.Equal(t, ExitSuccess, code)
+}
+
+func TestUpdateKeepsReferencesWithoutVersion(t *testing.T) {
+	defer withResolverFake(updateTestResolver())()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx\nFROM nginx:latest\nFROM nginx:2.0.0\n")
+
+	stdout, code := shell(t, `dockmoor update --in-place {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Empty(t, stdout)
+	assert.Equal(t, ExitSuccess, code)
+
+	content, _ := ioutil.ReadFile(tmpfn)
+	assert.Equal(t, "FROM nginx\nFROM nginx:latest\nFROM nginx:2.0.0\n", string(content))
+}
+
+func TestUpdateReportsResolveErrors(t *testing.T) {
+	defer withResolverFake(updateTestResolver())()
+
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := pinTestDockerfile(dir, "FROM nginx:1.14.0\nFROM unknown:1.0\n")
+
+	stdout, code := shell(t, `dockmoor update {{.Dockerfile}}`, struct {
+		Dockerfile string
+	}{tmpfn})
+
+	assert.Contains(t, stdout, "FROM nginx:1.15.3\nFROM unknown:1.0\n")
+	assert.Contains(t, stdout, "Could not update 'unknown:1.0'")
+	assert.Equal(t, ExitResolveError, code)
+}
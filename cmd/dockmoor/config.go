@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jessevdk/go-flags"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const configFileName = ".dockmoor.yml"
+
+// workingDirectory is where the search for the configuration file starts
+var workingDirectory = os.Getwd
+
+// projectConfig is the content of a .dockmoor.yml. The settings apply to all commands, the settings of a
+// profile selected with --profile replace them. Options given on the command line replace both
+type projectConfig struct {
+	configSettings `yaml:",inline"`
+	Profiles       map[string]configSettings `yaml:"profiles"`
+
+	// dir is the directory of the configuration file, include and exclude paths are relative to it
+	dir string
+
+	// options are the values of the command options by long name, including the selected profile
+	options map[string][]string
+}
+
+type configSettings struct {
+	LogLevel   string                `yaml:"log-level"`
+	Include    []string              `yaml:"include"`
+	Exclude    []string              `yaml:"exclude"`
+	Predicates configPredicates      `yaml:"predicates"`
+	Registry   configRegistry        `yaml:"registry"`
+	Formats    configFormats         `yaml:"formats"`
+	Rules      map[string]configRule `yaml:"rules"`
+}
+
+// configRule is a rule of the check command. Image references matching the where expression violate the rule
+type configRule struct {
+	Severity string `yaml:"severity"`
+	Where    string `yaml:"where"`
+	Message  string `yaml:"message"`
+}
+
+// configPredicates are named like the options of the Predicates groups
+type configPredicates struct {
+	Domains       []string `yaml:"domain"`
+	DomainRegexps []string `yaml:"domain-regex"`
+	Names         []string `yaml:"name"`
+	NameRegexps   []string `yaml:"name-regex"`
+	Untagged      *bool    `yaml:"untagged"`
+	Latest        *bool    `yaml:"latest"`
+	Outdated      *bool    `yaml:"outdated"`
+	Tags          []string `yaml:"tag"`
+	TagRegexps    []string `yaml:"tag-regex"`
+	TagSemver     string   `yaml:"tag-semver"`
+	NonSemver     string   `yaml:"non-semver"`
+	Unpinned      *bool    `yaml:"unpinned"`
+	Where         string   `yaml:"where"`
+}
+
+type configRegistry struct {
+	Resolver string `yaml:"resolver"`
+	CacheTTL string `yaml:"cache-ttl"`
+	Timeout  string `yaml:"timeout"`
+}
+
+type configFormats struct {
+	Dockerfile struct {
+		BuildArgs map[string]string `yaml:"build-args"`
+	} `yaml:"dockerfile"`
+
+	Kubernetes struct {
+		ImagePaths []string `yaml:"image-paths"`
+	} `yaml:"kubernetes"`
+
+	GithubActions struct {
+		MatchActions *bool `yaml:"match-actions"`
+	} `yaml:"github-actions"`
+
+	Helm struct {
+		Keys map[string][]string `yaml:"keys"`
+	} `yaml:"helm"`
+
+	Kustomize struct {
+		FollowResources *bool `yaml:"follow-resources"`
+	} `yaml:"kustomize"`
+
+	Travis struct {
+		DockerPull *bool `yaml:"docker-pull"`
+	} `yaml:"travis"`
+
+	Compose struct {
+		BuildArgs *bool `yaml:"build-args"`
+	} `yaml:"compose"`
+}
+
+// findConfigFile returns the first .dockmoor.yml in dir or its parents, or "" if there is none
+func findConfigFile(dir string) string {
+	for {
+		candidate := filepath.Join(dir, configFileName)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadConfig reads the configuration given with --config or found from the working directory upward and
+// returns the settings of the selected profile. The result is nil when there is no configuration file
+func loadConfig(configFile string, profile string) (*projectConfig, error) {
+	if configFile == "" {
+		dir, err := workingDirectory()
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not determine working directory")
+		}
+		configFile = findConfigFile(dir)
+	}
+
+	if configFile == "" {
+		if profile != "" {
+			return nil, errors.Errorf("Profile '%s' selected, but no %s found", profile, configFileName)
+		}
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(filepath.Clean(configFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not read %s", configFile)
+	}
+
+	config, err := parseConfig(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid configuration %s", configFile)
+	}
+	config.dir = filepath.Dir(configFile)
+	config.options = config.optionValues()
+
+	if profile != "" {
+		settings, ok := config.Profiles[profile]
+		if !ok {
+			return nil, errors.Errorf("Unknown profile '%s' in %s", profile, configFile)
+		}
+		config.selectProfile(settings)
+	}
+
+	return config, nil
+}
+
+func parseConfig(content []byte) (*projectConfig, error) {
+	config := &projectConfig{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// selectProfile replaces the settings by the settings given in the profile
+func (config *projectConfig) selectProfile(profile configSettings) {
+	if profile.LogLevel != "" {
+		config.LogLevel = profile.LogLevel
+	}
+	if profile.Include != nil {
+		config.Include = profile.Include
+	}
+	if profile.Exclude != nil {
+		config.Exclude = profile.Exclude
+	}
+	for name, values := range profile.optionValues() {
+		config.options[name] = values
+	}
+	for name, rule := range profile.Rules {
+		if config.Rules == nil {
+			config.Rules = make(map[string]configRule)
+		}
+		config.Rules[name] = rule
+	}
+}
+
+// optionValues returns the values of the command options by long name, like the options would be given
+// on the command line
+func (settings configSettings) optionValues() map[string][]string {
+	options := make(map[string][]string)
+
+	strs := func(name string, values []string) {
+		if values != nil {
+			options[name] = values
+		}
+	}
+	str := func(name string, value string) {
+		if value != "" {
+			options[name] = []string{value}
+		}
+	}
+	boolean := func(name string, value *bool) {
+		if value != nil {
+			options[name] = []string{strconv.FormatBool(*value)}
+		}
+	}
+
+	p := settings.Predicates
+	strs("domain", p.Domains)
+	strs("domain-regex", p.DomainRegexps)
+	strs("name", p.Names)
+	strs("name-regex", p.NameRegexps)
+	boolean("untagged", p.Untagged)
+	boolean("latest", p.Latest)
+	boolean("outdated", p.Outdated)
+	strs("tag", p.Tags)
+	strs("tag-regex", p.TagRegexps)
+	str("tag-semver", p.TagSemver)
+	str("non-semver", p.NonSemver)
+	boolean("unpinned", p.Unpinned)
+	str("where", p.Where)
+
+	str("resolver", settings.Registry.Resolver)
+	str("cache-ttl", settings.Registry.CacheTTL)
+	str("registry-timeout", settings.Registry.Timeout)
+
+	if args := settings.Formats.Dockerfile.BuildArgs; args != nil {
+		buildArgs := make([]string, 0, len(args))
+		for key, value := range args {
+			buildArgs = append(buildArgs, key+"="+value)
+		}
+		sort.Strings(buildArgs)
+		options["build-arg"] = buildArgs
+	}
+	strs("k8s-image-path", settings.Formats.Kubernetes.ImagePaths)
+	boolean("match-actions", settings.Formats.GithubActions.MatchActions)
+	if fieldKeys := settings.Formats.Helm.Keys; fieldKeys != nil {
+		helmKeys := make([]string, 0, len(fieldKeys))
+		for field, keys := range fieldKeys {
+			for _, key := range keys {
+				helmKeys = append(helmKeys, field+"="+key)
+			}
+		}
+		sort.Strings(helmKeys)
+		options["helm-key"] = helmKeys
+	}
+	boolean("kustomize-resources", settings.Formats.Kustomize.FollowResources)
+	boolean("travis-docker-pull", settings.Formats.Travis.DockerPull)
+	boolean("compose-build-args", settings.Formats.Compose.BuildArgs)
+
+	return options
+}
+
+// includeArguments are the include paths relative to the working directory, used when no input file is given
+func (config *projectConfig) includeArguments() []string {
+	arguments := make([]string, 0, len(config.Include))
+	for _, include := range config.Include {
+		arguments = append(arguments, config.resolve(include))
+	}
+	return arguments
+}
+
+func (config *projectConfig) resolve(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+
+	joined := filepath.Join(config.dir, file)
+	if dir, err := workingDirectory(); err == nil {
+		if rel, err := filepath.Rel(dir, joined); err == nil {
+			return rel
+		}
+	}
+	return joined
+}
+
+// excluded reports whether the file is matched by an exclude pattern. Patterns containing a / match the path
+// relative to the configuration file, or one of its parent directories. Other patterns match the name of the
+// file or of any of its parent directories, e.g. vendor or *.generated.yaml
+func (config *projectConfig) excluded(file string) bool {
+	if config == nil || len(config.Exclude) == 0 {
+		return false
+	}
+
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(config.dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absFile)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range config.Exclude {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+		for candidate := rel; candidate != "." && candidate != "/"; candidate = path.Dir(candidate) {
+			name := candidate
+			if !strings.Contains(pattern, "/") {
+				name = path.Base(candidate)
+			}
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// configuredPredicateGroups are the options of predicates that replace each other. When an option of a group
+// is given on the command line, all configured options of the group are ignored, e.g. --tag 3.8 replaces
+// latest: true. non-semver only modifies tag-semver, so it is ignored but doesn't replace the group
+var configuredPredicateGroups = map[string][]string{
+	"domain": {"domain", "domain-regex"},
+	"name":   {"name", "name-regex"},
+	"tag":    {"untagged", "latest", "outdated", "tag", "tag-regex", "tag-semver"},
+	"digest": {"unpinned", "digest"},
+	"where":  {"where"},
+}
+
+var predicateGroupModifiers = map[string][]string{
+	"tag": {"non-semver"},
+}
+
+// replacedOptions returns the configured options of the predicate groups given on the command line
+func replacedOptions(command *flags.Command) map[string]bool {
+	replaced := make(map[string]bool)
+	for group, names := range configuredPredicateGroups {
+		given := false
+		for _, name := range names {
+			if option := command.FindOptionByLongName(name); option != nil && option.IsSet() {
+				given = true
+			}
+		}
+		if !given {
+			continue
+		}
+
+		for _, name := range append(names, predicateGroupModifiers[group]...) {
+			replaced[name] = true
+		}
+	}
+	return replaced
+}
+
+// apply sets the options of the active command that were not given on the command line
+func (config *projectConfig) apply(mainOptions *mainOptions) error {
+	parser := mainOptions.Parser()
+
+	if config.LogLevel != "" && !parser.FindOptionByLongName("log-level").IsSet() {
+		mainOptions.LogLevel = config.LogLevel
+	}
+
+	command := parser.Active
+	if command == nil {
+		return nil
+	}
+
+	replaced := replacedOptions(command)
+	names := make([]string, 0)
+	options := config.options
+	for name := range options {
+		if !replaced[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// go-flags sets the options from ini files like from the command line, with ParseAsDefaults
+	// only the options not given on the command line
+	ini := bytes.NewBuffer(nil)
+	fmt.Fprintf(ini, "[%s]\n", command.Name)
+	for _, name := range names {
+		if command.FindOptionByLongName(name) == nil {
+			continue
+		}
+		for _, value := range options[name] {
+			fmt.Fprintf(ini, "%s = %s\n", name, strconv.Quote(value))
+		}
+	}
+
+	iniParser := flags.NewIniParser(parser)
+	iniParser.ParseAsDefaults = true
+	err := iniParser.Parse(ini)
+	if iniErr, ok := err.(*flags.IniError); ok {
+		return errors.New(iniErr.Message)
+	}
+	return err
+}
+
+// isMissingInputFile reports whether parsing failed only because no input file was given
+func isMissingInputFile(err error) bool {
+	flagsErr, ok := err.(*flags.Error)
+	return ok && flagsErr.Type == flags.ErrRequired
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `
+log-level: INFO
+include: [ docker/, k8s/ ]
+exclude: [ vendor, k8s/generated/* ]
+predicates:
+  unpinned: true
+  domain: [ "*.corp.example.com" ]
+registry:
+  resolver: registry
+  cache-ttl: 24h
+  timeout: 1m
+formats:
+  dockerfile:
+    build-args:
+      GO_VERSION: "1.11"
+      BASE: alpine
+  kubernetes:
+    image-paths: [ "{.spec.steps[*].image}" ]
+  helm:
+    keys:
+      tag: [ version, imageTag ]
+profiles:
+  eol:
+    include: [ services/ ]
+    predicates:
+      unpinned: false
+      tag-semver: "<1.12"
+`
+
+func configTestDir(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "dockmoor")
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, configFileName), []byte(content), 0666))
+	return dir
+}
+
+func TestFindConfigFileSearchesParents(t *testing.T) {
+	dir := configTestDir(t, testConfig)
+	defer os.RemoveAll(dir)
+
+	nested := filepath.Join(dir, "a", "b")
+	assert.Nil(t, os.MkdirAll(nested, 0777))
+
+	assert.Equal(t, filepath.Join(dir, configFileName), findConfigFile(nested))
+	assert.Equal(t, filepath.Join(dir, configFileName), findConfigFile(dir))
+}
+
+func TestLoadConfigFromWorkingDirectory(t *testing.T) {
+	dir := configTestDir(t, testConfig)
+	defer os.RemoveAll(dir)
+
+	org := workingDirectory
+	defer func() { workingDirectory = org }()
+	workingDirectory = func() (string, error) {
+		return dir, nil
+	}
+
+	config, err := loadConfig("", "")
+	assert.Nil(t, err)
+	assert.Equal(t, dir, config.dir)
+	assert.Equal(t, "INFO", config.LogLevel)
+	assert.Equal(t, []string{"docker/", "k8s/"}, config.Include)
+	assert.Equal(t, map[string][]string{
+		"unpinned":         {"true"},
+		"domain":           {"*.corp.example.com"},
+		"resolver":         {"registry"},
+		"cache-ttl":        {"24h"},
+		"registry-timeout": {"1m"},
+		"build-arg":        {"BASE=alpine", "GO_VERSION=1.11"},
+		"k8s-image-path":   {"{.spec.steps[*].image}"},
+		"helm-key":         {"tag=imageTag", "tag=version"},
+	}, config.options)
+}
+
+func TestLoadConfigWithoutConfigFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	org := workingDirectory
+	defer func() { workingDirectory = org }()
+	workingDirectory = func() (string, error) {
+		return dir, nil
+	}
+
+	config, err := loadConfig("", "")
+	assert.Nil(t, err)
+	assert.Nil(t, config)
+
+	_, err = loadConfig("", "eol")
+	assert.EqualError(t, err, "Profile 'eol' selected, but no .dockmoor.yml found")
+}
+
+func TestLoadConfigAppliesProfile(t *testing.T) {
+	dir := configTestDir(t, testConfig)
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, configFileName)
+
+	config, err := loadConfig(configFile, "eol")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"services/"}, config.Include)
+	assert.Equal(t, []string{"vendor", "k8s/generated/*"}, config.Exclude)
+	assert.Equal(t, []string{"false"}, config.options["unpinned"])
+	assert.Equal(t, []string{"<1.12"}, config.options["tag-semver"])
+	assert.Equal(t, []string{"*.corp.example.com"}, config.options["domain"])
+
+	_, err = loadConfig(configFile, "unknown")
+	assert.EqualError(t, err, "Unknown profile 'unknown' in "+configFile)
+}
+
+func TestLoadConfigRejectsUnknownSettings(t *testing.T) {
+	dir := configTestDir(t, "predicates:\n  lastest: true\n")
+	defer os.RemoveAll(dir)
+
+	_, err := loadConfig(filepath.Join(dir, configFileName), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2: field lastest not found")
+}
+
+func TestConfigExcluded(t *testing.T) {
+	config := &projectConfig{dir: "project"}
+	config.Exclude = []string{"vendor", "k8s/generated/*", "*.tmpl.yaml"}
+
+	excluded := []string{
+		"project/vendor/Dockerfile",
+		"project/app/vendor/lib/Dockerfile",
+		"project/k8s/generated/deployment.yaml",
+		"project/k8s/app.tmpl.yaml",
+	}
+	for _, file := range excluded {
+		assert.True(t, config.excluded(file), "%s should be excluded", file)
+	}
+
+	included := []string{
+		"project/Dockerfile",
+		"project/k8s/deployment.yaml",
+		"project/app/generated/deployment.yaml",
+		"other/k8s/generated/deployment.yaml",
+	}
+	for _, file := range included {
+		assert.False(t, config.excluded(file), "%s should not be excluded", file)
+	}
+
+	var none *projectConfig
+	assert.False(t, none.excluded("project/vendor/Dockerfile"))
+}
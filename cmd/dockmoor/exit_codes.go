@@ -9,4 +9,7 @@ const (
 	ExitNotFound
 	ExitInvalidFormat
 	ExitCouldNotOpenFile
+	ExitCouldNotWriteFile
+	ExitResolveError
+	ExitPolicyViolation
 )
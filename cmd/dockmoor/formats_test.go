@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// formatFixtures are files of each format and files that resemble a format, with the name of the only
+// format that accepts them, or no name when no format accepts them
+var formatFixtures = []struct {
+	name    string
+	format  string
+	content string
+}{
+	{"empty", "", ``},
+	{"invalid yaml", "", "jobs: [\n"},
+
+	{"dockerfile", "Dockerfile", "FROM nginx\nRUN echo\n"},
+
+	{"compose", "docker-compose", "version: '3'\nservices:\n  web:\n    image: nginx\n"},
+	{"compose without version", "docker-compose", "services:\n  web:\n    image: nginx\n"},
+
+	{"kubernetes", "Kubernetes", "apiVersion: v1\nkind: Pod\nspec:\n  containers:\n  - image: nginx\n"},
+	{"kubernetes with helm keys", "Kubernetes", "apiVersion: v1\nkind: ConfigMap\ndata:\n  repository: nginx\n  tag: '1.19'\n"},
+
+	{"gitlab ci", "GitLab CI", "services:\n  - docker:dind\nbuild:\n  image: docker:18\n  script: docker build .\n"},
+	{"gitlab ci job", "GitLab CI", "build:\n  image: golang\n  script: go build\n"},
+	{"gitlab ci templates", "", ".build:\n  image: golang\n  script: go build\n"},
+	{"gitlab ci globals", "", "image: nginx\nstages: [build]\n"},
+
+	{"github workflow", "GitHub Actions", `name: CI
+on: [push]
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    container: golang:1.11
+    services:
+      db:
+        image: postgres:10
+    steps:
+      - uses: actions/checkout@v2
+      - uses: docker://alpine:3.8
+  reuse:
+    uses: octo-org/workflows/.github/workflows/release.yml@main
+`},
+	{"github workflow without images", "GitHub Actions", "on: push\njobs:\n  build:\n    runs-on: ubuntu-latest\n"},
+	{"github workflow without on", "", "jobs:\n  build:\n    runs-on: ubuntu-latest\n"},
+	{"github workflow jobs list", "", "on: push\njobs:\n  - build\n"},
+
+	{"circleci", "CircleCI", `version: 2.1
+orbs:
+  node: circleci/node@4.1
+executors:
+  go:
+    docker:
+      - image: cimg/go:1.15   # primary
+      - image: "postgres:10"
+        environment:
+          POSTGRES_USER: test
+jobs:
+  build:
+    executor: go
+    steps: [checkout]
+  test:
+    parameters:
+      image:
+        type: string
+        default: cimg/base:2020.01
+      version:
+        type: string
+        default: "1.0"
+    docker:
+      - image: << parameters.image >>
+      - image: &redis redis:5
+    steps: [checkout]
+  lint:
+    executor:
+      name: node/default
+      image: cimg/node:14.15
+    steps: [checkout]
+  cache:
+    docker:
+      - image: *redis
+    steps: [checkout]
+workflows:
+  main:
+    jobs:
+      - build
+      - node/test:
+          executor:
+            name: node/default
+            image: cimg/node:12.20
+          base-image: alpine:3.8
+          version: "12"
+`},
+	{"circleci without version", "", "jobs:\n  build:\n    docker:\n      - image: golang\n"},
+	{"circleci without jobs", "", "version: 2.1\nsteps: []\n"},
+
+	{"travis ci", "Travis CI", `language: go
+services:
+  - docker
+env:
+  global:
+    - DB_IMAGE=postgres:10 CACHE_IMAGE="redis:5"
+    - UNUSED=alpine:3.8
+before_install:
+  - docker pull $DB_IMAGE
+  - docker pull ${CACHE_IMAGE} && docker pull -q golang:1.11
+script:
+  - go test ./...
+jobs:
+  include:
+    - env: LINT_IMAGE=golangci/golangci-lint:v1.21
+      script: docker pull "$LINT_IMAGE"
+`},
+	{"travis ci jobs", "Travis CI", "language: go\njobs:\n  include:\n    - script: make\n"},
+	{"travis ci without language", "", "script: make\n"},
+	{"travis ci without scripts", "", "language: go\n"},
+	{"travis ci language mapping", "", "language:\n  name: go\nscript: make\n"},
+
+	{"helm values", "Helm", `replicaCount: 1
+image:
+  repository: nginx   # web server
+  tag: 1.19.2
+  pullPolicy: IfNotPresent
+metrics:
+  image:
+    registry: docker.io
+    repository: bitnami/nginx-exporter
+    tag: "0.8.0"
+    digest: ""
+sidecars:
+  - name: proxy
+    image: &proxy
+      repository: envoyproxy/envoy
+      tag: v1.16.0
+  - name: copy
+    image: *proxy
+init:
+  image:
+    repository: busybox
+    tag: ""
+`},
+	{"helm values with services", "Helm", "services:\n  api:\n    image:\n      repository: x\n      tag: \"1\"\n"},
+	{"helm values with resources", "Helm", "image:\n  repository: nginx\n  tag: 1.19.2\nresources: {}\n"},
+	{"compose with helm images", "docker-compose", "services:\n  api:\n    image:\n      repository: x\n      tag: \"1\"\n  web:\n    build: .\n"},
+	{"helm values without repository", "", "image:\n  name: nginx\n  tag: 1.19.2\n"},
+	{"helm values without tag", "", "image:\n  repository: nginx\n"},
+
+	{"kustomization", "Kustomize", `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - ../base
+images:
+  - name: nginx
+    newTag: 1.19.2   # web server
+  - name: postgres
+    newName: my.registry/postgres
+    newTag: "12.4"
+    digest: sha256:2a03a6059f21e150ae84b0973863609494aad70f0a80eaeb64bddd8d92465812
+  - name: busybox
+    newName: my.registry/busybox
+`},
+	{"kustomize component", "Kustomize", "apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\nimages:\n  - name: nginx\n    newTag: 1.19.2\n"},
+	{"kustomization without apiVersion", "Kustomize", "namePrefix: dev-\nresources:\n  - pod.yaml\n"},
+	{"kustomization with unknown fields", "", "resources: [pod.yaml]\nscript: [make]\n"},
+	{"kustomization images mapping", "", "images:\n  newTag: 1.19.2\n"},
+	{"kustomization images without name", "", "images:\n  - newTag: 1.19.2\n"},
+}
+
+func TestFormatsIdentifyFixturesUnambiguously(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(bytes.NewBuffer(nil))
+	provider := dockfmt.DefaultFormatProvider()
+
+	for _, fixture := range formatFixtures {
+		fixture := fixture
+		t.Run(fixture.name, func(t *testing.T) {
+			format, err := dockfmt.IdentifyFormat(log, provider, strings.NewReader(fixture.content), "anything")
+			if ambiguous, ok := err.(dockfmt.AmbiguousFormatError); ok {
+				t.Fatalf("Accepted by %s and %s", ambiguous.Formats[0].Name(), ambiguous.Formats[1].Name())
+			}
+
+			name := ""
+			if format != nil {
+				name = format.Name()
+			}
+			assert.Equal(t, fixture.format, name)
+		})
+	}
+}
+
+func TestFormatsHaveFixtures(t *testing.T) {
+	fixtureFormats := make(map[string]bool)
+	for _, fixture := range formatFixtures {
+		fixtureFormats[fixture.format] = true
+	}
+
+	for _, format := range dockfmt.DefaultFormatProvider().Formats() {
+		assert.True(t, fixtureFormats[format.Name()], "No fixture of %s", format.Name())
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrSingleInputFile = errors.New("Provide exactly one input file")
+
+// inputFile is a file to process. Explicit files are named on the command line, the others were found
+// in directories or by globs and are skipped when no format accepts them
+type inputFile struct {
+	path     string
+	explicit bool
+}
+
+// configDirectories are hidden directories that contain files of supported formats, e.g. .github/workflows or .circleci
+var configDirectories = map[string]bool{
+	".github":   true,
+	".circleci": true,
+}
+
+// expandInputFiles resolves the arguments to files: directories are searched recursively, skipping hidden
+// directories like .git except for configDirectories, and globs like *.yaml are expanded. Other arguments, including - for stdin, are
+// taken as they are
+func expandInputFiles(arguments []string) ([]inputFile, error) {
+	var result *multierror.Error
+	files := make([]inputFile, 0, len(arguments))
+	for _, argument := range arguments {
+		if argument == "-" {
+			files = append(files, inputFile{path: argument, explicit: true})
+			continue
+		}
+
+		if _, err := os.Stat(argument); err != nil && isGlob(argument) {
+			matches, err := filepath.Glob(argument)
+			if err != nil {
+				result = multierror.Append(result, errors.Wrapf(err, "Invalid pattern '%s'", argument))
+				continue
+			}
+			if len(matches) == 0 {
+				result = multierror.Append(result, errors.Errorf("No files match '%s'", argument))
+				continue
+			}
+
+			for _, match := range matches {
+				found, err := filesOf(match, false)
+				result = multierror.Append(result, err)
+				files = append(files, found...)
+			}
+			continue
+		}
+
+		found, err := filesOf(argument, true)
+		result = multierror.Append(result, err)
+		files = append(files, found...)
+	}
+
+	return files, result.ErrorOrNil()
+}
+
+func isGlob(argument string) bool {
+	return strings.ContainsAny(argument, "*?[")
+}
+
+// filesOf returns the path itself unless it is a directory, whose files are returned instead
+func filesOf(path string, explicit bool) ([]inputFile, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		// files that cannot be read are reported when they are opened
+		return []inputFile{{path: path, explicit: explicit}}, nil
+	}
+
+	files := make([]inputFile, 0)
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if file != path && strings.HasPrefix(info.Name(), ".") && !configDirectories[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.Mode().IsRegular() {
+			files = append(files, inputFile{path: file})
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+func verifySingleInputFile(mopts *MatchingOptions) error {
+	if len(mopts.Positional.InputFiles) > 0 {
+		return ErrSingleInputFile
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func inputFilesTestDir(t *testing.T, files ...string) string {
+	dir, err := ioutil.TempDir("", "dockmoor")
+	assert.Nil(t, err)
+
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0777))
+		assert.Nil(t, ioutil.WriteFile(path, []byte("FROM nginx\n"), 0666))
+	}
+	return dir
+}
+
+func TestExpandInputFilesSearchesDirectories(t *testing.T) {
+	dir := inputFilesTestDir(t, "Dockerfile", "app/Dockerfile", "app/compose.yml", ".git/config")
+	defer os.RemoveAll(dir)
+
+	files, err := expandInputFiles([]string{dir})
+	assert.Nil(t, err)
+	assert.Equal(t, []inputFile{
+		{path: filepath.Join(dir, "Dockerfile")},
+		{path: filepath.Join(dir, "app", "Dockerfile")},
+		{path: filepath.Join(dir, "app", "compose.yml")},
+	}, files)
+}
+
+func TestExpandInputFilesExpandsGlobs(t *testing.T) {
+	dir := inputFilesTestDir(t, "a.yml", "b.yml", "c.txt")
+	defer os.RemoveAll(dir)
+
+	files, err := expandInputFiles([]string{filepath.Join(dir, "*.yml"), "-"})
+	assert.Nil(t, err)
+	assert.Equal(t, []inputFile{
+		{path: filepath.Join(dir, "a.yml")},
+		{path: filepath.Join(dir, "b.yml")},
+		{path: "-", explicit: true},
+	}, files)
+}
+
+func TestExpandInputFilesKeepsExplicitFiles(t *testing.T) {
+	files, err := expandInputFiles([]string{"does-not-exist", "Dockerfile"})
+	assert.Nil(t, err)
+	assert.Equal(t, []inputFile{
+		{path: "does-not-exist", explicit: true},
+		{path: "Dockerfile", explicit: true},
+	}, files)
+}
+
+func TestExpandInputFilesReportsGlobsWithoutMatches(t *testing.T) {
+	dir := inputFilesTestDir(t, "a.yml")
+	defer os.RemoveAll(dir)
+
+	files, err := expandInputFiles([]string{filepath.Join(dir, "*.json"), filepath.Join(dir, "a.yml")})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No files match")
+	assert.Equal(t, []inputFile{{path: filepath.Join(dir, "a.yml"), explicit: true}}, files)
+}
@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/dockres"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jessevdk/go-flags"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 type MatchingMode int
@@ -20,9 +25,9 @@ const (
 )
 
 
-var domainPredicateNames = []string{"domains"}
-var namePredicateNames = []string{"names"}
-var tagPredicateNames = []string{"latest", "outdated", "untagged", "tags"}
+var domainPredicateNames = []string{"domains", "domain-regex"}
+var namePredicateNames = []string{"names", "name-regex"}
+var tagPredicateNames = []string{"latest", "outdated", "untagged", "tags", "tag-regex", "tag-semver"}
 var digestPredicateNames = []string{"digests", "unpinned"}
 
 var predicateGroups = map[string][]string{
@@ -47,6 +52,9 @@ var (
 	ErrAtMostOneDigestPredicate = errors.Errorf("Provide at most one of --" + strings.Join(digestPredicateNames, ", --"))
 )
 
+var ErrOfflineAndRefresh = errors.New("Provide at most one of --offline, --refresh")
+var ErrCacheOptionsWithoutCache = errors.New("Provide --offline, --refresh and --cache-ttl only with a cached resolver, i.e. --resolver registry")
+
 var ErrAtMostOnePredicate = map[string]error {
 	"domain": ErrAtMostOneDomainPredicate,
 	"name": ErrAtMostOneNamePredicate,
@@ -56,18 +64,23 @@ var ErrAtMostOnePredicate = map[string]error {
 
 type MatchingOptions struct {
 	DomainPredicates struct {
-		Domains []string `required:"no" long:"domain" description:"Matches all images matching one of the specified domains" hidden:"true"`
+		Domains       []string `required:"no" long:"domain" description:"Matches all images with one of the specified domains. Domains can be globs like *.example.com. Can be given multiple times"`
+		DomainRegexps []string `required:"no" long:"domain-regex" description:"Matches all images with a domain matching one of the specified regular expressions. Can be given multiple times"`
 	} `group:"Domain Predicates" description:"Limit matched image references depending on their domain"`
 
 	NamePredicates struct {
-		Names []string `required:"no" long:"name" description:"Matches all images matching one of the specified names" hidden:"true"`
+		Names       []string `required:"no" long:"name" description:"Matches all images with one of the specified names. Names can be globs like myorg/*, where * does not match / but ** does. Can be given multiple times"`
+		NameRegexps []string `required:"no" long:"name-regex" description:"Matches all images with a name matching one of the specified regular expressions. Can be given multiple times"`
 	} `group:"Name Predicates" description:"Limit matched image references depending on their name"`
 
 	TagPredicates struct {
-		Untagged bool     `required:"no" long:"untagged" description:"Matches images with no tag"`
-		Latest   bool     `required:"no" long:"latest" description:"Matches images with latest or no tag"`
-		Outdated bool     `required:"no" long:"outdated" description:"Matches all images with newer versions available" hidden:"true"`
-		Tags     []string `required:"no" long:"tag" description:"Matches all images matching one of the specified tags" hidden:"true"`
+		Untagged   bool     `required:"no" long:"untagged" description:"Matches images with no tag"`
+		Latest     bool     `required:"no" long:"latest" description:"Matches images with latest or no tag"`
+		Outdated   bool     `required:"no" long:"outdated" description:"Matches all images with newer versions available"`
+		Tags       []string `required:"no" long:"tag" description:"Matches all images with one of the specified tags. Tags can be globs like 1.*-alpine. Can be given multiple times"`
+		TagRegexps []string `required:"no" long:"tag-regex" description:"Matches all images with a tag matching one of the specified regular expressions, e.g. '^\\d+\\.\\d+-alpine$'. Can be given multiple times"`
+		TagSemver  string   `required:"no" long:"tag-semver" description:"Matches all images with a tag that is a semantic version within the range, e.g. '<1.12 || >=2.0.0-0'. Tags like v1.11 and 1.11.5-alpine are semantic versions"`
+		NonSemver  string   `required:"no" long:"non-semver" description:"How --tag-semver treats tags that are no semantic version, like latest or no tag" choice:"no-match" choice:"match" choice:"error" default:"no-match"`
 	} `group:"Tag Predicates" description:"Limit matched image references depending on their tag"`
 
 	DigestPredicates struct {
@@ -75,14 +88,64 @@ type MatchingOptions struct {
 		Digests  []string `required:"no" long:"digest" description:"Matches all digests matching one of the specified digests" hidden:"true"`
 	} `group:"Digest Predicates" description:"Limit matched image references depending on their digest"`
 
+	ExpressionPredicates struct {
+		Where string `required:"no" long:"where" description:"Matches images satisfying the expression, e.g. '(unpinned or latest) and not domain(\"internal.example.com\")'. The predicates unpinned, latest, untagged, outdated, domain(...), name(...), tag(...), digest(...), domain-regex(...), name-regex(...) and tag-regex(...) are combined with and, or, not and parentheses"`
+	} `group:"Expression Predicates" description:"Combine predicates freely, the expression must be satisfied in addition to the predicates above"`
+
+	FormatOptions struct {
+		KubernetesImagePaths []string `required:"no" long:"k8s-image-path" description:"JSONPath that selects images in Kubernetes resources without pod spec, e.g. {.spec.steps[*].image} for a custom resource. Can be given multiple times"`
+		BuildArgs            []string `required:"no" long:"build-arg" description:"Set the value of an ARG used in FROM instructions of Dockerfiles, like docker build --build-arg KEY=VALUE. Can be given multiple times"`
+		MatchActions         bool     `required:"no" long:"match-actions" description:"Match actions used in GitHub workflows like actions/checkout@v2 as image references github.com/actions/checkout:v2. Actions pinned to a commit have the digest sha1:<commit>"`
+		HelmKeys             []string `required:"no" long:"helm-key" description:"Additional key of a field of images in Helm chart values, given like FIELD=KEY, e.g. tag=version. Fields are registry, repository, tag and digest. Can be given multiple times"`
+		KustomizeResources   bool     `required:"no" long:"kustomize-resources" description:"Follow the resources of kustomization files to find the effective images of overlays, including images of bases and manifests that are not overridden by images"`
+		TravisDockerPull     bool     `required:"no" long:"travis-docker-pull" description:"Find images of docker pull commands in the scripts of .travis.yml. Images given by variables, like docker pull $IMAGE, are found where the variable is assigned in env"`
+		ComposeBuildArgs     bool     `required:"no" long:"compose-build-args" description:"Find images in build args of docker-compose services named like *IMAGE, e.g. BASE_IMAGE"`
+	} `group:"Format Options" description:"Control where image references are found"`
+
+	ResolverOptions struct {
+		Resolver string        `required:"no" long:"resolver" description:"Where to look up digests and tags: the local docker daemon or the registry of the image. Only the results of registry are cached" choice:"dockerd" choice:"registry" default:"dockerd"`
+		CacheTTL time.Duration `required:"no" long:"cache-ttl" description:"How long digests and tags looked up by the registry resolver are cached, e.g. 30m or 24h (default: 1h)"`
+		Offline  bool          `required:"no" long:"offline" description:"Look up digests and tags only in the cache of the registry resolver"`
+		Refresh  bool          `required:"no" long:"refresh" description:"Look up digests and tags with the registry resolver even when they are cached, the results are cached again"`
+		Timeout  time.Duration `required:"no" long:"registry-timeout" description:"How long a request to a registry may take, e.g. 10s or 1m" default:"30s"`
+	} `group:"Resolver Options" description:"Control how digests and tags of images are looked up"`
+
 	Positional struct {
-		InputFile flags.Filename `required:"yes"`
+		InputFile  flags.Filename `required:"yes"`
+		InputFiles []flags.Filename
 	} `positional-args:"yes"`
 
-	mainOpts *mainOptions
-	mode     MatchingMode
+	mainOpts         *mainOptions
+	mode             MatchingMode
+	listOpts         *ListOptions
+	output           *string
+	report           *sarifReport
+	predicates       []namedPredicate
+	nonSemverErrors  *multierror.Error
+	resolverInstance dockres.Resolver
+	formatsInstance  dockfmt.FormatProvider
+}
+
+var resolverFactories = map[string]func(mopts *MatchingOptions) (dockres.Resolver, error){
+	"dockerd": func(mopts *MatchingOptions) (dockres.Resolver, error) {
+		return dockres.DockerdResolverNew()
+	},
+	"registry": func(mopts *MatchingOptions) (dockres.Resolver, error) {
+		return dockres.RegistryResolverNew(mopts.ResolverOptions.Timeout)
+	},
 }
 
+// cachedResolvers are the resolvers whose results are cached on disk. The docker daemon is local and
+// its images change with every pull, so it is always asked
+var cachedResolvers = map[string]bool{
+	"registry": true,
+}
+
+// defaultCacheTTL is how long results of cached resolvers are used when --cache-ttl is not given
+const defaultCacheTTL = time.Hour
+
+var cacheDirectory = dockres.CacheDirectory
+
 func (mopts *MatchingOptions) mainOptions() *mainOptions {
 	return mopts.mainOpts
 }
@@ -95,6 +158,98 @@ func (mopts *MatchingOptions) Stdout() io.Writer {
 	return mopts.mainOptions().stdout
 }
 
+func (mopts *MatchingOptions) resolver() (dockres.Resolver, error) {
+	if mopts.resolverInstance != nil {
+		return mopts.resolverInstance, nil
+	}
+
+	name := mopts.resolverName()
+	resolverFactory := resolverFactories[name]
+	if resolverFactory == nil {
+		return nil, errors.Errorf("Unknown resolver '%s'", name)
+	}
+
+	resolver, err := resolverFactory(mopts)
+	if err != nil {
+		return nil, err
+	}
+
+	if cachedResolvers[name] {
+		dir, err := cacheDirectory()
+		if err != nil {
+			return nil, err
+		}
+		ttl := mopts.ResolverOptions.CacheTTL
+		if ttl == 0 {
+			ttl = defaultCacheTTL
+		}
+		resolver = dockres.CachedResolverNew(resolver, filepath.Join(dir, name), ttl, mopts.cacheMode())
+	}
+
+	mopts.resolverInstance = resolver
+	return resolver, nil
+}
+
+func (mopts *MatchingOptions) resolverName() string {
+	if mopts.ResolverOptions.Resolver == "" {
+		return "dockerd"
+	}
+	return mopts.ResolverOptions.Resolver
+}
+
+// usesCacheOptions reports whether any option of the cache of resolvers is given
+func (mopts *MatchingOptions) usesCacheOptions() bool {
+	ro := mopts.ResolverOptions
+	return ro.Offline || ro.Refresh || ro.CacheTTL != 0
+}
+
+func (mopts *MatchingOptions) cacheMode() dockres.CacheMode {
+	switch {
+	case mopts.ResolverOptions.Offline:
+		return dockres.CacheOffline
+	case mopts.ResolverOptions.Refresh:
+		return dockres.CacheRefresh
+	default:
+		return dockres.CacheUse
+	}
+}
+
+var _ dockproc.TagSource = (*resolverTagSource)(nil)
+
+// resolverTagSource lists tags using the configured resolver, asking at most once per repository
+type resolverTagSource struct {
+	mopts *MatchingOptions
+	tags  map[string][]string
+}
+
+func (source *resolverTagSource) ListTags(ref dockref.Reference) ([]string, error) {
+	if tags, ok := source.tags[ref.Name()]; ok {
+		return tags, nil
+	}
+
+	resolver, err := source.mopts.resolver()
+	if err != nil {
+		source.mopts.Log().Errorf("Could not create resolver: %s", err.Error())
+		return nil, err
+	}
+
+	tags, err := resolver.ListTags(ref)
+	if err != nil {
+		source.mopts.Log().Errorf("Could not list tags of '%s': %s", ref.Original(), err.Error())
+		return nil, err
+	}
+
+	source.tags[ref.Name()] = tags
+	return tags, nil
+}
+
+func (mopts *MatchingOptions) tagSource() dockproc.TagSource {
+	return &resolverTagSource{
+		mopts: mopts,
+		tags:  make(map[string][]string),
+	}
+}
+
 type GroupCount struct {
 	countDomain, countName, countTag, countDigest int
 }
@@ -112,6 +267,9 @@ func calculateDomainCounts(options *MatchingOptions) (count int) {
 	if options.DomainPredicates.Domains != nil {
 		count++
 	}
+	if options.DomainPredicates.DomainRegexps != nil {
+		count++
+	}
 	return
 }
 
@@ -119,6 +277,9 @@ func calculateNameCounts(options *MatchingOptions) (count int) {
 	if options.NamePredicates.Names != nil {
 		count++
 	}
+	if options.NamePredicates.NameRegexps != nil {
+		count++
+	}
 	return
 }
 
@@ -126,6 +287,12 @@ func calculateTagCounts(options *MatchingOptions) (count int) {
 	if options.TagPredicates.Tags != nil {
 		count++
 	}
+	if options.TagPredicates.TagRegexps != nil {
+		count++
+	}
+	if options.TagPredicates.TagSemver != "" {
+		count++
+	}
 	if options.TagPredicates.Untagged {
 		count++
 	}
@@ -152,15 +319,12 @@ func verifyMatchOptionsAtMostOnePredicatePerGroup(fo *MatchingOptions) error {
 
 	counts := calculateCounts(fo)
 
-	// domain and name cannot have more than one predicate set
-	// as there is only one
-
-	//if counts.countDomain > 1 {
-	//	return ErrAtMostOneDomainPredicate
-	//}
-	//if counts.countName > 1 {
-	//	return ErrAtMostOneNamePredicate
-	//}
+	if counts.countDomain > 1 {
+		return ErrAtMostOneDomainPredicate
+	}
+	if counts.countName > 1 {
+		return ErrAtMostOneNamePredicate
+	}
 
 	if counts.countTag > 1 {
 		return ErrAtMostOneTagPredicate
@@ -174,6 +338,20 @@ func verifyMatchOptionsAtMostOnePredicatePerGroup(fo *MatchingOptions) error {
 
 func verifyMatchOptions(fo *MatchingOptions) error {
 	err := verifyMatchOptionsAtMostOnePredicatePerGroup(fo)
+	if err != nil {
+		return err
+	}
+
+	if fo.ResolverOptions.Offline && fo.ResolverOptions.Refresh {
+		return ErrOfflineAndRefresh
+	}
+
+	if fo.usesCacheOptions() && !cachedResolvers[fo.resolverName()] {
+		return ErrCacheOptionsWithoutCache
+	}
+
+	// reports invalid patterns and expressions
+	_, err = fo.createPredicates()
 	return err
 }
 
@@ -217,20 +395,36 @@ var anyPredicateFactory = func () dockproc.Predicate {
 	return dockproc.AnyPredicateNew()
 }
 
-var domainsPredicateFactory = func(domains []string) dockproc.Predicate {
-	return dockproc.DomainsPredicateNew(domains)
+var domainsPredicateFactory = func(domains []string) (dockproc.Predicate, error) {
+	return dockproc.DomainPatternsPredicateNew(domains)
+}
+var domainRegexpsPredicateFactory = func(expressions []string) (dockproc.Predicate, error) {
+	return dockproc.DomainRegexpsPredicateNew(expressions)
+}
+var namePredicateFactory = func(names []string) (dockproc.Predicate, error) {
+	return dockproc.NamePatternsPredicateNew(names)
 }
-var namePredicateFactory = func(names []string) dockproc.Predicate {
-	return dockproc.NamesPredicateNew(names)
+var nameRegexpsPredicateFactory = func(expressions []string) (dockproc.Predicate, error) {
+	return dockproc.NameRegexpsPredicateNew(expressions)
+}
+var outdatedPredicateFactory = func(tagSource dockproc.TagSource) dockproc.Predicate {
+	return dockproc.OutdatedPredicateNew(tagSource)
 }
-//var outdatedPredicateFactory = func() dockproc.Predicate {
-//	return dockproc.OutdatedPredicateNew()
-//}
 var untaggedPredicateFactory = func() dockproc.Predicate {
 	return dockproc.UntaggedPredicateNew()
 }
-var tagsPredicateFactory = func(tags []string) dockproc.Predicate {
-	return dockproc.TagsPredicateNew(tags)
+var tagsPredicateFactory = func(tags []string) (dockproc.Predicate, error) {
+	return dockproc.TagPatternsPredicateNew(tags)
+}
+var tagRegexpsPredicateFactory = func(expressions []string) (dockproc.Predicate, error) {
+	return dockproc.TagRegexpsPredicateNew(expressions)
+}
+var semverPredicateFactory = func(versionRange string, nonSemver dockproc.NonSemverHandler) (dockproc.Predicate, error) {
+	parsed, err := dockref.ParseVersionRange(versionRange)
+	if err != nil {
+		return nil, err
+	}
+	return dockproc.SemverPredicateNew(parsed, nonSemver), nil
 }
 var digestsPredicateFactory = func(digests []string) dockproc.Predicate {
 	return dockproc.DigestsPredicateNew(digests)
@@ -238,49 +432,203 @@ var digestsPredicateFactory = func(digests []string) dockproc.Predicate {
 var andPredicateFactory = func(predicates []dockproc.Predicate) dockproc.Predicate {
 	return dockproc.AndPredicateNew(predicates)
 }
+var notPredicateFactory = func(predicate dockproc.Predicate) dockproc.Predicate {
+	return dockproc.NotPredicateNew(predicate)
+}
 
-func (mopts *MatchingOptions) getPredicate() dockproc.Predicate {
-	anyPredicate := anyPredicateFactory()
-	var predicates []dockproc.Predicate
+// namedPredicate is a predicate selected by a command line option, the name identifies the option
+type namedPredicate struct {
+	name      string
+	predicate dockproc.Predicate
+}
+
+// namedPredicates returns the predicates selected by the options. They are created once, so patterns are
+// compiled and tags are looked up only once for all files
+func (mopts *MatchingOptions) namedPredicates() ([]namedPredicate, error) {
+	if mopts.predicates != nil {
+		return mopts.predicates, nil
+	}
+
+	predicates, err := mopts.createPredicates()
+	if err != nil {
+		return nil, err
+	}
+	mopts.predicates = predicates
+	return predicates, nil
+}
+
+func (mopts *MatchingOptions) createPredicates() ([]namedPredicate, error) {
+	predicates := make([]namedPredicate, 0)
+	var result *multierror.Error
+
+	add := func(name string, p dockproc.Predicate, err error) {
+		if err != nil {
+			result = multierror.Append(result, err)
+			return
+		}
+		predicates = append(predicates, namedPredicate{name, p})
+	}
 
 	if mopts.DomainPredicates.Domains != nil {
-		p := domainsPredicateFactory(mopts.DomainPredicates.Domains)
-		predicates = append(predicates, p)
+		p, err := domainsPredicateFactory(mopts.DomainPredicates.Domains)
+		add("domain", p, err)
+	}
+
+	if mopts.DomainPredicates.DomainRegexps != nil {
+		p, err := domainRegexpsPredicateFactory(mopts.DomainPredicates.DomainRegexps)
+		add("domain-regex", p, err)
 	}
 
 	if mopts.NamePredicates.Names != nil {
-		p := namePredicateFactory(mopts.NamePredicates.Names)
-		predicates = append(predicates, p)
+		p, err := namePredicateFactory(mopts.NamePredicates.Names)
+		add("name", p, err)
 	}
 
-	//if mopts.TagPredicates.Outdated {
-	//	p := outdatedPredicateFactory()
-	//	predicates = append(predicates, p)
-	//}
+	if mopts.NamePredicates.NameRegexps != nil {
+		p, err := nameRegexpsPredicateFactory(mopts.NamePredicates.NameRegexps)
+		add("name-regex", p, err)
+	}
+
+	if mopts.TagPredicates.Outdated {
+		p := outdatedPredicateFactory(mopts.tagSource())
+		add("outdated", p, nil)
+	}
 
 	if mopts.TagPredicates.Untagged {
 		p := untaggedPredicateFactory()
-		predicates = append(predicates, p)
+		add("untagged", p, nil)
 	}
 
 	if mopts.TagPredicates.Tags != nil {
-		p := tagsPredicateFactory(mopts.TagPredicates.Tags)
-		predicates = append(predicates, p)
+		p, err := tagsPredicateFactory(mopts.TagPredicates.Tags)
+		add("tag", p, err)
+	}
+
+	if mopts.TagPredicates.TagRegexps != nil {
+		p, err := tagRegexpsPredicateFactory(mopts.TagPredicates.TagRegexps)
+		add("tag-regex", p, err)
+	}
+
+	if mopts.TagPredicates.TagSemver != "" {
+		p, err := semverPredicateFactory(mopts.TagPredicates.TagSemver, mopts.nonSemverHandler())
+		add("tag-semver", p, err)
 	}
 
 	if mopts.TagPredicates.Latest {
 		p := latestPredicateFactory()
-		predicates = append(predicates, p)
+		add("latest", p, nil)
 	}
 
 	if mopts.DigestPredicates.Unpinned {
 		p := latestUnpinnedFactory()
-		predicates = append(predicates, p)
+		add("unpinned", p, nil)
 	}
 
 	if mopts.DigestPredicates.Digests != nil {
 		p := digestsPredicateFactory(mopts.DigestPredicates.Digests)
-		predicates = append(predicates, p)
+		add("digest", p, nil)
+	}
+
+	if mopts.ExpressionPredicates.Where != "" {
+		p, err := mopts.wherePredicate()
+		add("where", p, err)
+	}
+
+	return predicates, result.ErrorOrNil()
+}
+
+func (mopts *MatchingOptions) wherePredicate() (dockproc.Predicate, error) {
+	return dockproc.ParseExpression(mopts.ExpressionPredicates.Where, mopts.expressionFactories())
+}
+
+// expressionFactories create the predicates available in --where expressions
+func (mopts *MatchingOptions) expressionFactories() map[string]dockproc.PredicateFactory {
+	withoutArgs := func(name string, factory func() dockproc.Predicate) dockproc.PredicateFactory {
+		return func(args []string) (dockproc.Predicate, error) {
+			if len(args) > 0 {
+				return nil, errors.Errorf("%s takes no arguments", name)
+			}
+			return factory(), nil
+		}
+	}
+
+	withArgs := func(name string, factory func([]string) (dockproc.Predicate, error)) dockproc.PredicateFactory {
+		return func(args []string) (dockproc.Predicate, error) {
+			if len(args) == 0 {
+				return nil, errors.Errorf("%s needs at least one argument", name)
+			}
+			return factory(args)
+		}
+	}
+
+	return map[string]dockproc.PredicateFactory{
+		"unpinned": withoutArgs("unpinned", latestUnpinnedFactory),
+		"latest":   withoutArgs("latest", latestPredicateFactory),
+		"untagged": withoutArgs("untagged", untaggedPredicateFactory),
+		"outdated": withoutArgs("outdated", func() dockproc.Predicate {
+			return outdatedPredicateFactory(mopts.tagSource())
+		}),
+		"domain": withArgs("domain", domainsPredicateFactory),
+		"name":   withArgs("name", namePredicateFactory),
+		"tag":    withArgs("tag", tagsPredicateFactory),
+		"digest": withArgs("digest", func(digests []string) (dockproc.Predicate, error) {
+			return digestsPredicateFactory(digests), nil
+		}),
+		"domain-regex": withArgs("domain-regex", domainRegexpsPredicateFactory),
+		"name-regex":   withArgs("name-regex", nameRegexpsPredicateFactory),
+		"tag-regex":    withArgs("tag-regex", tagRegexpsPredicateFactory),
+		"tag-semver": withArgs("tag-semver", func(ranges []string) (dockproc.Predicate, error) {
+			return semverPredicateFactory(strings.Join(ranges, " || "), mopts.nonSemverHandler())
+		}),
+	}
+}
+
+// nonSemverHandler decides how --tag-semver treats tags that are no semantic version.
+// With --non-semver error, the image references are collected and reported by takeNonSemverErrors
+func (mopts *MatchingOptions) nonSemverHandler() dockproc.NonSemverHandler {
+	switch mopts.TagPredicates.NonSemver {
+	case "match":
+		return func(ref dockref.Reference) bool {
+			return true
+		}
+	case "error":
+		return func(ref dockref.Reference) bool {
+			message := fmt.Sprintf("Tag of %s is not a semantic version", ref.Original())
+			if location, ok := dockfmt.LocationOf(ref); ok {
+				message = location.String() + ": " + message
+			}
+			mopts.nonSemverErrors = multierror.Append(mopts.nonSemverErrors, errors.New(message))
+			return false
+		}
+	default:
+		return func(ref dockref.Reference) bool {
+			return false
+		}
+	}
+}
+
+// takeNonSemverErrors returns the image references with tags that are no semantic version found since the last call
+func (mopts *MatchingOptions) takeNonSemverErrors() error {
+	err := mopts.nonSemverErrors.ErrorOrNil()
+	mopts.nonSemverErrors = nil
+	return err
+}
+
+func (mopts *MatchingOptions) getPredicate() dockproc.Predicate {
+	predicates, err := mopts.namedPredicates()
+	if err != nil {
+		// the options are verified before, but never match everything by accident
+		return notPredicateFactory(anyPredicateFactory())
+	}
+	return predicateOf(predicates)
+}
+
+// predicateOf combines the predicates, matching all image references if there are none
+func predicateOf(namedPredicates []namedPredicate) dockproc.Predicate {
+	anyPredicate := anyPredicateFactory()
+	var predicates []dockproc.Predicate
+	for _, named := range namedPredicates {
+		predicates = append(predicates, named.predicate)
 	}
 
 	switch len(predicates) {
@@ -306,10 +654,153 @@ func saveClose(log *logrus.Logger, readCloser io.Closer) {
 	}
 }
 
+// buildArgs parses KEY=VALUE pairs. Like docker build, the value of KEY without value is taken from the environment
+func buildArgs(pairs []string) map[string]string {
+	args := make(map[string]string)
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) == 2 {
+			args[parts[0]] = parts[1]
+		} else if value, ok := os.LookupEnv(parts[0]); ok {
+			args[parts[0]] = value
+		}
+	}
+	return args
+}
+
+func (mopts *MatchingOptions) inputArguments() []string {
+	arguments := []string{string(mopts.Positional.InputFile)}
+	for _, inputFile := range mopts.Positional.InputFiles {
+		arguments = append(arguments, string(inputFile))
+	}
+	return arguments
+}
+
+// match processes all input files. The result is ExitSuccess when any file contains a match and
+// ExitNotFound when none does. Files that cannot be opened or have an invalid format are reported, but
+// do not stop the remaining files from being processed; the first such error determines the exit code
 func (mopts *MatchingOptions) match() (exitCode ExitCode, err error) {
+	if mopts.outputFormat() == "sarif" {
+		mopts.report = sarifReportNew()
+	}
+
+	exitCode, err = mopts.matchInputFiles()
+
+	var errOutput error
+	if mopts.mode == matchAndPrint {
+		errOutput = mopts.listOpts.flush(mopts.Stdout())
+	}
+	if mopts.report != nil {
+		errOutput = mopts.report.write(mopts.Stdout())
+	}
+	if errOutput != nil {
+		mopts.Log().Errorf("Could not write output: %s", errOutput.Error())
+		err = multierror.Append(err, errOutput).ErrorOrNil()
+	}
+	return
+}
+
+// outputFormat is the value of --output of commands that print the matches
+func (mopts *MatchingOptions) outputFormat() string {
+	if mopts.output == nil {
+		return ""
+	}
+	return *mopts.output
+}
+
+func (mopts *MatchingOptions) matchInputFiles() (exitCode ExitCode, err error) {
+	return mopts.processInputFiles(mopts.matchFormatProcessor)
+}
+
+// processInputFiles processes all input files. The exit code is the first failure, ExitSuccess when
+// processFormat succeeded for any file or ExitNotFound otherwise
+func (mopts *MatchingOptions) processInputFiles(processFormat func(formatProcessor dockfmt.FormatProcessor) (ExitCode, error)) (exitCode ExitCode, err error) {
 	log := mopts.Log()
 
-	filePathInput := string(mopts.Positional.InputFile)
+	files, expandErr := expandInputFiles(mopts.inputArguments())
+	if len(files) == 1 && files[0].explicit && expandErr == nil {
+		return mopts.withFormatProcessor(files[0].path, processFormat)
+	}
+
+	// invalid format options are reported once instead of for each file
+	if _, err = mopts.formatProvider(); err != nil {
+		log.Errorf("Invalid options: %s", err.Error())
+		return ExitInvalidParams, err
+	}
+
+	var results *multierror.Error
+	failure := ExitSuccess
+	if expandErr != nil {
+		log.Errorf("Could not find input files: %s", expandErr.Error())
+		results = multierror.Append(results, expandErr)
+		failure = ExitCouldNotOpenFile
+	}
+
+	found := false
+	for _, file := range files {
+		if !file.explicit && mopts.mainOptions().config.excluded(file.path) {
+			log.Debugf("Skipping %s: excluded by configuration", file.path)
+			continue
+		}
+
+		code, err := mopts.withFormatProcessor(file.path, processFormat)
+		if _, ok := err.(dockfmt.UnknownFormatError); ok && !file.explicit {
+			log.Debugf("Skipping %s: unknown format", file.path)
+			continue
+		}
+		results = multierror.Append(results, err)
+
+		switch code {
+		case ExitSuccess:
+			found = true
+		case ExitNotFound:
+		default:
+			if code == ExitInvalidFormat {
+				log.Errorf("Invalid format of %s", file.path)
+			}
+			if failure == ExitSuccess {
+				failure = code
+			}
+		}
+	}
+
+	switch {
+	case failure != ExitSuccess:
+		exitCode = failure
+	case found:
+		exitCode = ExitSuccess
+	default:
+		exitCode = ExitNotFound
+	}
+	return exitCode, results.ErrorOrNil()
+}
+
+// formatProvider returns the formats of the main options, configured with the format options
+func (mopts *MatchingOptions) formatProvider() (dockfmt.FormatProvider, error) {
+	if mopts.formatsInstance != nil {
+		return mopts.formatsInstance, nil
+	}
+
+	options := dockfmt.Options{
+		ActionReferences:     mopts.FormatOptions.MatchActions,
+		BuildArgs:            buildArgs(mopts.FormatOptions.BuildArgs),
+		ComposeBuildArgs:     mopts.FormatOptions.ComposeBuildArgs,
+		HelmKeys:             mopts.FormatOptions.HelmKeys,
+		KubernetesImagePaths: mopts.FormatOptions.KubernetesImagePaths,
+		KustomizeResources:   mopts.FormatOptions.KustomizeResources,
+		TravisDockerPull:     mopts.FormatOptions.TravisDockerPull,
+	}
+	formatProvider, err := dockfmt.ConfiguredFormatProvider(mopts.mainOptions().FormatProvider(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	mopts.formatsInstance = formatProvider
+	return formatProvider, nil
+}
+
+func (mopts *MatchingOptions) withFormatProcessor(filePathInput string, processFormat func(formatProcessor dockfmt.FormatProcessor) (ExitCode, error)) (exitCode ExitCode, err error) {
+	log := mopts.Log()
 
 	fpInput, err := mopts.open(filePathInput)
 	defer saveClose(log, fpInput)
@@ -320,21 +811,31 @@ func (mopts *MatchingOptions) match() (exitCode ExitCode, err error) {
 		return
 	}
 
-	formatProvider := mopts.mainOptions().FormatProvider()
+	formatProvider, err := mopts.formatProvider()
+	if err != nil {
+		log.Errorf("Invalid options: %s", err.Error())
+		return ExitInvalidParams, err
+	}
+
 	fileFormat, formatError := dockfmt.IdentifyFormat(log, formatProvider, fpInput, filePathInput)
 	if fileFormat == nil {
 		return ExitInvalidFormat, formatError
 	}
 
 	formatProcessor := dockfmt.FormatProcessorNew(fileFormat, log, fpInput)
-	exitCode, err = mopts.matchFormatProcessor(formatProcessor)
+	exitCode, err = processFormat(formatProcessor)
 	return
 }
 
 func (mopts *MatchingOptions) matchFormatProcessor(formatProcessor dockfmt.FormatProcessor) (exitCode ExitCode, err error) {
 	log := mopts.Log()
 
-	predicate := mopts.getPredicate()
+	namedPredicates, err := mopts.namedPredicates()
+	if err != nil {
+		log.Errorf("Invalid options: %s", err.Error())
+		return ExitInvalidParams, err
+	}
+	predicate := predicateOf(namedPredicates)
 	accumulator, err := dockproc.MatchesAccumulatorNew(predicate, log, mopts.Stdout())
 
 	errAcc := accumulator.Accumulate(formatProcessor)
@@ -342,6 +843,11 @@ func (mopts *MatchingOptions) matchFormatProcessor(formatProcessor dockfmt.Forma
 		log.Errorf("Error during accumulation: %s", errAcc.Error())
 	}
 
+	if err := mopts.takeNonSemverErrors(); err != nil {
+		log.Errorf("Found tags that are no semantic version: %s", err.Error())
+		return ExitInvalidFormat, err
+	}
+
 	matches := accumulator.Matches()
 
 	if len(matches) > 0 {
@@ -352,11 +858,13 @@ func (mopts *MatchingOptions) matchFormatProcessor(formatProcessor dockfmt.Forma
 
 	var results *multierror.Error
 
+	if mopts.report != nil {
+		mopts.report.add(namedPredicates, matches)
+	}
+
 	if mopts.mode == matchAndPrint {
-		for _, r := range matches {
-			_, err = fmt.Fprintf(mopts.Stdout(), "%s\n", r.Original())
-			results = multierror.Append(results, err)
-		}
+		err = mopts.listOpts.print(mopts.Stdout(), formatProcessor.Format(), matches)
+		results = multierror.Append(results, err)
 	}
 	return exitCode, results.ErrorOrNil()
 }
@@ -3,9 +3,11 @@ package main
 import (
 	"fmt"
 	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/stretchr/testify/assert"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestEmptyPredicates(t *testing.T) {
@@ -26,12 +28,20 @@ func applyPredicatesByName(fo *MatchingOptions, names ...string) {
 			fo.TagPredicates.Latest = true
 		case equalsAnyString("domains", name):
 			fo.DomainPredicates.Domains = []string{"a", "b"}
+		case equalsAnyString("domain-regex", name):
+			fo.DomainPredicates.DomainRegexps = []string{"^a", "b$"}
 		case equalsAnyString("names", name):
 			fo.NamePredicates.Names = []string{"a", "b"}
+		case equalsAnyString("name-regex", name):
+			fo.NamePredicates.NameRegexps = []string{"^a", "b$"}
 		case equalsAnyString("untagged", name):
 			fo.TagPredicates.Untagged = true
 		case equalsAnyString("tags", name):
 			fo.TagPredicates.Tags = []string{"a", "b"}
+		case equalsAnyString("tag-regex", name):
+			fo.TagPredicates.TagRegexps = []string{"^a", "b$"}
+		case equalsAnyString("tag-semver", name):
+			fo.TagPredicates.TagSemver = "<1.12 || >=2.0.0-0"
 		case equalsAnyString("unpinned", name):
 			fo.DigestPredicates.Unpinned = true
 		case equalsAnyString("digests", name):
@@ -244,14 +254,14 @@ func TestUntaggedPredicateWhenUntaggedSet(t *testing.T) {
 	assert.IsType(t, dockproc.UntaggedPredicateNew(), predicate)
 }
 
-//func TestOutdatedPredicateWhenOutdatedSet(t *testing.T) {
-//	fo := &MatchingOptions{}
-//	fo.TagPredicates.Outdated = true
-//
-//	predicate := fo.getPredicate()
-//
-//	assert.IsType(t, dockproc.OutdatedPredicateNew(), predicate)
-//}
+func TestOutdatedPredicateWhenOutdatedSet(t *testing.T) {
+	fo := &MatchingOptions{}
+	fo.TagPredicates.Outdated = true
+
+	predicate := fo.getPredicate()
+
+	assert.IsType(t, dockproc.OutdatedPredicateNew(nil), predicate)
+}
 
 func TestLatestPredicateWhenLatestSet(t *testing.T) {
 	fo := &MatchingOptions{}
@@ -307,3 +317,83 @@ func TestAndPredicateWhenUnpinnedAndLatestSet(t *testing.T) {
 
 	assert.Equal(t, 2, matches)
 }
+
+func TestInvalidPatternsFailVerification(t *testing.T) {
+	fo := &MatchingOptions{}
+	fo.NamePredicates.Names = []string{"myorg/[a-z"}
+	err := verifyMatchOptions(fo)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid glob 'myorg/[a-z': missing ]")
+
+	fo = &MatchingOptions{}
+	fo.TagPredicates.TagRegexps = []string{`^(\d+`}
+	err = verifyMatchOptions(fo)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid regular expression '^(\\d+'")
+}
+
+func TestCacheOptionsRequireCachedResolver(t *testing.T) {
+	cacheOptions := map[string]func(fo *MatchingOptions){
+		"offline":   func(fo *MatchingOptions) { fo.ResolverOptions.Offline = true },
+		"refresh":   func(fo *MatchingOptions) { fo.ResolverOptions.Refresh = true },
+		"cache-ttl": func(fo *MatchingOptions) { fo.ResolverOptions.CacheTTL = time.Minute },
+	}
+
+	for name, setOption := range cacheOptions {
+		fo := &MatchingOptions{}
+		setOption(fo)
+		assert.Equal(t, ErrCacheOptionsWithoutCache, verifyMatchOptions(fo), name)
+
+		fo.ResolverOptions.Resolver = "dockerd"
+		assert.Equal(t, ErrCacheOptionsWithoutCache, verifyMatchOptions(fo), name)
+
+		fo.ResolverOptions.Resolver = "registry"
+		assert.Nil(t, verifyMatchOptions(fo), name)
+	}
+}
+
+func TestGlobsSelectPatternPredicates(t *testing.T) {
+	fo := &MatchingOptions{}
+	fo.NamePredicates.Names = []string{"myorg/*"}
+	fo.DomainPredicates.Domains = []string{"*.corp.example.com"}
+
+	predicates, err := fo.namedPredicates()
+	assert.Nil(t, err)
+	assert.Len(t, predicates, 2)
+
+	ref, _ := dockref.FromOriginal("registry.corp.example.com/myorg/app:1.0")
+	assert.True(t, fo.getPredicate().Matches(ref))
+	ref, _ = dockref.FromOriginal("registry.corp.example.com/otherorg/app:1.0")
+	assert.False(t, fo.getPredicate().Matches(ref))
+}
+
+func TestNonSemverErrorsAreCollected(t *testing.T) {
+	fo := &MatchingOptions{}
+	fo.TagPredicates.TagSemver = ">=1.12"
+	fo.TagPredicates.NonSemver = "error"
+
+	predicate := fo.getPredicate()
+	for _, original := range []string{"golang:1.12", "golang:latest", "golang"} {
+		ref, _ := dockref.FromOriginal(original)
+		predicate.Matches(ref)
+	}
+
+	err := fo.takeNonSemverErrors()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Tag of golang:latest is not a semantic version")
+	assert.Contains(t, err.Error(), "Tag of golang is not a semantic version")
+	assert.Nil(t, fo.takeNonSemverErrors())
+}
+
+func TestNonSemverTagsMatchWhenRequested(t *testing.T) {
+	fo := &MatchingOptions{}
+	fo.TagPredicates.TagSemver = ">=1.12"
+
+	ref, _ := dockref.FromOriginal("golang:latest")
+	assert.False(t, fo.getPredicate().Matches(ref))
+
+	fo = &MatchingOptions{}
+	fo.TagPredicates.TagSemver = ">=1.12"
+	fo.TagPredicates.NonSemver = "match"
+	assert.True(t, fo.getPredicate().Matches(ref))
+}
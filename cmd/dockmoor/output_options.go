@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/jessevdk/go-flags"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+var (
+	ErrOutputAndInPlace = errors.New("Provide at most one of --output-file, --in-place")
+	ErrInPlaceStdin     = errors.New("Cannot use --in-place when reading from stdin")
+)
+
+// OutputOptions control where commands that rewrite their input write the result to
+type OutputOptions struct {
+	OutputFile flags.Filename `required:"no" short:"o" long:"output-file" description:"Write the result to the given path instead of stdout"`
+	InPlace    bool           `required:"no" short:"i" long:"in-place" description:"Replace the input file with the result"`
+}
+
+func verifyOutputOptions(oo *OutputOptions, inputFile flags.Filename) error {
+	if oo.InPlace && oo.OutputFile != "" {
+		return ErrOutputAndInPlace
+	}
+
+	if oo.InPlace && inputFile == "-" {
+		return ErrInPlaceStdin
+	}
+
+	return nil
+}
+
+func (oo *OutputOptions) writeOutput(stdout io.Writer, inputFile flags.Filename, content []byte) error {
+	outputPath := string(oo.OutputFile)
+	if oo.InPlace {
+		outputPath = string(inputFile)
+	}
+
+	if outputPath == "" || outputPath == "-" {
+		_, err := stdout.Write(content)
+		return err
+	}
+
+	mode := os.FileMode(0666)
+	if info, err := os.Stat(outputPath); err == nil {
+		mode = info.Mode()
+	}
+
+	return ioutil.WriteFile(outputPath, content, mode)
+}
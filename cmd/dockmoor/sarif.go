@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"io"
+	"path/filepath"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+const sarifVersion = "2.1.0"
+const informationURI = "https://github.com/MeneDev/dockmoor"
+
+// sarifRule describes the image references matched by a predicate. The message is a format for the original
+// image reference
+type sarifRule struct {
+	id          string
+	name        string
+	description string
+	message     string
+}
+
+// predicateLevel is the level of the rules of predicates
+const predicateLevel = "warning"
+
+// imageRule is used when no predicate is given and all image references match
+var imageRule = sarifRule{"image", "ImageReference", "Image reference", "Image reference %s"}
+
+var sarifRules = map[string]sarifRule{
+	"domain":       {"domain", "Domain", "Image reference with one of the given domains", "Image reference %s has one of the given domains"},
+	"name":         {"name", "Name", "Image reference with one of the given names", "Image reference %s has one of the given names"},
+	"outdated":     {"outdated", "Outdated", "Image reference with newer versions available", "Image reference %s has newer versions available"},
+	"untagged":     {"untagged", "Untagged", "Image reference without tag", "Image reference %s has no tag"},
+	"tag":          {"tag", "Tag", "Image reference with one of the given tags", "Image reference %s has one of the given tags"},
+	"latest":       {"latest", "Latest", "Image reference with latest or no tag", "Image reference %s uses the latest tag"},
+	"unpinned":     {"unpinned", "Unpinned", "Image reference not pinned to a digest", "Image reference %s is not pinned to a digest"},
+	"digest":       {"digest", "Digest", "Image reference with one of the given digests", "Image reference %s has one of the given digests"},
+	"domain-regex": {"domain-regex", "DomainRegex", "Image reference with a domain matching one of the given regular expressions", "Image reference %s has a domain matching one of the given regular expressions"},
+	"name-regex":   {"name-regex", "NameRegex", "Image reference with a name matching one of the given regular expressions", "Image reference %s has a name matching one of the given regular expressions"},
+	"tag-regex":    {"tag-regex", "TagRegex", "Image reference with a tag matching one of the given regular expressions", "Image reference %s has a tag matching one of the given regular expressions"},
+	"tag-semver":   {"tag-semver", "TagSemver", "Image reference with a tag within the given version range", "Image reference %s has a tag within the given version range"},
+	"where":        {"where", "Where", "Image reference satisfying the --where expression", "Image reference %s satisfies the --where expression"},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string                     `json:"name"`
+	Version        string                     `json:"version"`
+	InformationURI string                     `json:"informationUri"`
+	Rules          []sarifReportingDescriptor `json:"rules"`
+}
+
+type sarifReportingDescriptor struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// sarifReport collects matching image references of all input files as results of the rules of the predicates
+type sarifReport struct {
+	rules       []sarifReportingDescriptor
+	ruleIndexes map[string]int
+	results     []sarifResult
+}
+
+func sarifReportNew() *sarifReport {
+	return &sarifReport{
+		rules:       make([]sarifReportingDescriptor, 0),
+		ruleIndexes: make(map[string]int),
+		results:     make([]sarifResult, 0),
+	}
+}
+
+func (report *sarifReport) ruleIndex(rule sarifRule, level string) int {
+	if index, ok := report.ruleIndexes[rule.id]; ok {
+		return index
+	}
+
+	index := len(report.rules)
+	report.rules = append(report.rules, sarifReportingDescriptor{
+		ID:                   rule.id,
+		Name:                 rule.name,
+		ShortDescription:     sarifMessage{Text: rule.description},
+		DefaultConfiguration: sarifConfiguration{Level: level},
+	})
+	report.ruleIndexes[rule.id] = index
+	return index
+}
+
+// add adds a result for each predicate to each match. All predicates are combined with and, so each
+// match satisfies all of them
+func (report *sarifReport) add(namedPredicates []namedPredicate, matches []dockref.Reference) {
+	rules := make([]sarifRule, 0, len(namedPredicates))
+	for _, named := range namedPredicates {
+		rules = append(rules, sarifRules[named.name])
+	}
+	if len(rules) == 0 {
+		rules = append(rules, imageRule)
+	}
+
+	for _, rule := range rules {
+		report.ruleIndex(rule, predicateLevel)
+	}
+
+	for _, r := range matches {
+		for _, rule := range rules {
+			report.addResult(rule, predicateLevel, r)
+		}
+	}
+}
+
+// addResult adds a result of the rule with the level error, warning or note
+func (report *sarifReport) addResult(rule sarifRule, level string, r dockref.Reference) {
+	report.results = append(report.results, sarifResultNew(rule, report.ruleIndex(rule, level), level, r))
+}
+
+func sarifResultNew(rule sarifRule, ruleIndex int, level string, r dockref.Reference) sarifResult {
+	result := sarifResult{
+		RuleID:    rule.id,
+		RuleIndex: ruleIndex,
+		Level:     level,
+		Message:   sarifMessage{Text: fmt.Sprintf(rule.message, r.Original())},
+	}
+
+	if location, ok := dockfmt.LocationOf(r); ok {
+		result.Locations = []sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(location.File)},
+				Region: sarifRegion{
+					StartLine:   location.Line,
+					StartColumn: location.Column,
+					EndLine:     location.EndLine,
+					EndColumn:   location.EndColumn,
+				},
+			},
+		}}
+	}
+
+	return result
+}
+
+func (report *sarifReport) write(writer io.Writer) error {
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:           "dockmoor",
+					Version:        Version,
+					InformationURI: informationURI,
+					Rules:          report.rules,
+				},
+			},
+			Results: report.results,
+		}},
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
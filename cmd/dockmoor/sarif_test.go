@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSarifReportHasResultForEachPredicate(t *testing.T) {
+	report := sarifReportNew()
+
+	nginx, _ := dockref.FromOriginal("nginx")
+	located := dockfmt.LocatedReferenceNew(nginx, dockfmt.Location{File: "app/Dockerfile", Line: 2, Column: 6, EndLine: 2, EndColumn: 11})
+	report.add([]namedPredicate{
+		{"latest", dockproc.LatestPredicateNew()},
+		{"unpinned", dockproc.UnpinnedPredicateNew()},
+	}, []dockref.Reference{located})
+
+	alpine, _ := dockref.FromOriginal("alpine")
+	report.add([]namedPredicate{
+		{"unpinned", dockproc.UnpinnedPredicateNew()},
+	}, []dockref.Reference{alpine})
+
+	assert.Equal(t, []sarifReportingDescriptor{
+		{ID: "latest", Name: "Latest", ShortDescription: sarifMessage{"Image reference with latest or no tag"}, DefaultConfiguration: sarifConfiguration{"warning"}},
+		{ID: "unpinned", Name: "Unpinned", ShortDescription: sarifMessage{"Image reference not pinned to a digest"}, DefaultConfiguration: sarifConfiguration{"warning"}},
+	}, report.rules)
+
+	region := sarifRegion{StartLine: 2, StartColumn: 6, EndLine: 2, EndColumn: 11}
+	locations := []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: "app/Dockerfile"}, Region: region}}}
+	assert.Equal(t, []sarifResult{
+		{RuleID: "latest", RuleIndex: 0, Level: "warning", Message: sarifMessage{"Image reference nginx uses the latest tag"}, Locations: locations},
+		{RuleID: "unpinned", RuleIndex: 1, Level: "warning", Message: sarifMessage{"Image reference nginx is not pinned to a digest"}, Locations: locations},
+		{RuleID: "unpinned", RuleIndex: 1, Level: "warning", Message: sarifMessage{"Image reference alpine is not pinned to a digest"}},
+	}, report.results)
+}
+
+func TestSarifReportUsesImageRuleWithoutPredicates(t *testing.T) {
+	report := sarifReportNew()
+
+	nginx, _ := dockref.FromOriginal("nginx")
+	report.add(nil, []dockref.Reference{nginx})
+
+	assert.Len(t, report.rules, 1)
+	assert.Equal(t, "image", report.rules[0].ID)
+	assert.Equal(t, "Image reference nginx", report.results[0].Message.Text)
+}
+
+func TestSarifReportWritesSarifLog(t *testing.T) {
+	report := sarifReportNew()
+	report.add(nil, nil)
+
+	buffer := bytes.NewBuffer(nil)
+	err := report.write(buffer)
+	assert.Nil(t, err)
+
+	var log map[string]interface{}
+	err = json.Unmarshal(buffer.Bytes(), &log)
+	assert.Nil(t, err)
+	assert.Equal(t, "2.1.0", log["version"])
+	assert.Equal(t, sarifSchema, log["$schema"])
+
+	run := log["runs"].([]interface{})[0].(map[string]interface{})
+	driver := run["tool"].(map[string]interface{})["driver"].(map[string]interface{})
+	assert.Equal(t, "dockmoor", driver["name"])
+	assert.Equal(t, []interface{}{}, run["results"])
+}
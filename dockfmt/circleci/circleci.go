@@ -0,0 +1,179 @@
+package circleci
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*circleCIFormat)(nil)
+
+type circleCIFormat struct {
+	filename string
+	content  []byte
+	images   []yamlfmt.ImageValue
+}
+
+// sections are the top-level keys that define jobs and executors
+var sections = []string{"jobs", "executors", "workflows", "orbs"}
+
+func (format *circleCIFormat) Name() string {
+	return "CircleCI"
+}
+
+func New() dockfmt.Format {
+	return newCircleCIFormat()
+}
+
+func newCircleCIFormat() *circleCIFormat {
+	return new(circleCIFormat)
+}
+
+func (format *circleCIFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return err
+	}
+
+	root := yamlfmt.Root(&document)
+	if root == nil {
+		return errors.Errorf("No YAML document found")
+	}
+
+	// docker-compose files have a version and services, GitHub workflows have jobs but no version
+	if yamlfmt.MappingValue(root, "version") == nil || yamlfmt.MappingValue(root, "services") != nil {
+		return errors.Errorf("No CircleCI configuration with version found")
+	}
+
+	containsSection := false
+	for _, section := range sections {
+		node := yamlfmt.MappingValue(root, section)
+		if node != nil && node.Kind == yaml.MappingNode {
+			containsSection = true
+		}
+	}
+	if !containsSection {
+		return errors.Errorf("No CircleCI jobs, executors, workflows or orbs found")
+	}
+
+	images := make([]yamlfmt.ImageValue, 0)
+	for _, section := range []string{"executors", "jobs"} {
+		forEachValue(yamlfmt.MappingValue(root, section), func(executor *yaml.Node) {
+			images = append(images, executorImages(executor)...)
+		})
+	}
+
+	workflows := yamlfmt.MappingValue(root, "workflows")
+	forEachValue(workflows, func(workflow *yaml.Node) {
+		jobs := yamlfmt.MappingValue(workflow, "jobs")
+		if jobs == nil || jobs.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, job := range jobs.Content {
+			// jobs with parameters are given like - orb/job: {executor: ..., image: ...}
+			forEachValue(job, func(invocation *yaml.Node) {
+				images = append(images, invocationImages(invocation)...)
+			})
+		}
+	})
+
+	format.filename = filename
+	format.content = content
+	format.images = yamlfmt.Unique(images)
+
+	return nil
+}
+
+func forEachValue(mapping *yaml.Node, f func(value *yaml.Node)) {
+	mapping = yamlfmt.ResolveAlias(mapping)
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(mapping.Content); i += 2 {
+		f(yamlfmt.ResolveAlias(mapping.Content[i]))
+	}
+}
+
+// executorImages returns the images of an executor or of a job, which can define its executor inline
+func executorImages(executor *yaml.Node) []yamlfmt.ImageValue {
+	images := make([]yamlfmt.ImageValue, 0)
+
+	docker := yamlfmt.MappingValue(executor, "docker")
+	if docker != nil && docker.Kind == yaml.SequenceNode {
+		for _, container := range docker.Content {
+			if image := scalar(yamlfmt.MappingValue(container, "image")); image != nil {
+				images = append(images, yamlfmt.ImageValue{Node: image})
+			}
+		}
+	}
+
+	// executors of orbs are parameterized like executor: {name: node/default, image: ...}
+	images = append(images, invocationImages(yamlfmt.MappingValue(executor, "executor"))...)
+
+	// parameters like image: {type: string, default: cimg/base:2020.01}
+	parameters := yamlfmt.MappingValue(executor, "parameters")
+	if parameters != nil && parameters.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(parameters.Content); i += 2 {
+			if !isImageName(parameters.Content[i].Value) {
+				continue
+			}
+			if image := scalar(yamlfmt.MappingValue(parameters.Content[i+1], "default")); image != nil {
+				images = append(images, yamlfmt.ImageValue{Node: image})
+			}
+		}
+	}
+
+	return images
+}
+
+// invocationImages returns the images passed as parameters to an executor or job of an orb
+func invocationImages(invocation *yaml.Node) []yamlfmt.ImageValue {
+	images := make([]yamlfmt.ImageValue, 0)
+	if invocation == nil || invocation.Kind != yaml.MappingNode {
+		return images
+	}
+
+	for i := 0; i+1 < len(invocation.Content); i += 2 {
+		key := invocation.Content[i].Value
+		value := yamlfmt.ResolveAlias(invocation.Content[i+1])
+		if key == "executor" {
+			images = append(images, invocationImages(value)...)
+			continue
+		}
+		if image := scalar(value); image != nil && isImageName(key) {
+			images = append(images, yamlfmt.ImageValue{Node: image})
+		}
+	}
+	return images
+}
+
+func isImageName(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), "image")
+}
+
+func scalar(node *yaml.Node) *yaml.Node {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return nil
+	}
+	return node
+}
+
+func (format *circleCIFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return yamlfmt.ProcessImages(log, format.filename, format.content, format.images, w, imageNameProcessor)
+}
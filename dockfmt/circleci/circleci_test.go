@@ -0,0 +1,129 @@
+package circleci
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const config = `version: 2.1
+orbs:
+  node: circleci/node@4.1
+executors:
+  go:
+    docker:
+      - image: cimg/go:1.15   # primary
+      - image: "postgres:10"
+        environment:
+          POSTGRES_USER: test
+jobs:
+  build:
+    executor: go
+    steps: [checkout]
+  test:
+    parameters:
+      image:
+        type: string
+        default: cimg/base:2020.01
+      version:
+        type: string
+        default: "1.0"
+    docker:
+      - image: << parameters.image >>
+      - image: &redis redis:5
+    steps: [checkout]
+  lint:
+    executor:
+      name: node/default
+      image: cimg/node:14.15
+    steps: [checkout]
+  cache:
+    docker:
+      - image: *redis
+    steps: [checkout]
+workflows:
+  main:
+    jobs:
+      - build
+      - node/test:
+          executor:
+            name: node/default
+            image: cimg/node:12.20
+          base-image: alpine:3.8
+          version: "12"
+`
+
+func processCircleCI(t *testing.T, file string, imageNameProcessor func(r dockref.Reference) (string, error)) string {
+	format := New()
+	err := format.ValidateInput(log, strings.NewReader(file), "config.yml")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.Process(log, strings.NewReader(file), buffer, imageNameProcessor)
+	assert.Nil(t, err)
+
+	return buffer.String()
+}
+
+func TestCircleCIFindsImagesInOrder(t *testing.T) {
+	var found []string
+	processCircleCI(t, config, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Equal(t, []string{
+		"cimg/go:1.15", "postgres:10",
+		"redis:5", "cimg/base:2020.01",
+		"cimg/node:14.15",
+		"cimg/node:12.20", "alpine:3.8",
+	}, found)
+}
+
+func TestCircleCIProcessPreservesFormatting(t *testing.T) {
+	result := processCircleCI(t, config, func(r dockref.Reference) (string, error) {
+		return r.Original() + "@sha256:1", nil
+	})
+
+	expected := strings.NewReplacer(
+		"cimg/go:1.15   # primary", "cimg/go:1.15@sha256:1   # primary",
+		`"postgres:10"`, `"postgres:10@sha256:1"`,
+		"default: cimg/base:2020.01", "default: cimg/base:2020.01@sha256:1",
+		"&redis redis:5", "&redis redis:5@sha256:1",
+		"cimg/node:14.15", "cimg/node:14.15@sha256:1",
+		"cimg/node:12.20", "cimg/node:12.20@sha256:1",
+		"alpine:3.8", "alpine:3.8@sha256:1",
+	).Replace(config)
+	assert.Equal(t, expected, result)
+}
+
+func TestCircleCIReportsLocations(t *testing.T) {
+	file := `version: 2.1
+jobs:
+  build:
+    docker:
+      - image: cimg/go:1.15
+`
+
+	var locations []dockfmt.Location
+	processCircleCI(t, file, func(r dockref.Reference) (string, error) {
+		location, ok := dockfmt.LocationOf(r)
+		assert.True(t, ok)
+		locations = append(locations, location)
+		return "", nil
+	})
+
+	assert.Equal(t, []dockfmt.Location{
+		{File: "config.yml", Line: 5, Column: 16, EndLine: 5, EndColumn: 28},
+	}, locations)
+}
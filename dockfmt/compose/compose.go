@@ -0,0 +1,147 @@
+package compose
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure ConfigurableFormat is implemented
+var _ dockfmt.ConfigurableFormat = (*composeFormat)(nil)
+
+type composeFormat struct {
+	filename string
+	content  []byte
+	images   []yamlfmt.ImageValue
+	// buildArgs enables finding images in build args
+	buildArgs bool
+}
+
+func (format *composeFormat) Name() string {
+	return "docker-compose"
+}
+
+func New() dockfmt.Format {
+	return newComposeFormat()
+}
+
+func newComposeFormat() *composeFormat {
+	return new(composeFormat)
+}
+
+// Configure returns a format that finds images in build args named like *IMAGE when ComposeBuildArgs is set
+func (format *composeFormat) Configure(options dockfmt.Options) (dockfmt.Format, error) {
+	configured := newComposeFormat()
+	configured.buildArgs = options.ComposeBuildArgs
+	return configured, nil
+}
+
+func (format *composeFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return err
+	}
+
+	root := yamlfmt.Root(&document)
+	if root == nil {
+		return errors.Errorf("No YAML document found")
+	}
+
+	services := yamlfmt.MappingValue(root, "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return errors.Errorf("No services found")
+	}
+
+	images := make([]yamlfmt.ImageValue, 0)
+	containsService := false
+	for i := 1; i < len(services.Content); i += 2 {
+		service := yamlfmt.ResolveAlias(services.Content[i])
+		if service.Kind != yaml.MappingNode {
+			continue
+		}
+
+		// Helm values often contain services with an image mapping of repository and tag
+		image := yamlfmt.MappingValue(service, "image")
+		if image != nil && image.Kind != yaml.ScalarNode {
+			image = nil
+		}
+		build := yamlfmt.MappingValue(service, "build")
+		if image == nil && build == nil {
+			continue
+		}
+		containsService = true
+
+		if image != nil {
+			images = append(images, yamlfmt.ImageValue{Node: image})
+		}
+
+		if format.buildArgs && build != nil && build.Kind == yaml.MappingNode {
+			images = append(images, imageBuildArgs(yamlfmt.MappingValue(build, "args"))...)
+		}
+	}
+
+	if !containsService {
+		return errors.Errorf("No service with image or build found")
+	}
+
+	format.filename = filename
+	format.content = content
+	format.images = yamlfmt.Unique(images)
+
+	return nil
+}
+
+// imageBuildArgs returns build args that name images by convention, e.g. BASE_IMAGE.
+// Build args can be given as mapping or as list of KEY=value
+func imageBuildArgs(args *yaml.Node) []yamlfmt.ImageValue {
+	values := make([]yamlfmt.ImageValue, 0)
+	if args == nil {
+		return values
+	}
+
+	switch args.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(args.Content); i += 2 {
+			value := yamlfmt.ResolveAlias(args.Content[i+1])
+			if isImageArgName(args.Content[i].Value) && value.Kind == yaml.ScalarNode {
+				values = append(values, yamlfmt.ImageValue{Node: value})
+			}
+		}
+	case yaml.SequenceNode:
+		for _, arg := range args.Content {
+			arg = yamlfmt.ResolveAlias(arg)
+			if arg.Kind != yaml.ScalarNode {
+				continue
+			}
+			parts := strings.SplitN(arg.Value, "=", 2)
+			if len(parts) == 2 && isImageArgName(parts[0]) {
+				values = append(values, yamlfmt.ImageValue{Node: arg, Prefix: parts[0] + "="})
+			}
+		}
+	}
+
+	return values
+}
+
+func isImageArgName(name string) bool {
+	return strings.HasSuffix(strings.ToUpper(name), "IMAGE")
+}
+
+func (format *composeFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return yamlfmt.ProcessImages(log, format.filename, format.content, format.images, w, imageNameProcessor)
+}
@@ -0,0 +1,242 @@
+package compose
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestComposeName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "docker-compose", name)
+}
+
+func TestComposeFormatRejectsNonComposeFiles(t *testing.T) {
+	files := map[string]string{
+		"empty":          ``,
+		"dockerfile":     "FROM nginx\nRUN echo\n",
+		"no services":    "version: '3'\nvolumes:\n  data: {}\n",
+		"services list":  "services:\n  - nginx\n",
+		"no image/build": "services:\n  web:\n    ports: [\"80:80\"]\n",
+		"image mapping":  "services:\n  api:\n    image:\n      repository: x\n      tag: \"1\"\n",
+		"invalid yaml":   "services: [\n",
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			format := New()
+			err := format.ValidateInput(log, strings.NewReader(file), "anything")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestComposeFormatAcceptsServicesWithBuildOnly(t *testing.T) {
+	file := "services:\n  web:\n    build: .\n"
+	format := New()
+	err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, err)
+}
+
+func processCompose(t *testing.T, format dockfmt.Format, file string, imageNameProcessor func(r dockref.Reference) (string, error)) string {
+	err := format.ValidateInput(log, strings.NewReader(file), "docker-compose.yml")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.Process(log, strings.NewReader(file), buffer, imageNameProcessor)
+	assert.Nil(t, err)
+
+	return buffer.String()
+}
+
+func withBuildArgs(t *testing.T) dockfmt.Format {
+	format, err := newComposeFormat().Configure(dockfmt.Options{ComposeBuildArgs: true})
+	assert.Nil(t, err)
+	return format
+}
+
+func TestComposeFindsImagesInOrder(t *testing.T) {
+	file := `version: "3.7"
+services:
+  web:
+    image: nginx:1.15
+  db:
+    image: "postgres:10"
+  app:
+    build:
+      context: .
+      args:
+        BASE_IMAGE: alpine:3.8
+        VERSION: "1.0"
+  worker:
+    build:
+      context: .
+      args:
+        - BUILDER_IMAGE=golang:1.11
+        - VERSION=1.0
+`
+
+	var found []string
+	processCompose(t, withBuildArgs(t), file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Equal(t, []string{"nginx:1.15", "postgres:10", "alpine:3.8", "golang:1.11"}, found)
+}
+
+func TestComposeIgnoresBuildArgsByDefault(t *testing.T) {
+	file := `services:
+  web:
+    image: nginx:1.15
+  app:
+    build:
+      args:
+        BASE_IMAGE: alpine:3.8
+  worker:
+    build:
+      args:
+        - BUILDER_IMAGE=golang:1.11
+`
+
+	var found []string
+	result := processCompose(t, New(), file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return r.Original() + "@sha256:1", nil
+	})
+
+	assert.Equal(t, []string{"nginx:1.15"}, found)
+	assert.Equal(t, strings.Replace(file, "nginx:1.15", "nginx:1.15@sha256:1", 1), result)
+}
+
+func TestComposeProcessPreservesFormatting(t *testing.T) {
+	file := `# the stack
+version: '3'
+
+x-defaults: &defaults
+  restart: always   # keep running
+  image: &app "menedev/app:1.0"
+
+services:
+  web:
+    image: 'nginx:1.15'  # front
+    ports:
+      - "80:80"
+  app:
+    <<: *defaults
+  app2:
+    image: *app
+  builder:
+    build:
+      args:
+        - BASE_IMAGE=alpine:3.8
+  cache:
+    image: redis
+`
+	expected := `# the stack
+version: '3'
+
+x-defaults: &defaults
+  restart: always   # keep running
+  image: &app "menedev/app:1.0@sha256:1"
+
+services:
+  web:
+    image: 'nginx:1.15@sha256:2'  # front
+    ports:
+      - "80:80"
+  app:
+    <<: *defaults
+  app2:
+    image: *app
+  builder:
+    build:
+      args:
+        - BASE_IMAGE=alpine:3.8@sha256:3
+  cache:
+    image: redis
+`
+
+	digests := map[string]string{
+		"menedev/app:1.0": "sha256:1",
+		"nginx:1.15":      "sha256:2",
+		"alpine:3.8":      "sha256:3",
+	}
+
+	calls := 0
+	result := processCompose(t, withBuildArgs(t), file, func(r dockref.Reference) (string, error) {
+		calls++
+		if dig, ok := digests[r.Original()]; ok {
+			return r.Original() + "@" + dig, nil
+		}
+		return "", nil
+	})
+
+	assert.Equal(t, expected, result)
+	assert.Equal(t, 4, calls)
+}
+
+func TestComposeSkipsInterpolatedImages(t *testing.T) {
+	file := "services:\n  web:\n    image: nginx:${TAG}\n  db:\n    image: postgres:10\n"
+
+	var found []string
+	result := processCompose(t, New(), file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "postgres:11", nil
+	})
+
+	assert.Equal(t, []string{"postgres:10"}, found)
+	assert.Equal(t, "services:\n  web:\n    image: nginx:${TAG}\n  db:\n    image: postgres:11\n", result)
+}
+
+func TestComposePassProcessorErrors(t *testing.T) {
+	file := "services:\n  web:\n    image: nginx\n"
+	format := New()
+	format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	expected := errors.New("Expected")
+	err := format.Process(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference) (string, error) {
+		return "", expected
+	})
+
+	assert.Equal(t, expected, err)
+}
+
+func TestComposeReportsLocations(t *testing.T) {
+	file := `services:
+  web:
+    image: nginx:1.15
+  db:
+    image: &db "postgres:10"
+  worker:
+    build:
+      args:
+        - BUILDER_IMAGE=golang:1.11
+`
+
+	var locations []dockfmt.Location
+	processCompose(t, withBuildArgs(t), file, func(r dockref.Reference) (string, error) {
+		location, ok := dockfmt.LocationOf(r)
+		assert.True(t, ok)
+		locations = append(locations, location)
+		return "", nil
+	})
+
+	assert.Equal(t, []dockfmt.Location{
+		{File: "docker-compose.yml", Line: 3, Column: 12, EndLine: 3, EndColumn: 22},
+		{File: "docker-compose.yml", Line: 5, Column: 17, EndLine: 5, EndColumn: 28},
+		{File: "docker-compose.yml", Line: 9, Column: 25, EndLine: 9, EndColumn: 36},
+	}, locations)
+}
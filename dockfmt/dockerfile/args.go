@@ -0,0 +1,199 @@
+package dockerfile
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+// globalArg is an ARG instruction before the first FROM
+type globalArg struct {
+	name       string
+	value      string
+	hasDefault bool
+	startLine  int
+	endLine    int
+}
+
+// templatePart is either literal text or a variable like ${GO_VERSION} in a FROM instruction
+type templatePart struct {
+	literal string
+
+	variable string
+	// operator is - for ${VAR:-word} and + for ${VAR:+word}
+	operator string
+	word     string
+}
+
+func (part templatePart) isVariable() bool {
+	return part.variable != ""
+}
+
+var variableNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
+
+// parseTemplate splits a word into literal text and variables
+func parseTemplate(word string) ([]templatePart, error) {
+	parts := make([]templatePart, 0)
+	literal := ""
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		if c == '\\' && i+1 < len(word) && word[i+1] == '$' {
+			literal += "$"
+			i++
+			continue
+		}
+
+		if c != '$' {
+			literal += string(c)
+			continue
+		}
+
+		var part templatePart
+		rest := word[i+1:]
+		if strings.HasPrefix(rest, "{") {
+			end := strings.Index(rest, "}")
+			if end < 0 {
+				return nil, errors.Errorf("Missing } in '%s'", word)
+			}
+
+			expression := rest[1:end]
+			name := variableNameRegexp.FindString(expression)
+			if name == "" {
+				return nil, errors.Errorf("Invalid variable '${%s}' in '%s'", expression, word)
+			}
+			part.variable = name
+
+			modifier := expression[len(name):]
+			switch {
+			case modifier == "":
+			case strings.HasPrefix(modifier, ":-"), strings.HasPrefix(modifier, ":+"):
+				part.operator = modifier[1:2]
+				part.word = modifier[2:]
+			default:
+				return nil, errors.Errorf("Unsupported substitution '${%s}' in '%s'", expression, word)
+			}
+			i += end + 1
+		} else {
+			name := variableNameRegexp.FindString(rest)
+			if name == "" {
+				literal += "$"
+				continue
+			}
+			part.variable = name
+			i += len(name)
+		}
+
+		if literal != "" {
+			parts = append(parts, templatePart{literal: literal})
+			literal = ""
+		}
+		parts = append(parts, part)
+	}
+
+	if literal != "" {
+		parts = append(parts, templatePart{literal: literal})
+	}
+
+	return parts, nil
+}
+
+func containsVariables(parts []templatePart) bool {
+	for _, part := range parts {
+		if part.isVariable() {
+			return true
+		}
+	}
+	return false
+}
+
+// expand replaces the variables with their values, unset variables are replaced by the empty string
+func expand(parts []templatePart, values map[string]string) string {
+	expanded := ""
+	for _, part := range parts {
+		if !part.isVariable() {
+			expanded += part.literal
+			continue
+		}
+
+		value, set := values[part.variable]
+		set = set && value != ""
+		switch {
+		case part.operator == "-" && !set:
+			expanded += part.word
+		case part.operator == "+" && set:
+			expanded += part.word
+		case part.operator == "+":
+		default:
+			expanded += value
+		}
+	}
+	return expanded
+}
+
+// solve finds the values of the variables that expand the template to target.
+// The values of variables that are used with :- or :+ cannot be changed
+func solve(parts []templatePart, values map[string]string, target string) (map[string]string, error) {
+	pattern := "^"
+	variables := make([]string, 0)
+	for _, part := range parts {
+		switch {
+		case !part.isVariable():
+			pattern += regexp.QuoteMeta(part.literal)
+		case part.operator != "":
+			pattern += regexp.QuoteMeta(expand([]templatePart{part}, values))
+		default:
+			pattern += "(.*?)"
+			variables = append(variables, part.variable)
+		}
+	}
+	pattern += "$"
+
+	matches := regexp.MustCompile(pattern).FindStringSubmatch(target)
+	if matches == nil {
+		return nil, errors.Errorf("Cannot express '%s' by changing the variables", target)
+	}
+
+	solved := make(map[string]string)
+	for i, variable := range variables {
+		value := matches[i+1]
+		if previous, ok := solved[variable]; ok && previous != value {
+			return nil, errors.Errorf("Cannot express '%s' because %s is used more than once", target, variable)
+		}
+		solved[variable] = value
+	}
+	return solved, nil
+}
+
+// parseArg parses the words of an ARG instruction like GO_VERSION=1.11 or GO_VERSION
+func parseArg(word string) (name string, value string, hasDefault bool) {
+	parts := strings.SplitN(word, "=", 2)
+	if len(parts) == 1 {
+		return parts[0], "", false
+	}
+	return parts[0], unquote(parts[1]), true
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// replaceArgDefault replaces the default of the named ARG in the line, keeping quotes
+func replaceArgDefault(line string, name string, value string) (string, error) {
+	argRegexp := regexp.MustCompile(`(^|\s)` + regexp.QuoteMeta(name) + `=("[^"]*"|'[^']*'|\S*)`)
+	location := argRegexp.FindStringSubmatchIndex(line)
+	if location == nil {
+		return "", errors.Errorf("Cannot find default of ARG %s in '%s'", name, strings.TrimSpace(line))
+	}
+
+	start, end := location[4], location[5]
+	old := line[start:end]
+	if len(old) >= 2 && (old[0] == '"' || old[0] == '\'') {
+		start++
+		end--
+	}
+
+	return line[:start] + value + line[end:], nil
+}
@@ -0,0 +1,96 @@
+package dockerfile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	expectations := map[string][]templatePart{
+		"nginx":                 {{literal: "nginx"}},
+		"golang:${GO_VERSION}":  {{literal: "golang:"}, {variable: "GO_VERSION"}},
+		"$IMAGE:$TAG":           {{variable: "IMAGE"}, {literal: ":"}, {variable: "TAG"}},
+		"node:${V:-10}-alpine":  {{literal: "node:"}, {variable: "V", operator: "-", word: "10"}, {literal: "-alpine"}},
+		"${REGISTRY:+r.io/}app": {{variable: "REGISTRY", operator: "+", word: "r.io/"}, {literal: "app"}},
+		`price\$5`:              {{literal: "price$5"}},
+		"a$":                    {{literal: "a$"}},
+	}
+
+	for word, expected := range expectations {
+		t.Run(word, func(t *testing.T) {
+			parts, err := parseTemplate(word)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, parts)
+		})
+	}
+
+	for _, word := range []string{"${GO_VERSION", "${}", "${V%%.*}", "${1}"} {
+		t.Run("Rejects "+word, func(t *testing.T) {
+			_, err := parseTemplate(word)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	values := map[string]string{"TAG": "1.15", "EMPTY": ""}
+	expectations := map[string]string{
+		"nginx:$TAG":             "nginx:1.15",
+		"nginx:${TAG}-alpine":    "nginx:1.15-alpine",
+		"nginx:${MISSING}":       "nginx:",
+		"nginx:${MISSING:-1.14}": "nginx:1.14",
+		"nginx:${EMPTY:-1.14}":   "nginx:1.14",
+		"nginx:${TAG:-1.14}":     "nginx:1.15",
+		"nginx${TAG:+:latest}":   "nginx:latest",
+		"nginx${MISSING:+:1.14}": "nginx",
+	}
+
+	for word, expected := range expectations {
+		t.Run(word, func(t *testing.T) {
+			parts, _ := parseTemplate(word)
+			assert.Equal(t, expected, expand(parts, values))
+		})
+	}
+}
+
+func TestSolve(t *testing.T) {
+	values := map[string]string{"IMAGE": "nginx", "TAG": "1.15", "V": "10"}
+
+	parts, _ := parseTemplate("${IMAGE}:${TAG}")
+	solved, err := solve(parts, values, "nginx:1.15@sha256:abc")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"IMAGE": "nginx", "TAG": "1.15@sha256:abc"}, solved)
+
+	parts, _ = parseTemplate("node:${V}-alpine")
+	_, err = solve(parts, values, "node:10-alpine@sha256:abc")
+	assert.Error(t, err)
+
+	parts, _ = parseTemplate("${TAG}-${TAG}")
+	_, err = solve(parts, values, "1.15-1.16")
+	assert.Error(t, err)
+
+	parts, _ = parseTemplate("node:${MISSING:-10}-${TAG}")
+	solved, err = solve(parts, values, "node:10-1.16")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"TAG": "1.16"}, solved)
+}
+
+func TestReplaceArgDefault(t *testing.T) {
+	expectations := map[string]string{
+		"ARG TAG=1.15\n":               "ARG TAG=1.16\n",
+		"ARG TAG=\"1.15\"\n":           "ARG TAG=\"1.16\"\n",
+		"ARG IMAGE=nginx TAG='1.15'\n": "ARG IMAGE=nginx TAG='1.16'\n",
+		"ARG MY_TAG=1 TAG=1.15\n":      "ARG MY_TAG=1 TAG=1.16\n",
+	}
+
+	for line, expected := range expectations {
+		t.Run(line, func(t *testing.T) {
+			replaced, err := replaceArgDefault(line, "TAG", "1.16")
+			assert.Nil(t, err)
+			assert.Equal(t, expected, replaced)
+		})
+	}
+
+	_, err := replaceArgDefault("ARG MY_TAG=1.15\n", "TAG", "1.16")
+	assert.Error(t, err)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"io"
 	"reflect"
+	"regexp"
 	"strings"
 )
 
@@ -19,13 +20,17 @@ func init() {
 	dockfmt.RegisterFormat(New())
 }
 
-// ensure Format is implemented
-var _ dockfmt.Format = (*dockerfileFormat)(nil)
+// ensure ConfigurableFormat is implemented
+var _ dockfmt.ConfigurableFormat = (*dockerfileFormat)(nil)
 
 type dockerfileFormat struct {
+	filename      string
 	lines         []string
 	result        *parser.Result
+	args          []globalArg
 	parseFunction func(rwc io.Reader) (*parser.Result, error)
+	// buildArgs override the defaults of global ARG instructions like --build-arg does for docker build
+	buildArgs map[string]string
 }
 
 func (format *dockerfileFormat) Name() string {
@@ -39,9 +44,20 @@ func New() dockfmt.Format {
 func newDockerfileFormat() *dockerfileFormat {
 	format := new(dockerfileFormat)
 	format.parseFunction = parser.Parse
+	format.buildArgs = make(map[string]string)
 	return format
 }
 
+// Configure returns a format that overrides the defaults of ARG instructions before the first FROM with
+// BuildArgs, like docker build --build-arg
+func (format *dockerfileFormat) Configure(options dockfmt.Options) (dockfmt.Format, error) {
+	configured := newDockerfileFormat()
+	for name, value := range options.BuildArgs {
+		configured.buildArgs[name] = value
+	}
+	return configured, nil
+}
+
 func dockerfileFormatSplitFunc(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	if atEOF && len(data) == 0 {
 		return 0, nil, nil
@@ -99,12 +115,33 @@ func (format *dockerfileFormat) ValidateInput(log logrus.FieldLogger, reader io.
 		return errors.Errorf("No FROM command found")
 	}
 
+	format.filename = filename
 	format.lines = lines
 	format.result = result
+	format.args = globalArgs(result.AST)
 
 	return nil
 }
 
+// globalArgs returns the ARGs declared before the first FROM, which can be used in FROM instructions
+func globalArgs(root *parser.Node) []globalArg {
+	args := make([]globalArg, 0)
+	for _, cmd := range root.Children {
+		if cmd.Value == "from" {
+			break
+		}
+		if cmd.Value != "arg" {
+			continue
+		}
+
+		for word := cmd.Next; word != nil; word = word.Next {
+			name, value, hasDefault := parseArg(word.Value)
+			args = append(args, globalArg{name: name, value: value, hasDefault: hasDefault, startLine: cmd.StartLine, endLine: endLineOfNode(cmd)})
+		}
+	}
+	return args
+}
+
 func saveFlush(log logrus.FieldLogger, writer *bufio.Writer) {
 	err := writer.Flush()
 	if err != nil {
@@ -118,45 +155,55 @@ func (format *dockerfileFormat) Process(log logrus.FieldLogger, reader io.Reader
 
 	defer saveFlush(log, writer)
 
-	root := format.result.AST
-	lines := format.lines
+	// edited lines by line number, FROM instructions may change the default of an ARG in an earlier line
+	edits := make(map[int]string)
+	values := argValues(format.args, format.buildArgs)
+	stages := stagesOf(format.result.AST, values)
 
-	curLineNum := 0
-	for _, cmd := range root.Children {
-		curLineNum++
-		for i := curLineNum; i < cmd.StartLine; i++ {
-			_, err := writer.WriteString(lines[i-1])
-			result = multierror.Append(result, err)
-			curLineNum++
+	stageIndex := 0
+	for _, cmd := range format.result.AST.Children {
+		var err error
+		if cmd.Value == "from" {
+			stage := stages[stageIndex]
+			stageIndex++
+			if stage.BaseStage >= 0 {
+				log.Infof("Skipping stage reference %s", stage.From)
+				continue
+			}
+			err = format.processNode(log, cmd, values, edits, imageNameProcessor)
+		} else {
+			err = format.processFlags(log, cmd, stages[:stageIndex], edits, imageNameProcessor)
 		}
 
-		handled, err := format.processNode(log, cmd, writer, imageNameProcessor)
 		if err != nil {
 			return err
 		}
-
-		endLine := endLineOfNode(cmd)
-
-		if !handled {
-			for i := cmd.StartLine; i <= endLine; i++ {
-				_, err := writer.WriteString(lines[i-1])
-				result = multierror.Append(result, err)
-			}
-		}
-		curLineNum = endLine
 	}
 
-	lastCommand := root.Children[len(root.Children)-1]
-	endLine := endLineOfNode(lastCommand)
-
-	for i := endLine; i < len(lines); i++ {
-		_, err := writer.WriteString(lines[i])
+	for i, line := range format.lines {
+		if edited, ok := edits[i+1]; ok {
+			line = edited
+		}
+		_, err := writer.WriteString(line)
 		result = multierror.Append(result, err)
 	}
 
 	return result.ErrorOrNil()
 }
 
+// argValues returns the values of the global ARGs, defaults are overridden by build args
+func argValues(args []globalArg, buildArgs map[string]string) map[string]string {
+	values := make(map[string]string)
+	for _, arg := range args {
+		if value, ok := buildArgs[arg.name]; ok {
+			values[arg.name] = value
+		} else if arg.hasDefault {
+			values[arg.name] = arg.value
+		}
+	}
+	return values
+}
+
 func endLineOfNode(command *parser.Node) int {
 	v := reflect.ValueOf(*command)
 	y := v.FieldByName("endLine")
@@ -164,48 +211,212 @@ func endLineOfNode(command *parser.Node) int {
 	return endLine
 }
 
-func (format *dockerfileFormat) processNode(log logrus.FieldLogger, node *parser.Node, writer *bufio.Writer, imageNameProcessor dockfmt.ImageNameProcessor) (bool, error) {
-	result := new(multierror.Error)
+func (format *dockerfileFormat) processNode(log logrus.FieldLogger, node *parser.Node, values map[string]string, edits map[int]string, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	if node.Value != "from" {
+		// pass-through
+		return nil
+	}
+
+	from := node.Next.Value
+	template, err := parseTemplate(from)
+	if err != nil {
+		return err
+	}
+
+	expanded := from
+	if containsVariables(template) {
+		expanded = expand(template, values)
+		log.Infof("Expanded image %s to %s", from, expanded)
+	}
+	log.Infof("Found image %s", expanded)
+
+	ref, err := dockref.FromOriginal(expanded)
+	if err != nil {
+		if containsVariables(template) {
+			log.Warnf("Skipping image '%s' in line %d: %s", from, node.StartLine, err.Error())
+			return nil
+		}
+		return err
+	}
+
+	canonicalString, err := imageNameProcessor(dockfmt.LocatedReferenceNew(ref, format.fromLocation(node, from)))
+	if err != nil {
+		return err
+	}
 
-	if node.Value == "from" {
-		from := node.Next.Value
-		log.Infof("Found image %s", from)
+	if canonicalString == "" || canonicalString == expanded {
+		// nothing to replace, keep the original
+		return nil
+	}
+
+	log.Infof("Pinning '%s' as '%s'", expanded, canonicalString)
+
+	if containsVariables(template) {
+		return format.editArgs(template, values, edits, from, canonicalString)
+	}
+
+	end := endLineOfNode(node)
+	for i := node.StartLine; i <= end; i++ {
+		edits[i] = strings.Replace(format.currentLine(edits, i), from, canonicalString, 1)
+	}
+	return nil
+}
+
+// processFlags processes images in COPY --from and RUN --mount=from= flags, skipping stage references
+func (format *dockerfileFormat) processFlags(log logrus.FieldLogger, node *parser.Node, stages []Stage, edits map[int]string, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	for _, image := range imageFlags(node) {
+		if _, ok := stageReference(stages, image); ok {
+			log.Infof("Skipping stage reference %s", image)
+			continue
+		}
 
-		ref, err := dockref.FromOriginal(from)
+		if strings.Contains(image, "$") {
+			log.Warnf("Skipping image '%s' in line %d: variables are only supported in FROM", image, node.StartLine)
+			continue
+		}
+
+		log.Infof("Found image %s", image)
+		ref, err := dockref.FromOriginal(image)
 		if err != nil {
-			return false, err
+			return err
 		}
 
-		canonicalString, err := imageNameProcessor(ref)
+		canonicalString, err := imageNameProcessor(dockfmt.LocatedReferenceNew(ref, format.flagLocation(node, image)))
 		if err != nil {
-			return false, err
+			return err
+		}
+
+		if canonicalString == "" || canonicalString == image {
+			continue
+		}
+
+		log.Infof("Pinning '%s' as '%s'", image, canonicalString)
+		err = format.editFlag(node, edits, image, canonicalString)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var instructionRegexp = regexp.MustCompile(`^\s*\S+`)
+
+// fromLocation returns the location of the image in a FROM instruction as written, i.e. before ARGs are expanded
+func (format *dockerfileFormat) fromLocation(node *parser.Node, from string) dockfmt.Location {
+	for i := node.StartLine; i <= endLineOfNode(node); i++ {
+		line := format.lines[i-1]
+		start := 0
+		if i == node.StartLine {
+			// skip the instruction, e.g. in FROM from
+			start = len(instructionRegexp.FindString(line))
 		}
 
-		if canonicalString != "" {
-			log.Infof("Pinning '%s' as '%s'", from, canonicalString)
+		if index := strings.Index(line[start:], from); index >= 0 {
+			return dockfmt.LocationInLine(format.filename, i, line, start+index, from)
 		}
+	}
+	return format.nodeLocation(node)
+}
 
-		//writer.WriteString(`FROM `)
-		//writer.WriteString(canonicalString)
+// flagLocation returns the location of the image in the from= part of a flag
+func (format *dockerfileFormat) flagLocation(node *parser.Node, image string) dockfmt.Location {
+	flagRegexp := flagImageRegexp(image)
+	for i := node.StartLine; i <= endLineOfNode(node); i++ {
+		line := format.lines[i-1]
+		if location := flagRegexp.FindStringSubmatchIndex(line); location != nil {
+			return dockfmt.LocationInLine(format.filename, i, line, location[3], image)
+		}
+	}
+	return format.nodeLocation(node)
+}
+
+func (format *dockerfileFormat) nodeLocation(node *parser.Node) dockfmt.Location {
+	return dockfmt.Location{
+		File:      format.filename,
+		Line:      node.StartLine,
+		Column:    1,
+		EndLine:   node.StartLine,
+		EndColumn: 1,
+	}
+}
 
-		//next := node.Next.Next
+func flagImageRegexp(image string) *regexp.Regexp {
+	return regexp.MustCompile(`(from=["']?)` + regexp.QuoteMeta(image) + `(["',\s]|$)`)
+}
 
-		//for next != nil {
-		//	writer.WriteString(` `)
-		//	writer.WriteString(next.Value)
-		//
-		//	next = next.Next
-		//}
+// editFlag replaces the image in the from= part of a flag
+func (format *dockerfileFormat) editFlag(node *parser.Node, edits map[int]string, image string, canonicalString string) error {
+	flagRegexp := flagImageRegexp(image)
+	for i := node.StartLine; i <= endLineOfNode(node); i++ {
+		line := format.currentLine(edits, i)
+		location := flagRegexp.FindStringSubmatchIndex(line)
+		if location == nil {
+			continue
+		}
 
-		end := endLineOfNode(node)
-		start := node.StartLine
+		start := location[3]
+		edits[i] = line[:start] + canonicalString + line[start+len(image):]
+		return nil
+	}
+	return errors.Errorf("Cannot find image %s in line %d", image, node.StartLine)
+}
+
+func (format *dockerfileFormat) currentLine(edits map[int]string, lineNumber int) string {
+	if edited, ok := edits[lineNumber]; ok {
+		return edited
+	}
+	return format.lines[lineNumber-1]
+}
+
+// editArgs changes the defaults of the ARGs used in the FROM instruction, so that it expands to canonicalString
+func (format *dockerfileFormat) editArgs(template []templatePart, values map[string]string, edits map[int]string, from string, canonicalString string) error {
+	solved, err := solve(template, values, canonicalString)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot rewrite 'FROM %s'", from)
+	}
+
+	for name, value := range solved {
+		if values[name] == value {
+			continue
+		}
+
+		arg, ok := format.globalArg(name)
+		if !ok || !arg.hasDefault {
+			return errors.Errorf("Cannot rewrite 'FROM %s': ARG %s has no default", from, name)
+		}
+		if _, ok := format.buildArgs[name]; ok {
+			return errors.Errorf("Cannot rewrite 'FROM %s': ARG %s is overridden by a build arg", from, name)
+		}
+
+		err = format.editArgDefault(edits, arg, value)
+		if err != nil {
+			return err
+		}
+		values[name] = value
+	}
+
+	return nil
+}
+
+func (format *dockerfileFormat) editArgDefault(edits map[int]string, arg globalArg, value string) error {
+	var err error
+	for i := arg.startLine; i <= arg.endLine; i++ {
+		var line string
+		line, err = replaceArgDefault(format.currentLine(edits, i), arg.name, value)
+		if err == nil {
+			edits[i] = line
+			return nil
+		}
+	}
+	return err
+}
 
-		for i := start; i <= end; i++ {
-			_, err := writer.WriteString(strings.Replace(format.lines[i-1], from, canonicalString, 1))
-			result = multierror.Append(result, err)
+func (format *dockerfileFormat) globalArg(name string) (globalArg, bool) {
+	// the last declaration wins
+	for i := len(format.args) - 1; i >= 0; i-- {
+		if format.args[i].name == name {
+			return format.args[i], true
 		}
-		return true, result.ErrorOrNil()
 	}
-	// pass-through
-	return false, result.ErrorOrNil()
+	return globalArg{}, false
 }
@@ -2,6 +2,7 @@ package dockerfile
 
 import (
 	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 	"github.com/pkg/errors"
@@ -245,3 +246,291 @@ func TestDockerfileFormat_ReportsFlushError(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, processErr)
 }
+
+func TestDockerfileProcessWritesReplacedReferences(t *testing.T) {
+	file := `FROM nginx AS builder
+RUN some \
+	command
+
+FROM alpine:3.8
+# And a comment`
+	format := New()
+	format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	buffer := bytes.NewBuffer(nil)
+	err := format.Process(log, strings.NewReader(file), buffer, func(r dockref.Reference) (string, error) {
+		if r.Original() == "nginx" {
+			return "nginx@sha256:db5acc22920799fe387a903437eb89387607e5b3f63cf0f4472ac182d7bad644", nil
+		}
+		return "", nil
+	})
+
+	expected := `FROM nginx@sha256:db5acc22920799fe387a903437eb89387607e5b3f63cf0f4472ac182d7bad644 AS builder
+RUN some \
+	command
+
+FROM alpine:3.8
+# And a comment`
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, buffer.String())
+}
+
+func processWithArgs(t *testing.T, format dockfmt.Format, file string, imageNameProcessor dockfmt.ImageNameProcessor) (string, error) {
+	err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.Process(log, strings.NewReader(file), buffer, imageNameProcessor)
+	return buffer.String(), err
+}
+
+func TestDockerfileExpandsGlobalArgsInFrom(t *testing.T) {
+	file := `ARG REGISTRY=quay.io
+ARG GO_VERSION=1.11
+FROM ${REGISTRY}/golang:$GO_VERSION AS builder
+ARG GO_VERSION=1.12
+FROM nginx:${NGINX_VERSION:-1.15}
+`
+
+	var found []string
+	_, err := processWithArgs(t, New(), file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"quay.io/golang:1.11", "nginx:1.15"}, found)
+}
+
+func TestDockerfileBuildArgsOverrideDefaults(t *testing.T) {
+	format, err := newDockerfileFormat().Configure(dockfmt.Options{BuildArgs: map[string]string{"GO_VERSION": "1.10", "NGINX_VERSION": "1.14"}})
+	assert.Nil(t, err)
+
+	file := "ARG GO_VERSION=1.11\nARG NGINX_VERSION\nFROM golang:$GO_VERSION\nFROM nginx:${NGINX_VERSION:-1.15}\n"
+
+	var found []string
+	_, err = processWithArgs(t, format, file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"golang:1.10", "nginx:1.14"}, found)
+}
+
+func TestDockerfileRewriteUpdatesArgDefault(t *testing.T) {
+	file := `# syntax
+ARG IMAGE=golang GO_VERSION="1.11"
+FROM ${IMAGE}:${GO_VERSION} AS builder
+FROM alpine:3.8
+COPY --from=builder /app /app
+`
+
+	result, err := processWithArgs(t, New(), file, func(r dockref.Reference) (string, error) {
+		return r.Original() + "@sha256:1", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, `# syntax
+ARG IMAGE=golang GO_VERSION="1.11@sha256:1"
+FROM ${IMAGE}:${GO_VERSION} AS builder
+FROM alpine:3.8@sha256:1
+COPY --from=builder /app /app
+`, result)
+}
+
+func TestDockerfileRewriteOfSharedArgMustAgree(t *testing.T) {
+	file := "ARG TAG=1.15\nFROM nginx:$TAG\nFROM nginx:$TAG\n"
+
+	result, err := processWithArgs(t, New(), file, func(r dockref.Reference) (string, error) {
+		return "nginx:1.16", nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "ARG TAG=1.16\nFROM nginx:$TAG\nFROM nginx:$TAG\n", result)
+
+	file = "ARG TAG=1.15\nFROM nginx:$TAG\nFROM alpine:$TAG\n"
+	_, err = processWithArgs(t, New(), file, func(r dockref.Reference) (string, error) {
+		return r.Name() + ":" + r.Tag() + "-" + r.Name(), nil
+	})
+	assert.Error(t, err)
+}
+
+func TestDockerfileRewriteFailsForArgsWithoutDefault(t *testing.T) {
+	format, err := newDockerfileFormat().Configure(dockfmt.Options{BuildArgs: map[string]string{"TAG": "1.15"}})
+	assert.Nil(t, err)
+
+	for _, file := range []string{"ARG TAG\nFROM nginx:$TAG\n", "ARG TAG=1.14\nFROM nginx:$TAG\n", "FROM node:${V:-10}-alpine\n"} {
+		_, err := processWithArgs(t, format, file, func(r dockref.Reference) (string, error) {
+			return r.Original() + "@sha256:1", nil
+		})
+		assert.Error(t, err, file)
+	}
+}
+
+func TestDockerfileSkipsUnresolvableVariables(t *testing.T) {
+	file := "FROM golang:$GO_VERSION\nFROM nginx:1.15\n"
+
+	var found []string
+	result, err := processWithArgs(t, New(), file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx:1.15"}, found)
+	assert.Equal(t, file, result)
+}
+
+func TestDockerfileExcludesStageReferences(t *testing.T) {
+	file := `ARG BASE=Build
+FROM golang:1.11 AS build
+RUN go build
+FROM build as test
+RUN go test
+FROM ${BASE}
+FROM alpine:3.8
+COPY --from=build /app /app
+`
+
+	var found []string
+	result, err := processWithArgs(t, New(), file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return r.Original() + "@sha256:1", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"golang:1.11", "alpine:3.8"}, found)
+	assert.Equal(t, strings.Replace(strings.Replace(file, "golang:1.11", "golang:1.11@sha256:1", 1), "alpine:3.8", "alpine:3.8@sha256:1", 1), result)
+}
+
+func TestDockerfileImagesNamedLikeLaterStagesAreNoStageReferences(t *testing.T) {
+	file := "FROM build\nFROM golang AS build\n"
+
+	var found []string
+	_, err := processWithArgs(t, New(), file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build", "golang"}, found)
+}
+
+func TestStages(t *testing.T) {
+	file := `ARG BASE=builder
+FROM golang:1.11 AS builder
+FROM ${BASE} AS test
+FROM alpine:3.8
+FROM test
+`
+
+	stages, err := Stages(strings.NewReader(file))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Stage{
+		{Index: 0, Name: "builder", From: "golang:1.11", BaseStage: -1, Dependencies: []int{}},
+		{Index: 1, Name: "test", From: "builder", BaseStage: 0, Dependencies: []int{0}},
+		{Index: 2, From: "alpine:3.8", BaseStage: -1, Dependencies: []int{}},
+		{Index: 3, From: "test", BaseStage: 1, Dependencies: []int{1}},
+	}, stages)
+}
+
+func TestStagesRequireFrom(t *testing.T) {
+	_, err := Stages(strings.NewReader("RUN echo\n"))
+	assert.Error(t, err)
+}
+
+func TestDockerfileProcessesImagesInFlags(t *testing.T) {
+	file := `FROM golang:1.11 AS build
+COPY --from=alpine:3.8 /etc/ssl /etc/ssl
+RUN --mount=type=bind,from=menedev/tools:1.0,target=/tools \
+    --mount=type=cache,target=/root/.cache \
+    /tools/build
+FROM scratch
+COPY --from=build /app /app
+COPY --from=0 /app /app2
+COPY --chown=1000 --from=nginx:1.15 /etc/nginx /etc/nginx
+`
+
+	var found []string
+	result, err := processWithArgs(t, New(), file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return r.Original() + "@sha256:1", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"golang:1.11", "alpine:3.8", "menedev/tools:1.0", "scratch", "nginx:1.15"}, found)
+	assert.Equal(t, `FROM golang:1.11@sha256:1 AS build
+COPY --from=alpine:3.8@sha256:1 /etc/ssl /etc/ssl
+RUN --mount=type=bind,from=menedev/tools:1.0@sha256:1,target=/tools \
+    --mount=type=cache,target=/root/.cache \
+    /tools/build
+FROM scratch@sha256:1
+COPY --from=build /app /app
+COPY --from=0 /app /app2
+COPY --chown=1000 --from=nginx:1.15@sha256:1 /etc/nginx /etc/nginx
+`, result)
+}
+
+func TestDockerfileSkipsVariablesInFlags(t *testing.T) {
+	file := "ARG IMAGE=alpine\nFROM scratch\nCOPY --from=$IMAGE /a /a\n"
+
+	var found []string
+	_, err := processWithArgs(t, New(), file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"scratch"}, found)
+}
+
+func TestStagesIncludeFlagDependencies(t *testing.T) {
+	file := `FROM golang:1.11 AS build
+FROM node:10 AS assets
+FROM alpine:3.8
+COPY --from=build /app /app
+COPY --from=1 /assets /assets
+RUN --mount=type=bind,from=Build,target=/src ls /src
+COPY --from=nginx:1.15 /etc/nginx /etc/nginx
+`
+
+	stages, err := Stages(strings.NewReader(file))
+
+	assert.Nil(t, err)
+	assert.Len(t, stages, 3)
+	assert.Equal(t, []int{0, 1}, stages[2].Dependencies)
+	assert.Equal(t, -1, stages[2].BaseStage)
+}
+
+func TestDockerfileReportsLocations(t *testing.T) {
+	file := `ARG TAG=1.11
+from  golang:${TAG} AS build
+COPY --from=alpine:3.8 /etc/ssl /etc/ssl
+RUN --mount=type=bind,from=menedev/tools:1.0,target=/tools \
+    /tools/build
+FROM \
+  nginx:1.15
+`
+
+	format := New()
+	err := format.ValidateInput(log, strings.NewReader(file), "build/Dockerfile")
+	assert.Nil(t, err)
+
+	var locations []dockfmt.Location
+	err = format.Process(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference) (string, error) {
+		location, ok := dockfmt.LocationOf(r)
+		assert.True(t, ok)
+		locations = append(locations, location)
+		return "", nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []dockfmt.Location{
+		{File: "build/Dockerfile", Line: 2, Column: 7, EndLine: 2, EndColumn: 20},
+		{File: "build/Dockerfile", Line: 3, Column: 13, EndLine: 3, EndColumn: 23},
+		{File: "build/Dockerfile", Line: 4, Column: 28, EndLine: 4, EndColumn: 45},
+		{File: "build/Dockerfile", Line: 7, Column: 3, EndLine: 7, EndColumn: 13},
+	}, locations)
+}
@@ -0,0 +1,147 @@
+package dockerfile
+
+import (
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/pkg/errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Stage is a build stage of a Dockerfile, starting with a FROM instruction
+type Stage struct {
+	Index int
+
+	// Name is the name given with AS, empty for unnamed stages
+	Name string
+
+	// From is the base of the stage with ARGs expanded, either an image or the name of an earlier stage
+	From string
+
+	// BaseStage is the index of the stage this stage is based on, -1 if it is based on an image
+	BaseStage int
+
+	// Dependencies are the indexes of the earlier stages this stage depends on
+	Dependencies []int
+}
+
+// Stages returns the build stages of the Dockerfile with their dependencies
+func Stages(reader io.Reader) ([]Stage, error) {
+	result, err := parser.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := stagesOf(result.AST, argValues(globalArgs(result.AST), nil))
+	if len(stages) == 0 {
+		return nil, errors.Errorf("No FROM command found")
+	}
+	return stages, nil
+}
+
+func stagesOf(root *parser.Node, values map[string]string) []Stage {
+	stages := make([]Stage, 0)
+	// stage names are case insensitive
+	indexes := make(map[string]int)
+
+	for _, cmd := range root.Children {
+		if cmd.Value != "from" {
+			if len(stages) > 0 {
+				addFlagDependencies(&stages[len(stages)-1], cmd, stages)
+			}
+			continue
+		}
+
+		from := ""
+		if cmd.Next != nil {
+			from = cmd.Next.Value
+		}
+		if template, err := parseTemplate(from); err == nil {
+			from = expand(template, values)
+		}
+
+		stage := Stage{
+			Index:        len(stages),
+			From:         from,
+			BaseStage:    -1,
+			Dependencies: make([]int, 0),
+		}
+
+		if index, ok := indexes[strings.ToLower(from)]; ok {
+			stage.BaseStage = index
+			stage.Dependencies = append(stage.Dependencies, index)
+		}
+
+		if name := stageName(cmd); name != "" {
+			stage.Name = name
+			indexes[strings.ToLower(name)] = stage.Index
+		}
+
+		stages = append(stages, stage)
+	}
+
+	return stages
+}
+
+// addFlagDependencies adds the stages used by COPY --from and RUN --mount=from= to the dependencies of the stage
+func addFlagDependencies(stage *Stage, cmd *parser.Node, stages []Stage) {
+	for _, value := range imageFlags(cmd) {
+		index, ok := stageReference(stages, value)
+		if !ok || index == stage.Index || index >= len(stages) || containsInt(stage.Dependencies, index) {
+			continue
+		}
+		stage.Dependencies = append(stage.Dependencies, index)
+	}
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// stageReference returns the index of the stage the value of a --from flag refers to.
+// The value is either the index or the name of a stage, names are case insensitive
+func stageReference(stages []Stage, value string) (int, bool) {
+	if index, err := strconv.Atoi(value); err == nil {
+		return index, true
+	}
+
+	for _, stage := range stages {
+		if stage.Name != "" && strings.EqualFold(stage.Name, value) {
+			return stage.Index, true
+		}
+	}
+	return -1, false
+}
+
+// imageFlags returns the values of COPY --from=image and RUN --mount=from=image flags,
+// which can be images or stages
+func imageFlags(cmd *parser.Node) []string {
+	values := make([]string, 0)
+	for _, flag := range cmd.Flags {
+		switch {
+		case cmd.Value == "copy" && strings.HasPrefix(flag, "--from="):
+			values = append(values, unquote(strings.TrimPrefix(flag, "--from=")))
+		case cmd.Value == "run" && strings.HasPrefix(flag, "--mount="):
+			for _, field := range strings.Split(unquote(strings.TrimPrefix(flag, "--mount=")), ",") {
+				if strings.HasPrefix(field, "from=") {
+					values = append(values, strings.TrimPrefix(field, "from="))
+				}
+			}
+		}
+	}
+	return values
+}
+
+// stageName returns the name given in FROM image AS name
+func stageName(from *parser.Node) string {
+	image := from.Next
+	if image == nil || image.Next == nil || !strings.EqualFold(image.Next.Value, "as") || image.Next.Next == nil {
+		return ""
+	}
+	return image.Next.Next.Value
+}
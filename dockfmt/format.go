@@ -17,6 +17,7 @@ type ImageNameProcessor func(r dockref.Reference) (string, error)
 type FormatProcessor interface {
 	Process(imageNameProcessor ImageNameProcessor) error
 	WithWriter(writer io.Writer) FormatProcessor
+	Format() Format
 }
 
 var _ FormatProcessor = (*formatProcessor)(nil)
@@ -32,6 +33,10 @@ func (fp *formatProcessor) Process(imageNameProcessor ImageNameProcessor) error
 	return fp.format.Process(fp.log, fp.reader, fp.writer, imageNameProcessor)
 }
 
+func (fp *formatProcessor) Format() Format {
+	return fp.format
+}
+
 func (fp *formatProcessor) WithWriter(writer io.Writer) FormatProcessor {
 	fp.writer = writer
 	return fp
@@ -1,9 +1,11 @@
 package dockfmt
 
 import (
+	"bytes"
 	"github.com/hashicorp/go-multierror"
 	"github.com/sirupsen/logrus"
 	"io"
+	"io/ioutil"
 )
 
 type FormatProvider interface {
@@ -49,10 +51,16 @@ func IdentifyFormat(log logrus.FieldLogger, formatProvider FormatProvider, reade
 		"knownFormats": formats,
 	})
 
+	// every format reads the input from the start
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
 	var pinner Format
 	var pinnerErrors error
 	for _, p := range formats {
-		validationErr := p.ValidateInput(log, reader, filename)
+		validationErr := p.ValidateInput(log, bytes.NewReader(content), filename)
 		if validationErr != nil {
 			pinnerErrors = multierror.Append(pinnerErrors, validationErr)
 			log.WithFields(logrus.Fields{
@@ -7,6 +7,8 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"io"
+	"io/ioutil"
 	"testing"
 )
 
@@ -122,6 +124,32 @@ func TestIdentifyFormatWithSeveralMatchingAndSeveralNonMatchingFormats(t *testin
 	assert.Contains(t, ambiguousFormatError.Formats, matchingFormatMock2)
 }
 
+func TestIdentifyFormatPassesCompleteInputToEachFormat(t *testing.T) {
+	var contents []string
+	readAll := func(args mock.Arguments) {
+		content, _ := ioutil.ReadAll(args.Get(1).(io.Reader))
+		contents = append(contents, string(content))
+	}
+
+	formatMock1 := new(FormatMock)
+	formatMock1.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Run(readAll).Return(errors.New("error"))
+	formatMock2 := new(FormatMock)
+	formatMock2.On("ValidateInput", mock.Anything, mock.Anything, mock.Anything).Run(readAll).Return(nil)
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.On("Formats").Return([]Format{
+		formatMock1,
+		formatMock2,
+	})
+
+	logger := logrus.New()
+	logger.SetOutput(&bytes.Buffer{})
+
+	format, _ := IdentifyFormat(logger, formatProviderMock, bytes.NewBufferString("FROM nginx"), "filename")
+
+	assert.Equal(t, formatMock2, format)
+	assert.Equal(t, []string{"FROM nginx", "FROM nginx"}, contents)
+}
+
 func TestDefaultFormatProviderExits(t *testing.T) {
 	provider := DefaultFormatProvider()
 	assert.NotNil(t, provider)
@@ -0,0 +1,106 @@
+package githubactions
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+// actionDomain is the domain of action references, which keeps them apart from images
+const actionDomain = "github.com"
+
+// shaAlgorithm is the algorithm of the digest of actions pinned to a commit
+const shaAlgorithm = "sha1"
+
+var commitSHARegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+var actionRegexp = regexp.MustCompile(`^([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+)((?:/[A-Za-z0-9_./-]+)?)@([A-Za-z0-9_./-]+)$`)
+
+var _ dockref.Reference = (*actionReference)(nil)
+
+// actionReference is an action used like owner/repo@ref. It is matched like the image reference
+// github.com/owner/repo with the ref as tag or, when it is a commit SHA, as digest sha1:<commit>.
+// Actions in a directory of the repository and reusable workflows have the name of their repository
+type actionReference struct {
+	original string
+	action   string
+	ref      string
+	named    reference.Named
+}
+
+// parseActionReference parses a reference like actions/checkout@v2 or owner/repo/path@ref
+func parseActionReference(original string) (dockref.Reference, error) {
+	matches := actionRegexp.FindStringSubmatch(original)
+	if matches == nil {
+		return nil, errors.Errorf("'%s' is no action reference like owner/repo@ref", original)
+	}
+
+	// repositories on GitHub are case insensitive, image names are lower case
+	named, err := reference.ParseNormalizedNamed(actionDomain + "/" + strings.ToLower(matches[1]))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid action '%s'", original)
+	}
+
+	return actionReference{
+		original: original,
+		action:   matches[1] + matches[2],
+		ref:      matches[3],
+		named:    named,
+	}, nil
+}
+
+func (r actionReference) isCommit() bool {
+	return commitSHARegexp.MatchString(r.ref)
+}
+
+func (r actionReference) Name() string {
+	return r.named.Name()
+}
+
+func (r actionReference) Tag() string {
+	if r.isCommit() {
+		return ""
+	}
+	return r.ref
+}
+
+func (r actionReference) DigestString() string {
+	if r.isCommit() {
+		return shaAlgorithm + ":" + r.ref
+	}
+	return ""
+}
+
+func (r actionReference) Digest() digest.Digest {
+	return digest.Digest(r.DigestString())
+}
+
+func (r actionReference) Original() string {
+	return r.original
+}
+
+func (r actionReference) Domain() string {
+	return reference.Domain(r.named)
+}
+
+func (r actionReference) Path() string {
+	return reference.Path(r.named)
+}
+
+func (r actionReference) Named() reference.Named {
+	return r.named
+}
+
+// WithDigest returns the action pinned to the commit of a sha1 digest. Actions have only one ref, so the tag is replaced
+func (r actionReference) WithDigest(dig digest.Digest) (dockref.Reference, error) {
+	if dig.Algorithm() != shaAlgorithm || !commitSHARegexp.MatchString(dig.Hex()) {
+		return nil, errors.Errorf("Cannot pin '%s' to '%s': actions are pinned to commits", r.original, dig)
+	}
+	return parseActionReference(r.action + "@" + dig.Hex())
+}
+
+func (r actionReference) WithTag(tag string) (dockref.Reference, error) {
+	return parseActionReference(r.action + "@" + tag)
+}
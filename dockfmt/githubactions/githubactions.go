@@ -0,0 +1,161 @@
+package githubactions
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure ConfigurableFormat is implemented
+var _ dockfmt.ConfigurableFormat = (*githubActionsFormat)(nil)
+
+type githubActionsFormat struct {
+	filename string
+	content  []byte
+	images   []yamlfmt.ImageValue
+	// actionReferences enables matching actions like actions/checkout@v2
+	actionReferences bool
+}
+
+// dockerPrefix marks steps that run an image instead of an action, e.g. uses: docker://alpine:3.8
+const dockerPrefix = "docker://"
+
+func (format *githubActionsFormat) Name() string {
+	return "GitHub Actions"
+}
+
+func New() dockfmt.Format {
+	return newGithubActionsFormat()
+}
+
+func newGithubActionsFormat() *githubActionsFormat {
+	return new(githubActionsFormat)
+}
+
+// Configure returns a format that matches actions used like owner/repo@ref in addition to images when
+// ActionReferences is set. Actions are matched as image references in the domain github.com, actions pinned
+// to a commit SHA have the digest sha1:<commit>
+func (format *githubActionsFormat) Configure(options dockfmt.Options) (dockfmt.Format, error) {
+	configured := newGithubActionsFormat()
+	configured.actionReferences = options.ActionReferences
+	return configured, nil
+}
+
+func (format *githubActionsFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return err
+	}
+
+	root := yamlfmt.Root(&document)
+	if root == nil {
+		return errors.Errorf("No YAML document found")
+	}
+
+	jobs := yamlfmt.MappingValue(root, "jobs")
+	if yamlfmt.MappingValue(root, "on") == nil || jobs == nil || jobs.Kind != yaml.MappingNode {
+		return errors.Errorf("No workflow with on and jobs found")
+	}
+
+	images := make([]yamlfmt.ImageValue, 0)
+	for i := 1; i < len(jobs.Content); i += 2 {
+		job := yamlfmt.ResolveAlias(jobs.Content[i])
+		if job.Kind != yaml.MappingNode {
+			return errors.Errorf("Job '%s' in line %d is no mapping", jobs.Content[i-1].Value, jobs.Content[i-1].Line)
+		}
+		images = append(images, format.jobImages(job)...)
+	}
+
+	format.filename = filename
+	format.content = content
+	format.images = yamlfmt.Unique(images)
+
+	return nil
+}
+
+// jobImages returns the images of the container, the services and the steps of a job
+func (format *githubActionsFormat) jobImages(job *yaml.Node) []yamlfmt.ImageValue {
+	images := make([]yamlfmt.ImageValue, 0)
+	if image := containerImage(yamlfmt.MappingValue(job, "container")); image != nil {
+		images = append(images, yamlfmt.ImageValue{Node: image})
+	}
+
+	services := yamlfmt.MappingValue(job, "services")
+	if services != nil && services.Kind == yaml.MappingNode {
+		for i := 1; i < len(services.Content); i += 2 {
+			if image := containerImage(services.Content[i]); image != nil {
+				images = append(images, yamlfmt.ImageValue{Node: image})
+			}
+		}
+	}
+
+	// reusable workflows are called like actions
+	if uses := format.usesValue(job); uses != nil {
+		images = append(images, *uses)
+	}
+
+	steps := yamlfmt.MappingValue(job, "steps")
+	if steps != nil && steps.Kind == yaml.SequenceNode {
+		for _, step := range steps.Content {
+			if uses := format.usesValue(step); uses != nil {
+				images = append(images, *uses)
+			}
+		}
+	}
+
+	return images
+}
+
+// containerImage returns the image of a container, which is given as image or as mapping with image
+func containerImage(container *yaml.Node) *yaml.Node {
+	container = yamlfmt.ResolveAlias(container)
+	if container == nil {
+		return nil
+	}
+
+	if container.Kind == yaml.MappingNode {
+		container = yamlfmt.MappingValue(container, "image")
+	}
+
+	if container == nil || container.Kind != yaml.ScalarNode {
+		return nil
+	}
+	return container
+}
+
+// usesValue returns the image of uses: docker://image, or the action of uses: owner/repo@ref when action
+// references are enabled. Local actions like ./.github/actions/build are skipped
+func (format *githubActionsFormat) usesValue(step *yaml.Node) *yamlfmt.ImageValue {
+	uses := yamlfmt.MappingValue(step, "uses")
+	if uses == nil || uses.Kind != yaml.ScalarNode {
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(uses.Value, dockerPrefix):
+		return &yamlfmt.ImageValue{Node: uses, Prefix: dockerPrefix}
+	case format.actionReferences && !strings.HasPrefix(uses.Value, "."):
+		return &yamlfmt.ImageValue{Node: uses, Parse: parseActionReference}
+	default:
+		return nil
+	}
+}
+
+func (format *githubActionsFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return yamlfmt.ProcessImages(log, format.filename, format.content, format.images, w, imageNameProcessor)
+}
@@ -0,0 +1,159 @@
+package gitlabci
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*gitlabCIFormat)(nil)
+
+type gitlabCIFormat struct {
+	filename string
+	content  []byte
+	images   []yamlfmt.ImageValue
+}
+
+// globalKeywords are the top-level keys that are no jobs
+var globalKeywords = map[string]bool{
+	"default":       true,
+	"include":       true,
+	"stages":        true,
+	"variables":     true,
+	"workflow":      true,
+	"image":         true,
+	"services":      true,
+	"cache":         true,
+	"before_script": true,
+	"after_script":  true,
+}
+
+// jobKeywords identify jobs, every job runs a script, triggers a pipeline or extends a job that does
+var jobKeywords = []string{"script", "trigger", "extends"}
+
+func (format *gitlabCIFormat) Name() string {
+	return "GitLab CI"
+}
+
+func New() dockfmt.Format {
+	return newGitlabCIFormat()
+}
+
+func newGitlabCIFormat() *gitlabCIFormat {
+	return new(gitlabCIFormat)
+}
+
+func (format *gitlabCIFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return err
+	}
+
+	root := yamlfmt.Root(&document)
+	if root == nil || root.Kind != yaml.MappingNode {
+		return errors.Errorf("No YAML document found")
+	}
+
+	// the global image and services are deprecated, but still supported
+	images := jobImages(root)
+	images = append(images, jobImages(yamlfmt.MappingValue(root, "default"))...)
+
+	containsJob := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if globalKeywords[root.Content[i].Value] {
+			continue
+		}
+
+		job := yamlfmt.ResolveAlias(root.Content[i+1])
+
+		// hidden keys like .template are no jobs, but their images are used by jobs extending them
+		if strings.HasPrefix(root.Content[i].Value, ".") {
+			images = append(images, jobImages(job)...)
+			continue
+		}
+
+		if isJob(job) {
+			containsJob = true
+			images = append(images, jobImages(job)...)
+		}
+	}
+
+	if !containsJob {
+		return errors.Errorf("No GitLab CI jobs found")
+	}
+
+	format.filename = filename
+	format.content = content
+	format.images = yamlfmt.Unique(images)
+
+	return nil
+}
+
+func isJob(node *yaml.Node) bool {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return false
+	}
+
+	for _, keyword := range jobKeywords {
+		if yamlfmt.MappingValue(node, keyword) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// jobImages returns the image and services of a job or of default
+func jobImages(job *yaml.Node) []yamlfmt.ImageValue {
+	images := make([]yamlfmt.ImageValue, 0)
+	if image := imageName(yamlfmt.MappingValue(job, "image")); image != nil {
+		images = append(images, yamlfmt.ImageValue{Node: image})
+	}
+
+	services := yamlfmt.MappingValue(job, "services")
+	if services != nil && services.Kind == yaml.SequenceNode {
+		for _, service := range services.Content {
+			if image := imageName(service); image != nil {
+				images = append(images, yamlfmt.ImageValue{Node: image})
+			}
+		}
+	}
+
+	return images
+}
+
+// imageName returns the scalar naming the image, images and services are given as name or as mapping with name
+func imageName(node *yaml.Node) *yaml.Node {
+	node = yamlfmt.ResolveAlias(node)
+	if node == nil {
+		return nil
+	}
+
+	if node.Kind == yaml.MappingNode {
+		node = yamlfmt.MappingValue(node, "name")
+	}
+
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return nil
+	}
+	return node
+}
+
+func (format *gitlabCIFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return yamlfmt.ProcessImages(log, format.filename, format.content, format.images, w, imageNameProcessor)
+}
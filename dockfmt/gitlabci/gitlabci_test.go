@@ -0,0 +1,138 @@
+package gitlabci
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestGitlabCIName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "GitLab CI", name)
+}
+
+func processGitlabCI(t *testing.T, file string, imageNameProcessor func(r dockref.Reference) (string, error)) string {
+	format := New()
+	err := format.ValidateInput(log, strings.NewReader(file), ".gitlab-ci.yml")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.Process(log, strings.NewReader(file), buffer, imageNameProcessor)
+	assert.Nil(t, err)
+
+	return buffer.String()
+}
+
+func TestGitlabCIFindsImagesInOrder(t *testing.T) {
+	file := `image: ruby:2.5
+services:
+  - postgres:10
+default:
+  image:
+    name: alpine:3.8
+    entrypoint: [""]
+  services:
+    - name: redis:5
+      alias: cache
+stages: [build, test]
+.go:
+  image: golang:1.11
+build:
+  extends: .go
+  services:
+    - docker:dind
+  script: go build
+deploy:
+  image: $CI_REGISTRY_IMAGE:latest
+  trigger: downstream
+`
+
+	var found []string
+	processGitlabCI(t, file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Equal(t, []string{"ruby:2.5", "postgres:10", "alpine:3.8", "redis:5", "golang:1.11", "docker:dind"}, found)
+}
+
+func TestGitlabCIProcessPreservesFormatting(t *testing.T) {
+	file := `# pipeline
+.defaults: &defaults
+  image: &go "golang:1.11"   # build image
+  before_script:
+    - go version
+
+build:
+  <<: *defaults
+  script: go build
+
+test:
+  extends: .defaults
+  image: *go
+  services:
+    - name: 'postgres:10'
+      alias: db
+  script: go test
+`
+	expected := `# pipeline
+.defaults: &defaults
+  image: &go "golang:1.11@sha256:1"   # build image
+  before_script:
+    - go version
+
+build:
+  <<: *defaults
+  script: go build
+
+test:
+  extends: .defaults
+  image: *go
+  services:
+    - name: 'postgres:10@sha256:1'
+      alias: db
+  script: go test
+`
+
+	calls := 0
+	result := processGitlabCI(t, file, func(r dockref.Reference) (string, error) {
+		calls++
+		return r.Original() + "@sha256:1", nil
+	})
+
+	assert.Equal(t, expected, result)
+	assert.Equal(t, 2, calls)
+}
+
+func TestGitlabCIReportsLocations(t *testing.T) {
+	file := `build:
+  image: golang:1.11
+  services:
+    - name: "docker:dind"
+  script: go build
+`
+
+	var locations []dockfmt.Location
+	processGitlabCI(t, file, func(r dockref.Reference) (string, error) {
+		location, ok := dockfmt.LocationOf(r)
+		assert.True(t, ok)
+		locations = append(locations, location)
+		return "", nil
+	})
+
+	assert.Equal(t, []dockfmt.Location{
+		{File: ".gitlab-ci.yml", Line: 2, Column: 10, EndLine: 2, EndColumn: 21},
+		{File: ".gitlab-ci.yml", Line: 4, Column: 14, EndLine: 4, EndColumn: 25},
+	}, locations)
+}
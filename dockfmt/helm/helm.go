@@ -0,0 +1,313 @@
+package helm
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/compose"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure ConfigurableFormat is implemented
+var _ dockfmt.ConfigurableFormat = (*helmFormat)(nil)
+
+type helmFormat struct {
+	filename string
+	content  []byte
+	images   []splitImage
+	keys     keyNames
+}
+
+// the fields of an image that is split into several keys
+const (
+	fieldRegistry   = "registry"
+	fieldRepository = "repository"
+	fieldTag        = "tag"
+	fieldDigest     = "digest"
+)
+
+var fields = []string{fieldRegistry, fieldRepository, fieldTag, fieldDigest}
+
+// keyNames are the names of the keys of each field
+type keyNames map[string][]string
+
+func defaultKeys() keyNames {
+	result := make(keyNames)
+	for _, field := range fields {
+		result[field] = []string{field}
+	}
+	return result
+}
+
+// parseKeys returns the key names with additional key names of the fields registry, repository, tag and
+// digest, given like tag=version. The key names of the fields themselves are always used
+func parseKeys(pairs []string) (keyNames, error) {
+	var result *multierror.Error
+	configured := defaultKeys()
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			result = multierror.Append(result, errors.Errorf("Invalid key '%s': expected FIELD=KEY", pair))
+			continue
+		}
+		if _, ok := configured[parts[0]]; !ok {
+			result = multierror.Append(result, errors.Errorf("Invalid key '%s': the field must be one of %s", pair, strings.Join(fields, ", ")))
+			continue
+		}
+		configured[parts[0]] = append(configured[parts[0]], parts[1])
+	}
+
+	if result != nil {
+		return nil, result.ErrorOrNil()
+	}
+	return configured, nil
+}
+
+// splitImage is a mapping that contains the parts of an image reference, e.g.
+// image: {registry: docker.io, repository: library/nginx, tag: 1.19.2, digest: ""}
+type splitImage struct {
+	registry   *yaml.Node
+	repository *yaml.Node
+	tag        *yaml.Node
+	digest     *yaml.Node
+}
+
+func (format *helmFormat) Name() string {
+	return "Helm"
+}
+
+func New() dockfmt.Format {
+	return newHelmFormat()
+}
+
+func newHelmFormat() *helmFormat {
+	return &helmFormat{keys: defaultKeys()}
+}
+
+// Configure returns a format that also uses the key names of HelmKeys
+func (format *helmFormat) Configure(options dockfmt.Options) (dockfmt.Format, error) {
+	keys, err := parseKeys(options.HelmKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	configured := newHelmFormat()
+	configured.keys = keys
+	return configured, nil
+}
+
+func (format *helmFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return err
+	}
+
+	root := yamlfmt.Root(&document)
+	if root == nil || root.Kind != yaml.MappingNode {
+		return errors.Errorf("No YAML document found")
+	}
+
+	// templates of charts render Kubernetes resources, values are no resources
+	if yamlfmt.ScalarValue(root, "apiVersion") != "" && yamlfmt.ScalarValue(root, "kind") != "" {
+		return errors.Errorf("Kubernetes resources are no chart values")
+	}
+
+	// values can have services like docker-compose files, but no services with image names
+	if compose.New().ValidateInput(log, bytes.NewReader(content), filename) == nil {
+		return errors.Errorf("docker-compose files are no chart values")
+	}
+
+	candidates := make([]splitImage, 0)
+	format.keys.findSplitImages(root, make(map[*yaml.Node]bool), &candidates)
+	if len(candidates) == 0 {
+		return errors.Errorf("No images with %s and %s or %s found", fieldRepository, fieldTag, fieldDigest)
+	}
+
+	// a chart uses its appVersion for an empty tag, which is unknown here
+	images := make([]splitImage, 0, len(candidates))
+	for _, image := range candidates {
+		if value(image.tag) == "" && value(image.digest) == "" {
+			log.Debugf("Skipping image '%s' at line %d: no tag or digest", image.repository.Value, image.repository.Line)
+			continue
+		}
+		images = append(images, image)
+	}
+
+	format.filename = filename
+	format.content = content
+	format.images = images
+
+	return nil
+}
+
+// findSplitImages walks the mappings and sequences below node and collects the mappings with a repository
+// and a tag or digest. Aliases are followed once
+func (keys keyNames) findSplitImages(node *yaml.Node, visited map[*yaml.Node]bool, images *[]splitImage) {
+	node = yamlfmt.ResolveAlias(node)
+	if node == nil || visited[node] {
+		return
+	}
+	visited[node] = true
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		if image, ok := keys.asSplitImage(node); ok {
+			*images = append(*images, image)
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			keys.findSplitImages(node.Content[i], visited, images)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			keys.findSplitImages(item, visited, images)
+		}
+	}
+}
+
+func (keys keyNames) asSplitImage(mapping *yaml.Node) (splitImage, bool) {
+	image := splitImage{
+		registry:   keys.field(mapping, fieldRegistry),
+		repository: keys.field(mapping, fieldRepository),
+		tag:        keys.field(mapping, fieldTag),
+		digest:     keys.field(mapping, fieldDigest),
+	}
+
+	if value(image.repository) == "" || image.tag == nil && image.digest == nil {
+		return image, false
+	}
+	return image, true
+}
+
+// field returns the scalar of the first key of the field in the mapping
+func (keys keyNames) field(mapping *yaml.Node, name string) *yaml.Node {
+	for _, key := range keys[name] {
+		node := yamlfmt.ResolveAlias(yamlfmt.MappingValue(mapping, key))
+		if node != nil && node.Kind == yaml.ScalarNode {
+			return node
+		}
+	}
+	return nil
+}
+
+// value returns the value of the scalar, null scalars like tag: ~ are empty
+func value(node *yaml.Node) string {
+	if node == nil || node.Tag == "!!null" {
+		return ""
+	}
+	return node.Value
+}
+
+// splitTag returns the tag and the digest of a tag value like 1.19.2@sha256:...
+func (image splitImage) splitTag() (tag string, digest string) {
+	parts := strings.SplitN(value(image.tag), "@", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+// reference returns the reference like charts render it, [registry/]repository[:tag][@digest]
+func (image splitImage) reference() string {
+	result := value(image.repository)
+	if registry := value(image.registry); registry != "" {
+		result = strings.TrimSuffix(registry, "/") + "/" + result
+	}
+
+	tag, digest := image.splitTag()
+	if tag != "" {
+		result += ":" + tag
+	}
+	if value(image.digest) != "" {
+		digest = value(image.digest)
+	}
+	if digest != "" {
+		result += "@" + digest
+	}
+	return result
+}
+
+func (format *helmFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	edits := make([]yamlfmt.Edit, 0)
+	for _, image := range format.images {
+		original := image.reference()
+		log.Infof("Found image %s", original)
+
+		ref, err := dockref.FromOriginal(original)
+		if err != nil {
+			log.Warnf("Skipping image '%s' at line %d: %s", original, image.repository.Line, err.Error())
+			continue
+		}
+
+		location := yamlfmt.NodeLocation(format.filename, format.content, image.repository)
+		canonicalString, err := imageNameProcessor(dockfmt.LocatedReferenceNew(ref, location))
+		if err != nil {
+			return err
+		}
+
+		if canonicalString == "" || canonicalString == original {
+			continue
+		}
+
+		log.Infof("Pinning '%s' as '%s'", original, canonicalString)
+		imageEdits, err := image.edits(ref, canonicalString)
+		if err != nil {
+			return err
+		}
+		edits = append(edits, imageEdits...)
+	}
+
+	return yamlfmt.ApplyEdits(log, format.content, edits, w)
+}
+
+// edits returns the changes of the tag and digest keys that turn the image into canonicalString.
+// Registry and repository are kept, so the name must not change. Without a digest key the digest
+// is appended to the tag
+func (image splitImage) edits(ref dockref.Reference, canonicalString string) ([]yamlfmt.Edit, error) {
+	canonical, err := dockref.FromOriginal(canonicalString)
+	if err != nil {
+		return nil, err
+	}
+
+	if canonical.Name() != ref.Name() {
+		return nil, errors.Errorf("Cannot rewrite '%s' in line %d as '%s': only the %s and %s keys are changed",
+			ref.Original(), image.repository.Line, canonicalString, fieldTag, fieldDigest)
+	}
+
+	tag := canonical.Tag()
+	hasDigestKey := image.digest != nil && image.digest.Tag != "!!null"
+	if !hasDigestKey && canonical.DigestString() != "" {
+		tag += "@" + canonical.DigestString()
+	}
+
+	edits := make([]yamlfmt.Edit, 0)
+	if tag != value(image.tag) {
+		if image.tag == nil || image.tag.Tag == "!!null" {
+			return nil, errors.Errorf("Cannot rewrite '%s' in line %d as '%s': no %s key",
+				ref.Original(), image.repository.Line, canonicalString, fieldTag)
+		}
+		edits = append(edits, yamlfmt.ScalarEdit(image.tag, tag))
+	}
+
+	if hasDigestKey && canonical.DigestString() != image.digest.Value {
+		edits = append(edits, yamlfmt.ScalarEdit(image.digest, canonical.DigestString()))
+	}
+
+	return edits, nil
+}
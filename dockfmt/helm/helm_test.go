@@ -0,0 +1,145 @@
+package helm
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const digest = "sha256:2a03a6059f21e150ae84b0973863609494aad70f0a80eaeb64bddd8d92465812"
+
+const values = `replicaCount: 1
+image:
+  repository: nginx   # web server
+  tag: 1.19.2
+  pullPolicy: IfNotPresent
+metrics:
+  image:
+    registry: docker.io
+    repository: bitnami/nginx-exporter
+    tag: "0.8.0"
+    digest: ""
+sidecars:
+  - name: proxy
+    image: &proxy
+      repository: envoyproxy/envoy
+      tag: v1.16.0
+  - name: copy
+    image: *proxy
+init:
+  image:
+    repository: busybox
+    tag: ""
+`
+
+func processHelm(t *testing.T, format dockfmt.Format, file string, imageNameProcessor func(r dockref.Reference) (string, error)) string {
+	err := format.ValidateInput(log, strings.NewReader(file), "values.yaml")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.Process(log, strings.NewReader(file), buffer, imageNameProcessor)
+	assert.Nil(t, err)
+
+	return buffer.String()
+}
+
+func TestHelmFindsImagesInOrder(t *testing.T) {
+	var found []string
+	processHelm(t, New(), values, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Equal(t, []string{
+		"nginx:1.19.2",
+		"docker.io/bitnami/nginx-exporter:0.8.0",
+		"envoyproxy/envoy:v1.16.0",
+	}, found)
+}
+
+func TestHelmPinWritesDigestKey(t *testing.T) {
+	result := processHelm(t, New(), values, func(r dockref.Reference) (string, error) {
+		return r.Original() + "@" + digest, nil
+	})
+
+	expected := strings.NewReplacer(
+		"tag: 1.19.2", "tag: 1.19.2@"+digest,
+		`digest: ""`, `digest: "`+digest+`"`,
+		"tag: v1.16.0", "tag: v1.16.0@"+digest,
+	).Replace(values)
+	assert.Equal(t, expected, result)
+}
+
+func TestHelmUpdateWritesTag(t *testing.T) {
+	file := "image:\n  repository: nginx\n  tag: 1.19.2@" + digest + "\n" +
+		"exporter:\n  repository: nginx/nginx-prometheus-exporter\n  tag: '0.8.0'\n  digest: " + digest + "\n"
+
+	result := processHelm(t, New(), file, func(r dockref.Reference) (string, error) {
+		if r.Name() == "docker.io/library/nginx" {
+			return "nginx:1.20", nil
+		}
+		return "nginx/nginx-prometheus-exporter:0.9.0", nil
+	})
+
+	expected := "image:\n  repository: nginx\n  tag: \"1.20\"\n" +
+		"exporter:\n  repository: nginx/nginx-prometheus-exporter\n  tag: '0.9.0'\n  digest: \"\"\n"
+	assert.Equal(t, expected, result)
+}
+
+func TestHelmRefusesToChangeName(t *testing.T) {
+	file := "image:\n  repository: nginx\n  tag: 1.19.2\n"
+
+	format := New()
+	err := format.ValidateInput(log, strings.NewReader(file), "values.yaml")
+	assert.Nil(t, err)
+
+	err = format.Process(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference) (string, error) {
+		return "alpine:3.8", nil
+	})
+	assert.Error(t, err)
+}
+
+func TestHelmConfigureKeys(t *testing.T) {
+	format, err := New().(dockfmt.ConfigurableFormat).Configure(dockfmt.Options{HelmKeys: []string{"repository=name", "tag=version"}})
+	assert.Nil(t, err)
+
+	var found []string
+	processHelm(t, format, "image:\n  name: nginx\n  version: 1.19.2\n", func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+	assert.Equal(t, []string{"nginx:1.19.2"}, found)
+
+	format, err = New().(dockfmt.ConfigurableFormat).Configure(dockfmt.Options{HelmKeys: []string{"tag", "label=version"}})
+	assert.Nil(t, format)
+	assert.Error(t, err)
+}
+
+func TestHelmReportsLocations(t *testing.T) {
+	file := `image:
+  repository: nginx
+  tag: 1.19.2
+`
+
+	var locations []dockfmt.Location
+	processHelm(t, New(), file, func(r dockref.Reference) (string, error) {
+		location, ok := dockfmt.LocationOf(r)
+		assert.True(t, ok)
+		locations = append(locations, location)
+		return "", nil
+	})
+
+	assert.Equal(t, []dockfmt.Location{
+		{File: "values.yaml", Line: 2, Column: 15, EndLine: 2, EndColumn: 20},
+	}, locations)
+}
@@ -0,0 +1,133 @@
+package kubernetes
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is the subset of JSONPath used by kubectl that selects fields and array elements,
+// e.g. {.spec.steps[*].image}
+type jsonPath struct {
+	expression string
+	segments   []pathSegment
+}
+
+type pathSegment struct {
+	field string
+	// index is the selected array element, -1 selects all elements
+	index   int
+	isIndex bool
+}
+
+func parseJSONPath(expression string) (jsonPath, error) {
+	path := jsonPath{expression: expression}
+
+	rest := strings.TrimSpace(expression)
+	if strings.HasPrefix(rest, "{") && strings.HasSuffix(rest, "}") {
+		rest = rest[1 : len(rest)-1]
+	}
+	rest = strings.TrimPrefix(rest, "$")
+
+	if rest == "" {
+		return path, errors.Errorf("Empty JSONPath '%s'", expression)
+	}
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			return path, errors.Errorf("Recursive descent is not supported in JSONPath '%s'", expression)
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			field := rest[1 : end+1]
+			if field == "" {
+				return path, errors.Errorf("Empty field name in JSONPath '%s'", expression)
+			}
+			path.segments = append(path.segments, pathSegment{field: field})
+			rest = rest[end+1:]
+		case rest[0] == '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return path, errors.Errorf("Missing ] in JSONPath '%s'", expression)
+			}
+			segment, err := parseBracket(rest[1:end])
+			if err != nil {
+				return path, errors.Wrapf(err, "Invalid JSONPath '%s'", expression)
+			}
+			path.segments = append(path.segments, segment)
+			rest = rest[end+1:]
+		default:
+			return path, errors.Errorf("Unexpected '%s' in JSONPath '%s'", rest, expression)
+		}
+	}
+
+	return path, nil
+}
+
+func parseBracket(content string) (pathSegment, error) {
+	if content == "*" {
+		return pathSegment{index: -1, isIndex: true}, nil
+	}
+
+	if len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0] {
+		return pathSegment{field: content[1 : len(content)-1]}, nil
+	}
+
+	index, err := strconv.Atoi(content)
+	if err != nil || index < 0 {
+		return pathSegment{}, errors.Errorf("Unsupported selector [%s]", content)
+	}
+	return pathSegment{index: index, isIndex: true}, nil
+}
+
+// find returns all scalars selected by the path
+func (path jsonPath) find(root *yaml.Node) []*yaml.Node {
+	nodes := []*yaml.Node{root}
+	for _, segment := range path.segments {
+		next := make([]*yaml.Node, 0)
+		for _, node := range nodes {
+			next = append(next, segment.find(node)...)
+		}
+		nodes = next
+	}
+
+	scalars := make([]*yaml.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Kind == yaml.ScalarNode {
+			scalars = append(scalars, node)
+		}
+	}
+	return scalars
+}
+
+func (segment pathSegment) find(node *yaml.Node) []*yaml.Node {
+	if !segment.isIndex {
+		if value := yamlfmt.MappingValue(node, segment.field); value != nil {
+			return []*yaml.Node{value}
+		}
+		return nil
+	}
+
+	node = yamlfmt.ResolveAlias(node)
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	if segment.index < 0 {
+		elements := make([]*yaml.Node, 0, len(node.Content))
+		for _, element := range node.Content {
+			elements = append(elements, yamlfmt.ResolveAlias(element))
+		}
+		return elements
+	}
+
+	if segment.index < len(node.Content) {
+		return []*yaml.Node{yamlfmt.ResolveAlias(node.Content[segment.index])}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package kubernetes
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	expectations := map[string][]pathSegment{
+		"{.spec.image}":            {{field: "spec"}, {field: "image"}},
+		"$.spec.steps[*].image":    {{field: "spec"}, {field: "steps"}, {index: -1, isIndex: true}, {field: "image"}},
+		".spec.steps[1].image":     {{field: "spec"}, {field: "steps"}, {index: 1, isIndex: true}, {field: "image"}},
+		"{.metadata['app.image']}": {{field: "metadata"}, {field: "app.image"}},
+	}
+
+	for expression, expected := range expectations {
+		t.Run(expression, func(t *testing.T) {
+			path, err := parseJSONPath(expression)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, path.segments)
+		})
+	}
+
+	for _, expression := range []string{"", "{}", "spec", ".spec..image", ".spec[", ".spec[-1]", ".spec[?(@.a)]", ".spec.", "{.spec[*]x}"} {
+		t.Run("Rejects "+expression, func(t *testing.T) {
+			_, err := parseJSONPath(expression)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestJSONPathFind(t *testing.T) {
+	var document yaml.Node
+	yaml.Unmarshal([]byte("spec:\n  steps:\n  - image: a\n  - image: b\n  - name: c\n  image: d\n"), &document)
+	root := document.Content[0]
+
+	values := func(nodes []*yaml.Node) []string {
+		result := make([]string, 0)
+		for _, node := range nodes {
+			result = append(result, node.Value)
+		}
+		return result
+	}
+
+	expectations := map[string][]string{
+		".spec.steps[*].image": {"a", "b"},
+		".spec.steps[1].image": {"b"},
+		".spec.steps[5].image": {},
+		".spec.image":          {"d"},
+		".spec.steps":          {},
+		".missing.image":       {},
+	}
+
+	for expression, expected := range expectations {
+		t.Run(expression, func(t *testing.T) {
+			path, err := parseJSONPath(expression)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, values(path.find(root)))
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package kubernetes
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure ConfigurableFormat is implemented
+var _ dockfmt.ConfigurableFormat = (*kubernetesFormat)(nil)
+
+type kubernetesFormat struct {
+	filename string
+	content  []byte
+	images   []yamlfmt.ImageValue
+	// customImagePaths select images in resources of other kinds, e.g. custom resources
+	customImagePaths []jsonPath
+}
+
+// podSpecPaths are the locations of the pod spec in kinds that create pods
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"PodTemplate":           {"template", "spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+var containerFields = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// parseImagePaths parses JSONPath expressions like {.spec.steps[*].image} that select images
+// in resources that are not known to contain pod specs, e.g. custom resources
+func parseImagePaths(expressions []string) ([]jsonPath, error) {
+	var result *multierror.Error
+	paths := make([]jsonPath, 0, len(expressions))
+	for _, expression := range expressions {
+		path, err := parseJSONPath(expression)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	if result != nil {
+		return nil, result.ErrorOrNil()
+	}
+	return paths, nil
+}
+
+func (format *kubernetesFormat) Name() string {
+	return "Kubernetes"
+}
+
+func New() dockfmt.Format {
+	return newKubernetesFormat()
+}
+
+func newKubernetesFormat() *kubernetesFormat {
+	return new(kubernetesFormat)
+}
+
+// Configure returns a format that also finds the images selected by the JSONPath expressions of
+// KubernetesImagePaths
+func (format *kubernetesFormat) Configure(options dockfmt.Options) (dockfmt.Format, error) {
+	paths, err := parseImagePaths(options.KubernetesImagePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	configured := newKubernetesFormat()
+	configured.customImagePaths = paths
+	return configured, nil
+}
+
+func (format *kubernetesFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	documents, err := yamlfmt.DecodeAll(content)
+	if err != nil {
+		return err
+	}
+
+	images := make([]yamlfmt.ImageValue, 0)
+	resources := 0
+	for _, document := range documents {
+		root := yamlfmt.Root(document)
+		if root == nil {
+			// e.g. a trailing ---
+			continue
+		}
+
+		if !isResource(root) {
+			return errors.Errorf("Document in line %d is no Kubernetes resource", document.Line)
+		}
+
+		resources++
+		images = append(images, format.resourceImages(root)...)
+	}
+
+	if resources == 0 {
+		return errors.Errorf("No Kubernetes resources found")
+	}
+
+	format.filename = filename
+	format.content = content
+	format.images = yamlfmt.Unique(images)
+
+	return nil
+}
+
+// kustomizeGroup is the API group of kustomization files, which are read by kustomize and not applied
+const kustomizeGroup = "kustomize.config.k8s.io/"
+
+func isResource(node *yaml.Node) bool {
+	apiVersion := yamlfmt.ScalarValue(node, "apiVersion")
+	return apiVersion != "" && !strings.HasPrefix(apiVersion, kustomizeGroup) && yamlfmt.ScalarValue(node, "kind") != ""
+}
+
+func (format *kubernetesFormat) resourceImages(resource *yaml.Node) []yamlfmt.ImageValue {
+	kind := yamlfmt.ScalarValue(resource, "kind")
+
+	if kind == "List" {
+		images := make([]yamlfmt.ImageValue, 0)
+		items := yamlfmt.MappingValue(resource, "items")
+		if items != nil && items.Kind == yaml.SequenceNode {
+			for _, item := range items.Content {
+				images = append(images, format.resourceImages(yamlfmt.ResolveAlias(item))...)
+			}
+		}
+		return images
+	}
+
+	if path, ok := podSpecPaths[kind]; ok {
+		podSpec := resource
+		for _, key := range path {
+			podSpec = yamlfmt.MappingValue(podSpec, key)
+		}
+		return podSpecImages(podSpec)
+	}
+
+	images := make([]yamlfmt.ImageValue, 0)
+	for _, path := range format.customImagePaths {
+		for _, node := range path.find(resource) {
+			images = append(images, yamlfmt.ImageValue{Node: node})
+		}
+	}
+	return images
+}
+
+func podSpecImages(podSpec *yaml.Node) []yamlfmt.ImageValue {
+	images := make([]yamlfmt.ImageValue, 0)
+	for _, field := range containerFields {
+		containers := yamlfmt.MappingValue(podSpec, field)
+		if containers == nil || containers.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, container := range containers.Content {
+			image := yamlfmt.MappingValue(container, "image")
+			if image != nil && image.Kind == yaml.ScalarNode {
+				images = append(images, yamlfmt.ImageValue{Node: image})
+			}
+		}
+	}
+	return images
+}
+
+func (format *kubernetesFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return yamlfmt.ProcessImages(log, format.filename, format.content, format.images, w, imageNameProcessor)
+}
@@ -0,0 +1,253 @@
+package kubernetes
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestKubernetesName(t *testing.T) {
+	format := New()
+	name := format.Name()
+	assert.Equal(t, "Kubernetes", name)
+}
+
+func TestKubernetesFormatRejectsNonKubernetesFiles(t *testing.T) {
+	files := map[string]string{
+		"empty":        ``,
+		"only ---":     "---\n",
+		"dockerfile":   "FROM nginx\nRUN echo\n",
+		"compose":      "services:\n  web:\n    image: nginx\n",
+		"missing kind": "apiVersion: v1\nmetadata:\n  name: x\n",
+		"mixed":        "apiVersion: v1\nkind: Pod\n---\nservices:\n  web:\n    image: nginx\n",
+		"invalid yaml": "apiVersion: [\n",
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			format := New()
+			err := format.ValidateInput(log, strings.NewReader(file), "anything")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKubernetesFormatAcceptsResourcesWithoutImages(t *testing.T) {
+	file := "apiVersion: v1\nkind: ConfigMap\ndata:\n  key: value\n---\n"
+	format := New()
+	err := format.ValidateInput(log, strings.NewReader(file), "anything")
+	assert.Nil(t, err)
+}
+
+func TestKubernetesFormatRejectsKustomizations(t *testing.T) {
+	files := map[string]string{
+		"kustomization": "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources: [pod.yaml]\n",
+		"component":     "apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\nimages:\n  - name: nginx\n",
+	}
+
+	for name, file := range files {
+		t.Run(name, func(t *testing.T) {
+			format := New()
+			err := format.ValidateInput(log, strings.NewReader(file), "kustomization.yaml")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func processKubernetes(t *testing.T, format dockfmt.Format, file string, imageNameProcessor func(r dockref.Reference) (string, error)) string {
+	err := format.ValidateInput(log, strings.NewReader(file), "deployment.yaml")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.Process(log, strings.NewReader(file), buffer, imageNameProcessor)
+	assert.Nil(t, err)
+
+	return buffer.String()
+}
+
+func collectImages(t *testing.T, format dockfmt.Format, file string) []string {
+	found := make([]string, 0)
+	processKubernetes(t, format, file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+	return found
+}
+
+func TestKubernetesFindsImagesOfPodSpecKinds(t *testing.T) {
+	workloads := map[string]string{
+		"Pod":         "apiVersion: v1\nkind: Pod\nspec:\n  containers:\n  - image: nginx:1\n",
+		"Deployment":  "apiVersion: apps/v1\nkind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n      - image: nginx:1\n",
+		"StatefulSet": "apiVersion: apps/v1\nkind: StatefulSet\nspec:\n  template:\n    spec:\n      containers:\n      - image: nginx:1\n",
+		"DaemonSet":   "apiVersion: apps/v1\nkind: DaemonSet\nspec:\n  template:\n    spec:\n      containers:\n      - image: nginx:1\n",
+		"ReplicaSet":  "apiVersion: apps/v1\nkind: ReplicaSet\nspec:\n  template:\n    spec:\n      containers:\n      - image: nginx:1\n",
+		"Job":         "apiVersion: batch/v1\nkind: Job\nspec:\n  template:\n    spec:\n      containers:\n      - image: nginx:1\n",
+		"CronJob":     "apiVersion: batch/v1beta1\nkind: CronJob\nspec:\n  jobTemplate:\n    spec:\n      template:\n        spec:\n          containers:\n          - image: nginx:1\n",
+		"List":        "apiVersion: v1\nkind: List\nitems:\n- apiVersion: v1\n  kind: Pod\n  spec:\n    containers:\n    - image: nginx:1\n",
+	}
+
+	for kind, file := range workloads {
+		t.Run(kind, func(t *testing.T) {
+			assert.Equal(t, []string{"nginx:1"}, collectImages(t, New(), file))
+		})
+	}
+}
+
+func TestKubernetesFindsAllContainerKinds(t *testing.T) {
+	file := `apiVersion: v1
+kind: Pod
+spec:
+  initContainers:
+  - name: init
+    image: busybox:1.29
+  containers:
+  - name: web
+    image: nginx:1.15
+  - name: sidecar
+    image: envoyproxy/envoy:v1.8.0
+  ephemeralContainers:
+  - name: debug
+    image: alpine:3.8
+`
+
+	assert.Equal(t, []string{"busybox:1.29", "nginx:1.15", "envoyproxy/envoy:v1.8.0", "alpine:3.8"}, collectImages(t, New(), file))
+}
+
+func TestKubernetesIgnoresImagesOutsideOfContainers(t *testing.T) {
+	file := "apiVersion: v1\nkind: ConfigMap\ndata:\n  image: nginx:1.15\n"
+
+	assert.Empty(t, collectImages(t, New(), file))
+}
+
+func TestKubernetesCustomImagePaths(t *testing.T) {
+	format, err := New().(dockfmt.ConfigurableFormat).Configure(dockfmt.Options{
+		KubernetesImagePaths: []string{"{.spec.steps[*].image}", "$.spec.sidecar['image']"},
+	})
+	assert.Nil(t, err)
+
+	file := `apiVersion: tekton.dev/v1alpha1
+kind: Task
+spec:
+  steps:
+  - image: golang:1.11
+  - image: alpine:3.8
+  sidecar:
+    image: nginx:1.15
+---
+apiVersion: v1
+kind: Pod
+spec:
+  steps:
+  - image: ignored:1
+  containers:
+  - image: busybox:1.29
+`
+
+	assert.Equal(t, []string{"golang:1.11", "alpine:3.8", "nginx:1.15", "busybox:1.29"}, collectImages(t, format, file))
+}
+
+func TestKubernetesConfigureRejectsInvalidPaths(t *testing.T) {
+	format, err := New().(dockfmt.ConfigurableFormat).Configure(dockfmt.Options{KubernetesImagePaths: []string{"{.spec..image}"}})
+	assert.Error(t, err)
+	assert.Nil(t, format)
+}
+
+func TestKubernetesProcessPreservesDocuments(t *testing.T) {
+	file := `# web tier
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: "nginx:1.15"   # pinned by CI
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+spec:
+  template:
+    spec:
+      containers:
+      - image: alpine:3.8
+        args: ["nginx:1.15"]
+`
+	expected := `# web tier
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: "nginx:1.15@sha256:1"   # pinned by CI
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+spec:
+  template:
+    spec:
+      containers:
+      - image: alpine:3.8@sha256:1
+        args: ["nginx:1.15"]
+`
+
+	result := processKubernetes(t, New(), file, func(r dockref.Reference) (string, error) {
+		return r.Original() + "@sha256:1", nil
+	})
+
+	assert.Equal(t, expected, result)
+}
+
+func TestKubernetesReportsLocations(t *testing.T) {
+	file := `apiVersion: v1
+kind: Pod
+spec:
+  containers:
+  - name: web
+    image: nginx:1.15
+---
+apiVersion: v1
+kind: Pod
+spec:
+  initContainers:
+  - image: 'alpine:3.8'
+`
+
+	var locations []dockfmt.Location
+	processKubernetes(t, New(), file, func(r dockref.Reference) (string, error) {
+		location, ok := dockfmt.LocationOf(r)
+		assert.True(t, ok)
+		locations = append(locations, location)
+		return "", nil
+	})
+
+	assert.Equal(t, []dockfmt.Location{
+		{File: "deployment.yaml", Line: 6, Column: 12, EndLine: 6, EndColumn: 22},
+		{File: "deployment.yaml", Line: 12, Column: 13, EndLine: 12, EndColumn: 23},
+	}, locations)
+}
@@ -0,0 +1,176 @@
+package kustomize
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	keyName    = "name"
+	keyNewName = "newName"
+	keyNewTag  = "newTag"
+	keyDigest  = "digest"
+)
+
+// imageEntry is an entry of images, which overrides the name, tag or digest of the images named name
+type imageEntry struct {
+	mapping *yaml.Node
+	name    *yaml.Node
+	newName *yaml.Node
+	newTag  *yaml.Node
+	digest  *yaml.Node
+}
+
+func imageEntryOf(mapping *yaml.Node) (imageEntry, error) {
+	if mapping.Kind != yaml.MappingNode {
+		return imageEntry{}, errors.Errorf("Image in line %d is no mapping", mapping.Line)
+	}
+
+	entry := imageEntry{
+		mapping: mapping,
+		name:    scalar(mapping, keyName),
+		newName: scalar(mapping, keyNewName),
+		newTag:  scalar(mapping, keyNewTag),
+		digest:  scalar(mapping, keyDigest),
+	}
+
+	if value(entry.name) == "" {
+		return imageEntry{}, errors.Errorf("Image in line %d has no %s", mapping.Line, keyName)
+	}
+	return entry, nil
+}
+
+func scalar(mapping *yaml.Node, key string) *yaml.Node {
+	node := yamlfmt.MappingValue(mapping, key)
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return nil
+	}
+	return node
+}
+
+// value returns the value of the scalar, null scalars like digest: ~ are empty
+func value(node *yaml.Node) string {
+	if node == nil || node.Tag == "!!null" {
+		return ""
+	}
+	return node.Value
+}
+
+// overridesVersion is true when the entry sets the tag or digest, so the reference doesn't depend on the resources
+func (entry imageEntry) overridesVersion() bool {
+	return value(entry.newTag) != "" || value(entry.digest) != ""
+}
+
+// nameNode returns the scalar of the effective name
+func (entry imageEntry) nameNode() *yaml.Node {
+	if value(entry.newName) != "" {
+		return entry.newName
+	}
+	return entry.name
+}
+
+// reference returns the effective reference newName:newTag@digest, using name when there is no newName
+func (entry imageEntry) reference() string {
+	result := value(entry.nameNode())
+	if tag := value(entry.newTag); tag != "" {
+		result += ":" + tag
+	}
+	if dig := value(entry.digest); dig != "" {
+		result += "@" + dig
+	}
+	return result
+}
+
+// matches checks whether the entry overrides the image, the names are compared normalized, so nginx
+// matches docker.io/library/nginx:1.19
+func (entry imageEntry) matches(ref dockref.Reference) bool {
+	name, err := dockref.FromOriginal(entry.name.Value)
+	return err == nil && name.Name() == ref.Name()
+}
+
+func matchingEntry(entries []imageEntry, ref dockref.Reference) *imageEntry {
+	for i := range entries {
+		if entries[i].matches(ref) {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// apply returns the image of a resource as kustomize overrides it. A new tag replaces the digest and a
+// new digest replaces the tag of the resource. The location of the image is kept
+func (entry imageEntry) apply(ref dockref.Reference) (dockref.Reference, error) {
+	tag, dig := ref.Tag(), ref.Digest()
+	if entry.overridesVersion() {
+		tag, dig = value(entry.newTag), digest.Digest(value(entry.digest))
+	}
+
+	named := ref
+	if newName := value(entry.newName); newName != "" {
+		var err error
+		named, err = dockref.FromOriginal(newName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid %s '%s' in line %d", keyNewName, newName, entry.mapping.Line)
+		}
+	}
+
+	result, err := named.WithTag(tag)
+	if err == nil && dig != "" {
+		result, err = result.WithDigest(dig)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid image '%s' in line %d", entry.reference(), entry.mapping.Line)
+	}
+
+	if location, ok := dockfmt.LocationOf(ref); ok {
+		return dockfmt.LocatedReferenceNew(result, location), nil
+	}
+	return result, nil
+}
+
+// edits returns the changes of newTag and digest that turn the entry into canonicalString. The keys are
+// added when they are missing. The name must not change
+func (entry imageEntry) edits(content []byte, ref dockref.Reference, canonicalString string) ([]yamlfmt.Edit, error) {
+	canonical, err := dockref.FromOriginal(canonicalString)
+	if err != nil {
+		return nil, err
+	}
+
+	if canonical.Name() != ref.Name() {
+		return nil, errors.Errorf("Cannot rewrite '%s' in line %d as '%s': only %s and %s are changed",
+			ref.Original(), entry.mapping.Line, canonicalString, keyNewTag, keyDigest)
+	}
+
+	edits := make([]yamlfmt.Edit, 0)
+	for _, field := range []struct {
+		key   string
+		node  *yaml.Node
+		value string
+	}{
+		{keyNewTag, entry.newTag, canonical.Tag()},
+		{keyDigest, entry.digest, canonical.DigestString()},
+	} {
+		if field.value == value(field.node) {
+			continue
+		}
+
+		switch {
+		case field.node != nil && field.node.Value != "":
+			edits = append(edits, yamlfmt.ScalarEdit(field.node, field.value))
+		case field.node != nil:
+			return nil, errors.Errorf("Cannot set %s of '%s' in line %d: the key has no value", field.key, ref.Original(), field.node.Line)
+		case field.value != "":
+			edit, err := yamlfmt.AppendKey(content, entry.mapping, field.key, field.value)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, edit)
+		}
+	}
+
+	return edits, nil
+}
@@ -0,0 +1,259 @@
+package kustomize
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/kubernetes"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure ConfigurableFormat is implemented
+var _ dockfmt.ConfigurableFormat = (*kustomizeFormat)(nil)
+
+type kustomizeFormat struct {
+	filename string
+	content  []byte
+	images   []imageEntry
+	// resourceImages are the images of the resources that are not overridden by images
+	resourceImages []dockref.Reference
+	// followResources enables finding the images of the resources
+	followResources bool
+	// manifests finds the images of the Kubernetes manifests of the resources
+	manifests dockfmt.Format
+}
+
+// kustomizeGroup is the API group of kustomization files
+const kustomizeGroup = "kustomize.config.k8s.io/"
+
+// fields are the top-level keys of kustomization files
+var fields = map[string]bool{
+	"apiVersion":            true,
+	"kind":                  true,
+	"metadata":              true,
+	"bases":                 true,
+	"buildMetadata":         true,
+	"commonAnnotations":     true,
+	"commonLabels":          true,
+	"components":            true,
+	"configMapGenerator":    true,
+	"configurations":        true,
+	"crds":                  true,
+	"generatorOptions":      true,
+	"generators":            true,
+	"helmCharts":            true,
+	"helmGlobals":           true,
+	"images":                true,
+	"labels":                true,
+	"namePrefix":            true,
+	"nameSuffix":            true,
+	"namespace":             true,
+	"openapi":               true,
+	"patches":               true,
+	"patchesJson6902":       true,
+	"patchesStrategicMerge": true,
+	"replacements":          true,
+	"replicas":              true,
+	"resources":             true,
+	"secretGenerator":       true,
+	"sortOptions":           true,
+	"transformers":          true,
+	"validators":            true,
+	"vars":                  true,
+}
+
+// listFields are the fields of which a kustomization without apiVersion has at least one
+var listFields = []string{"resources", "bases", "components", "images"}
+
+func (format *kustomizeFormat) Name() string {
+	return "Kustomize"
+}
+
+func New() dockfmt.Format {
+	return newKustomizeFormat()
+}
+
+func newKustomizeFormat() *kustomizeFormat {
+	return &kustomizeFormat{manifests: kubernetes.New()}
+}
+
+// Configure returns a format that reads the resources of kustomizations when KustomizeResources is set, so the
+// effective images of an overlay are found, including the images of its bases that are not overridden by images.
+// The manifests of the resources are read with the options of the Kubernetes format
+func (format *kustomizeFormat) Configure(options dockfmt.Options) (dockfmt.Format, error) {
+	manifests, err := dockfmt.ConfigureFormat(kubernetes.New(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	configured := newKustomizeFormat()
+	configured.followResources = options.KustomizeResources
+	configured.manifests = manifests
+	return configured, nil
+}
+
+func (format *kustomizeFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	entries, root, err := parseKustomization(content)
+	if err != nil {
+		return err
+	}
+
+	images := make([]imageEntry, 0)
+	for _, entry := range entries {
+		// without newTag and digest the tag is the one of the resources
+		if entry.overridesVersion() {
+			images = append(images, entry)
+		} else {
+			log.Debugf("Skipping image '%s' at line %d: no newTag or digest", entry.name.Value, entry.name.Line)
+		}
+	}
+
+	resourceImages := make([]dockref.Reference, 0)
+	if format.followResources {
+		resources, err := format.kustomizationResourceImages(log, filename, root, map[string]bool{})
+		if err != nil {
+			return err
+		}
+
+		for _, ref := range resources {
+			entry := matchingEntry(entries, ref)
+			if entry != nil && entry.overridesVersion() {
+				// the image of the entry is used instead
+				continue
+			}
+			if entry != nil {
+				ref, err = entry.apply(ref)
+				if err != nil {
+					return err
+				}
+			}
+			resourceImages = append(resourceImages, ref)
+		}
+	}
+
+	format.filename = filename
+	format.content = content
+	format.images = images
+	format.resourceImages = resourceImages
+
+	return nil
+}
+
+// parseKustomization returns the entries of images and the top-level node of a kustomization
+func parseKustomization(content []byte) ([]imageEntry, *yaml.Node, error) {
+	var document yaml.Node
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := yamlfmt.Root(&document)
+	if root == nil || root.Kind != yaml.MappingNode {
+		return nil, nil, errors.Errorf("No YAML document found")
+	}
+
+	if apiVersion := yamlfmt.ScalarValue(root, "apiVersion"); apiVersion != "" {
+		if !strings.HasPrefix(apiVersion, kustomizeGroup) {
+			return nil, nil, errors.Errorf("No kustomization: apiVersion %s is no %s", apiVersion, kustomizeGroup)
+		}
+	} else if !isKustomization(root) {
+		return nil, nil, errors.Errorf("No kustomization with %s found", strings.Join(listFields, ", "))
+	}
+
+	images := yamlfmt.MappingValue(root, "images")
+	if images == nil {
+		return []imageEntry{}, root, nil
+	}
+	if images.Kind != yaml.SequenceNode {
+		return nil, nil, errors.Errorf("images in line %d is no list", images.Line)
+	}
+
+	entries := make([]imageEntry, 0, len(images.Content))
+	for _, node := range images.Content {
+		entry, err := imageEntryOf(yamlfmt.ResolveAlias(node))
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, root, nil
+}
+
+// isKustomization checks a mapping without apiVersion, which must only contain fields of kustomizations
+// and at least one of the lists of resources or images
+func isKustomization(root *yaml.Node) bool {
+	for i := 0; i < len(root.Content); i += 2 {
+		if !fields[root.Content[i].Value] {
+			return false
+		}
+	}
+
+	for _, field := range listFields {
+		node := yamlfmt.MappingValue(root, field)
+		if node != nil && node.Kind == yaml.SequenceNode {
+			return true
+		}
+	}
+	return false
+}
+
+func (format *kustomizeFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	edits := make([]yamlfmt.Edit, 0)
+	for _, entry := range format.images {
+		original := entry.reference()
+		log.Infof("Found image %s", original)
+
+		ref, err := dockref.FromOriginal(original)
+		if err != nil {
+			log.Warnf("Skipping image '%s' at line %d: %s", original, entry.name.Line, err.Error())
+			continue
+		}
+
+		location := yamlfmt.NodeLocation(format.filename, format.content, entry.nameNode())
+		canonicalString, err := imageNameProcessor(dockfmt.LocatedReferenceNew(ref, location))
+		if err != nil {
+			return err
+		}
+
+		if canonicalString == "" || canonicalString == original {
+			continue
+		}
+
+		log.Infof("Pinning '%s' as '%s'", original, canonicalString)
+		entryEdits, err := entry.edits(format.content, ref, canonicalString)
+		if err != nil {
+			return err
+		}
+		edits = append(edits, entryEdits...)
+	}
+
+	for _, ref := range format.resourceImages {
+		canonicalString, err := imageNameProcessor(ref)
+		if err != nil {
+			return err
+		}
+
+		if canonicalString != "" && canonicalString != ref.Original() {
+			location, _ := dockfmt.LocationOf(ref)
+			log.Warnf("Not rewriting '%s' of %s: images of resources are rewritten in their files or by adding them to images",
+				ref.Original(), location)
+		}
+	}
+
+	return yamlfmt.ApplyEdits(log, format.content, edits, w)
+}
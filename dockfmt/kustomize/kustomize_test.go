@@ -0,0 +1,201 @@
+package kustomize
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const testDigest = "sha256:2a03a6059f21e150ae84b0973863609494aad70f0a80eaeb64bddd8d92465812"
+
+const kustomization = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+  - ../base
+images:
+  - name: nginx
+    newTag: 1.19.2   # web server
+  - name: postgres
+    newName: my.registry/postgres
+    newTag: "12.4"
+    digest: ` + testDigest + `
+  - name: busybox
+    newName: my.registry/busybox
+`
+
+func processKustomize(t *testing.T, format dockfmt.Format, file string, filename string, imageNameProcessor func(r dockref.Reference) (string, error)) string {
+	err := format.ValidateInput(log, strings.NewReader(file), filename)
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.Process(log, strings.NewReader(file), buffer, imageNameProcessor)
+	assert.Nil(t, err)
+
+	return buffer.String()
+}
+
+func TestKustomizeFindsEffectiveImagesInOrder(t *testing.T) {
+	var found []string
+	processKustomize(t, New(), kustomization, "kustomization.yaml", func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+
+	assert.Equal(t, []string{"nginx:1.19.2", "my.registry/postgres:12.4@" + testDigest}, found)
+}
+
+func TestKustomizePinWritesDigest(t *testing.T) {
+	result := processKustomize(t, New(), kustomization, "kustomization.yaml", func(r dockref.Reference) (string, error) {
+		if r.DigestString() != "" {
+			return r.Original(), nil
+		}
+		return r.Original() + "@" + testDigest, nil
+	})
+
+	expected := strings.Replace(kustomization, "    newTag: 1.19.2   # web server\n",
+		"    newTag: 1.19.2   # web server\n    digest: "+testDigest+"\n", 1)
+	assert.Equal(t, expected, result)
+}
+
+func TestKustomizeUpdateWritesNewTag(t *testing.T) {
+	result := processKustomize(t, New(), kustomization, "kustomization.yaml", func(r dockref.Reference) (string, error) {
+		if r.Name() == "my.registry/postgres" {
+			return "my.registry/postgres:12.5", nil
+		}
+		return "nginx:1.20", nil
+	})
+
+	expected := strings.NewReplacer(
+		"newTag: 1.19.2", `newTag: "1.20"`,
+		`newTag: "12.4"`, `newTag: "12.5"`,
+		"digest: "+testDigest, `digest: ""`,
+	).Replace(kustomization)
+	assert.Equal(t, expected, result)
+}
+
+func TestKustomizeRefusesToChangeName(t *testing.T) {
+	format := New()
+	err := format.ValidateInput(log, strings.NewReader(kustomization), "kustomization.yaml")
+	assert.Nil(t, err)
+
+	err = format.Process(log, strings.NewReader(kustomization), bytes.NewBuffer(nil), func(r dockref.Reference) (string, error) {
+		return "alpine:3.8", nil
+	})
+	assert.Error(t, err)
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "dockmoor")
+	assert.Nil(t, err)
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0777))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0666))
+	}
+	return dir
+}
+
+func TestKustomizeFollowsResources(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base/kustomization.yaml": "resources:\n  - deployment.yaml\nimages:\n  - name: redis\n    newTag: '6.0'\n",
+		"base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - image: nginx:1.19
+        - image: postgres:12
+        - image: busybox:1.32
+        - image: redis:5
+        - image: alpine:3.12
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	format, err := New().(dockfmt.ConfigurableFormat).Configure(dockfmt.Options{KustomizeResources: true})
+	assert.Nil(t, err)
+
+	filename := filepath.Join(dir, "overlay", "kustomization.yaml")
+	var found []string
+	var files []string
+	processKustomize(t, format, kustomization, filename, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		location, ok := dockfmt.LocationOf(r)
+		assert.True(t, ok)
+		files = append(files, filepath.Base(location.File))
+		return "", nil
+	})
+
+	assert.Equal(t, []string{
+		"nginx:1.19.2", "my.registry/postgres:12.4@" + testDigest,
+		"my.registry/busybox:1.32", "redis:6.0", "alpine:3.12",
+	}, found)
+	assert.Equal(t, []string{
+		"kustomization.yaml", "kustomization.yaml",
+		"deployment.yaml", "kustomization.yaml", "deployment.yaml",
+	}, files)
+}
+
+func TestKustomizeImageEntriesOverrideResources(t *testing.T) {
+	expectations := []struct {
+		entry    string
+		image    string
+		expected string
+	}{
+		{"{name: nginx, newTag: '1.19'}", "nginx:1.18@" + testDigest, "nginx:1.19"},
+		{"{name: nginx, digest: " + testDigest + "}", "library/nginx:1.18", "library/nginx@" + testDigest},
+		{"{name: nginx, newTag: '1.19', digest: " + testDigest + "}", "nginx", "nginx:1.19@" + testDigest},
+		{"{name: nginx, newName: my.registry/nginx}", "nginx:1.18@" + testDigest, "my.registry/nginx:1.18@" + testDigest},
+		{"{name: nginx, newName: my.registry/nginx}", "nginx", "my.registry/nginx"},
+	}
+
+	for _, e := range expectations {
+		e := e
+		t.Run(e.entry+" "+e.image, func(t *testing.T) {
+			var node yaml.Node
+			assert.Nil(t, yaml.Unmarshal([]byte(e.entry), &node))
+			entry, err := imageEntryOf(node.Content[0])
+			assert.Nil(t, err)
+
+			ref, _ := dockref.FromOriginal(e.image)
+			applied, err := entry.apply(ref)
+			assert.Nil(t, err)
+			assert.Equal(t, e.expected, applied.Original())
+		})
+	}
+}
+
+func TestKustomizeReportsLocations(t *testing.T) {
+	file := `images:
+  - name: nginx
+    newTag: 1.19.2
+`
+
+	var locations []dockfmt.Location
+	processKustomize(t, New(), file, "kustomization.yaml", func(r dockref.Reference) (string, error) {
+		location, ok := dockfmt.LocationOf(r)
+		assert.True(t, ok)
+		locations = append(locations, location)
+		return "", nil
+	})
+
+	assert.Equal(t, []dockfmt.Location{
+		{File: "kustomization.yaml", Line: 2, Column: 11, EndLine: 2, EndColumn: 16},
+	}, locations)
+}
@@ -0,0 +1,153 @@
+package kustomize
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resourceFields list the resources of a kustomization, bases is the deprecated name of resources
+var resourceFields = []string{"resources", "bases", "components"}
+
+// kustomizationFileNames are the names kustomize looks for in directories
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// kustomizationResourceImages returns the images of the resources of the kustomization in filename.
+// Directories are read as kustomizations, with their images applied, files as Kubernetes manifests.
+// Remote resources like github.com/org/repo//dir?ref=v1 are skipped
+func (format *kustomizeFormat) kustomizationResourceImages(log logrus.FieldLogger, filename string, root *yaml.Node, visited map[string]bool) ([]dockref.Reference, error) {
+	dir := filepath.Dir(filename)
+	images := make([]dockref.Reference, 0)
+	for _, field := range resourceFields {
+		resources := yamlfmt.MappingValue(root, field)
+		if resources == nil || resources.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, resource := range resources.Content {
+			resource = yamlfmt.ResolveAlias(resource)
+			if resource.Kind != yaml.ScalarNode {
+				continue
+			}
+
+			if isRemote(resource.Value) {
+				log.Warnf("Skipping remote resource '%s' of %s", resource.Value, filename)
+				continue
+			}
+
+			path := filepath.Join(dir, resource.Value)
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Warnf("Skipping resource '%s' of %s: %s", resource.Value, filename, err.Error())
+				continue
+			}
+
+			var resourceImages []dockref.Reference
+			if info.IsDir() {
+				resourceImages, err = format.directoryImages(log, path, visited)
+			} else {
+				resourceImages, err = format.manifestImages(log, path)
+			}
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, resourceImages...)
+		}
+	}
+	return images, nil
+}
+
+func isRemote(resource string) bool {
+	return strings.Contains(resource, "://") || strings.HasPrefix(resource, "github.com/") ||
+		strings.HasPrefix(resource, "git@") || strings.Contains(resource, "?ref=")
+}
+
+// directoryImages returns the images of the kustomization in dir
+func (format *kustomizeFormat) directoryImages(log logrus.FieldLogger, dir string, visited map[string]bool) ([]dockref.Reference, error) {
+	filename := ""
+	for _, name := range kustomizationFileNames {
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			filename = candidate
+			break
+		}
+	}
+	if filename == "" {
+		log.Warnf("Skipping %s: no kustomization found", dir)
+		return []dockref.Reference{}, nil
+	}
+
+	absolute, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	if visited[absolute] {
+		return nil, errors.Errorf("Cycle of resources in %s", filename)
+	}
+	visited[absolute] = true
+	defer delete(visited, absolute)
+
+	content, err := ioutil.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	entries, root, err := parseKustomization(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid kustomization %s", filename)
+	}
+
+	resourceImages, err := format.kustomizationResourceImages(log, filename, root, visited)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]dockref.Reference, 0, len(resourceImages))
+	for _, ref := range resourceImages {
+		entry := matchingEntry(entries, ref)
+		if entry == nil {
+			images = append(images, ref)
+			continue
+		}
+
+		ref, err = entry.apply(ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid kustomization %s", filename)
+		}
+		if entry.overridesVersion() {
+			// the tag or digest is defined by the entry
+			ref = dockfmt.LocatedReferenceNew(ref, yamlfmt.NodeLocation(filename, content, entry.nameNode()))
+		}
+		images = append(images, ref)
+	}
+	return images, nil
+}
+
+// manifestImages returns the images of the Kubernetes resources in the file
+func (format *kustomizeFormat) manifestImages(log logrus.FieldLogger, filename string) ([]dockref.Reference, error) {
+	content, err := ioutil.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]dockref.Reference, 0)
+	err = format.manifests.ValidateInput(log, bytes.NewReader(content), filename)
+	if err != nil {
+		log.Warnf("Skipping resource %s: %s", filename, err.Error())
+		return images, nil
+	}
+
+	err = format.manifests.Process(log, bytes.NewReader(content), ioutil.Discard, func(r dockref.Reference) (string, error) {
+		images = append(images, r)
+		return "", nil
+	})
+	return images, err
+}
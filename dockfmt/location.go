@@ -0,0 +1,67 @@
+package dockfmt
+
+import (
+	"fmt"
+	"github.com/MeneDev/dockmoor/dockref"
+)
+
+// Location is the position of an image reference in a file.
+// Lines and columns start at 1, columns count characters. The end is exclusive, i.e. EndColumn is the
+// column following the last character of the reference
+type Location struct {
+	File      string
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+}
+
+func (location Location) String() string {
+	return fmt.Sprintf("%s:%d:%d", location.File, location.Line, location.Column)
+}
+
+// LocatedReference is a reference together with the location it was found at.
+// Formats pass LocatedReferences to the ImageNameProcessor when they know the location
+type LocatedReference interface {
+	dockref.Reference
+	Location() Location
+}
+
+var _ LocatedReference = (*locatedReference)(nil)
+
+type locatedReference struct {
+	dockref.Reference
+	location Location
+}
+
+func (r locatedReference) Location() Location {
+	return r.location
+}
+
+func LocatedReferenceNew(ref dockref.Reference, location Location) LocatedReference {
+	return locatedReference{
+		Reference: ref,
+		location:  location,
+	}
+}
+
+// LocationOf returns the location of the reference, if it is known
+func LocationOf(ref dockref.Reference) (Location, bool) {
+	located, ok := ref.(LocatedReference)
+	if !ok {
+		return Location{}, false
+	}
+	return located.Location(), true
+}
+
+// LocationInLine returns the location of text that starts at byte offset start of the line
+func LocationInLine(file string, lineNumber int, line string, start int, text string) Location {
+	column := len([]rune(line[:start])) + 1
+	return Location{
+		File:      file,
+		Line:      lineNumber,
+		Column:    column,
+		EndLine:   lineNumber,
+		EndColumn: column + len([]rune(text)),
+	}
+}
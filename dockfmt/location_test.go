@@ -0,0 +1,37 @@
+package dockfmt
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLocationOfLocatedReference(t *testing.T) {
+	ref, _ := dockref.FromOriginal("nginx:1.15")
+	location := Location{File: "Dockerfile", Line: 2, Column: 6, EndLine: 2, EndColumn: 16}
+
+	located := LocatedReferenceNew(ref, location)
+	assert.Equal(t, "nginx:1.15", located.Original())
+
+	actual, ok := LocationOf(located)
+	assert.True(t, ok)
+	assert.Equal(t, location, actual)
+	assert.Equal(t, "Dockerfile:2:6", actual.String())
+}
+
+func TestLocationOfPlainReferenceIsUnknown(t *testing.T) {
+	ref, _ := dockref.FromOriginal("nginx:1.15")
+	_, ok := LocationOf(ref)
+	assert.False(t, ok)
+}
+
+func TestLocationInLineCountsCharacters(t *testing.T) {
+	line := "  image: nginx # äöü\n"
+	location := LocationInLine("compose.yml", 3, line, 9, "nginx")
+	assert.Equal(t, Location{File: "compose.yml", Line: 3, Column: 10, EndLine: 3, EndColumn: 15}, location)
+
+	line = "  ä: nginx\n"
+	location = LocationInLine("compose.yml", 1, line, 6, "nginx")
+	assert.Equal(t, 6, location.Column)
+	assert.Equal(t, 11, location.EndColumn)
+}
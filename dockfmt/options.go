@@ -0,0 +1,67 @@
+package dockfmt
+
+import (
+	"github.com/hashicorp/go-multierror"
+)
+
+// Options control where formats find image references. They are given for each run, so the registered
+// formats keep their defaults
+type Options struct {
+	// ActionReferences matches actions used in GitHub workflows like actions/checkout@v2
+	ActionReferences bool
+	// BuildArgs override the defaults of ARG instructions before the first FROM of Dockerfiles
+	BuildArgs map[string]string
+	// ComposeBuildArgs finds images in build args of docker-compose services named like *IMAGE, e.g. BASE_IMAGE
+	ComposeBuildArgs bool
+	// HelmKeys are additional key names of the fields of images in Helm chart values, given like tag=version
+	HelmKeys []string
+	// KubernetesImagePaths are JSONPath expressions that select images in Kubernetes resources without pod spec
+	KubernetesImagePaths []string
+	// KustomizeResources follows the resources of kustomizations to find the effective images of overlays
+	KustomizeResources bool
+	// TravisDockerPull finds the images of docker pull commands in the scripts of Travis CI configurations
+	TravisDockerPull bool
+}
+
+// ConfigurableFormat is a Format that has options
+type ConfigurableFormat interface {
+	Format
+	// Configure returns a new instance of the format that uses the options
+	Configure(options Options) (Format, error)
+}
+
+// ConfigureFormat returns an instance of format that uses the options, formats without options are returned as they are
+func ConfigureFormat(format Format, options Options) (Format, error) {
+	configurable, ok := format.(ConfigurableFormat)
+	if !ok {
+		return format, nil
+	}
+	return configurable.Configure(options)
+}
+
+// ConfiguredFormatProvider returns a provider of the formats of provider configured with options
+func ConfiguredFormatProvider(provider FormatProvider, options Options) (FormatProvider, error) {
+	var result *multierror.Error
+	formats := make(formatList, 0)
+	for _, format := range provider.Formats() {
+		configured, err := ConfigureFormat(format, options)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+		formats = append(formats, configured)
+	}
+
+	if result != nil {
+		return nil, result.ErrorOrNil()
+	}
+	return formats, nil
+}
+
+var _ FormatProvider = (formatList)(nil)
+
+type formatList []Format
+
+func (formats formatList) Formats() []Format {
+	return formats
+}
@@ -0,0 +1,51 @@
+package dockfmt
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var _ ConfigurableFormat = (*configurableFormatMock)(nil)
+
+type configurableFormatMock struct {
+	FormatMock
+	options Options
+	err     error
+}
+
+func (m *configurableFormatMock) Configure(options Options) (Format, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &configurableFormatMock{options: options}, nil
+}
+
+func TestConfiguredFormatProviderConfiguresFormats(t *testing.T) {
+	plain := new(FormatMock)
+	configurable := new(configurableFormatMock)
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.OnFormats().Return([]Format{plain, configurable})
+
+	options := Options{ActionReferences: true}
+	provider, err := ConfiguredFormatProvider(formatProviderMock, options)
+	assert.Nil(t, err)
+
+	formats := provider.Formats()
+	assert.Len(t, formats, 2)
+	assert.Equal(t, plain, formats[0])
+	assert.Equal(t, options, formats[1].(*configurableFormatMock).options)
+	assert.Equal(t, Options{}, configurable.options)
+}
+
+func TestConfiguredFormatProviderReturnsErrorsOfFormats(t *testing.T) {
+	formatProviderMock := new(FormatProviderMock)
+	formatProviderMock.OnFormats().Return([]Format{
+		&configurableFormatMock{err: errors.New("invalid")},
+		new(FormatMock),
+	})
+
+	provider, err := ConfiguredFormatProvider(formatProviderMock, Options{})
+	assert.Nil(t, provider)
+	assert.Error(t, err)
+}
@@ -0,0 +1,230 @@
+package travisci
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure ConfigurableFormat is implemented
+var _ dockfmt.ConfigurableFormat = (*travisCIFormat)(nil)
+
+type travisCIFormat struct {
+	filename string
+	content  []byte
+	images   []yamlfmt.ImageValue
+	// dockerPull enables finding images of docker pull commands
+	dockerPull bool
+}
+
+// phases are the keys of scripts that can pull images
+var phases = []string{"before_install", "install", "before_script", "script", "after_success", "after_failure", "after_script", "before_deploy", "after_deploy"}
+
+var dockerPullRegexp = regexp.MustCompile(`docker\s+pull\s+(?:-\S+\s+)*["']?([^\s;&|"']+)`)
+var variableRegexp = regexp.MustCompile(`^\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))$`)
+var assignmentRegexp = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)=("[^"]*"|'[^']*'|\S*)`)
+
+func (format *travisCIFormat) Name() string {
+	return "Travis CI"
+}
+
+func New() dockfmt.Format {
+	return newTravisCIFormat()
+}
+
+func newTravisCIFormat() *travisCIFormat {
+	return new(travisCIFormat)
+}
+
+// Configure returns a format that finds the images of docker pull commands in the scripts when TravisDockerPull
+// is set. Images given by a variable, like docker pull $IMAGE, are found where the variable is assigned in env
+func (format *travisCIFormat) Configure(options dockfmt.Options) (dockfmt.Format, error) {
+	configured := newTravisCIFormat()
+	configured.dockerPull = options.TravisDockerPull
+	return configured, nil
+}
+
+func (format *travisCIFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return err
+	}
+
+	root := yamlfmt.Root(&document)
+	if root == nil {
+		return errors.Errorf("No YAML document found")
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return errors.Errorf("No Travis CI configuration found")
+	}
+
+	if filepath.Base(filename) != ".travis.yml" && !hasTravisStructure(root) {
+		return errors.Errorf("No Travis CI configuration with language and scripts or jobs found")
+	}
+
+	// services like docker or postgresql are installed on the build machine, they are names of services, not
+	// images, so they are not read
+	images := make([]yamlfmt.ImageValue, 0)
+	if format.dockerPull {
+		images = pulledImages(root)
+	}
+
+	format.filename = filename
+	format.content = content
+	format.images = images
+
+	return nil
+}
+
+// hasTravisStructure reports whether the configuration has a language and phases or jobs, so it is recognized
+// regardless of its file name
+func hasTravisStructure(root *yaml.Node) bool {
+	language := yamlfmt.MappingValue(root, "language")
+	if language == nil || language.Kind != yaml.ScalarNode {
+		return false
+	}
+
+	// GitHub workflows are triggered by on, Travis CI only knows on below deploy
+	if yamlfmt.MappingValue(root, "on") != nil {
+		return false
+	}
+
+	for _, key := range append([]string{"jobs", "matrix"}, phases...) {
+		if yamlfmt.MappingValue(root, key) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// pulledImages returns the images of docker pull commands in the scripts of the configuration and of its jobs
+func pulledImages(root *yaml.Node) []yamlfmt.ImageValue {
+	configurations := []*yaml.Node{root}
+	for _, key := range []string{"jobs", "matrix"} {
+		include := yamlfmt.MappingValue(yamlfmt.MappingValue(root, key), "include")
+		if include != nil && include.Kind == yaml.SequenceNode {
+			for _, job := range include.Content {
+				configurations = append(configurations, yamlfmt.ResolveAlias(job))
+			}
+		}
+	}
+
+	variables := make(map[string]bool)
+	images := make([]yamlfmt.ImageValue, 0)
+	for _, configuration := range configurations {
+		for _, phase := range phases {
+			for _, line := range scalars(yamlfmt.MappingValue(configuration, phase)) {
+				for _, match := range dockerPullRegexp.FindAllStringSubmatchIndex(line.Value, -1) {
+					image := line.Value[match[2]:match[3]]
+					if variable := variableRegexp.FindStringSubmatch(image); variable != nil {
+						variables[variable[1]+variable[2]] = true
+						continue
+					}
+					images = append(images, yamlfmt.ImageValue{Node: line, Prefix: line.Value[:match[2]], Suffix: line.Value[match[3]:]})
+				}
+			}
+		}
+	}
+
+	for _, configuration := range configurations {
+		for _, assignments := range envScalars(yamlfmt.MappingValue(configuration, "env")) {
+			images = append(images, assignedImages(assignments, variables)...)
+		}
+	}
+
+	sortByPosition(images)
+	return images
+}
+
+// sortByPosition sorts the images in the order they appear in the document
+func sortByPosition(images []yamlfmt.ImageValue) {
+	sort.SliceStable(images, func(i, j int) bool {
+		a, b := images[i].Node, images[j].Node
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return len(images[i].Prefix) < len(images[j].Prefix)
+	})
+}
+
+// envScalars returns the scalars of env, which is a list or string of assignments, or a mapping with global
+// and jobs or matrix
+func envScalars(env *yaml.Node) []*yaml.Node {
+	if env != nil && env.Kind == yaml.MappingNode {
+		result := make([]*yaml.Node, 0)
+		for _, key := range []string{"global", "jobs", "matrix"} {
+			result = append(result, scalars(yamlfmt.MappingValue(env, key))...)
+		}
+		return result
+	}
+	return scalars(env)
+}
+
+// assignedImages returns the values of assignments like IMAGE=postgres:10 to the variables. A scalar can
+// contain several assignments separated by whitespace
+func assignedImages(assignments *yaml.Node, variables map[string]bool) []yamlfmt.ImageValue {
+	images := make([]yamlfmt.ImageValue, 0)
+	value := assignments.Value
+	for _, match := range assignmentRegexp.FindAllStringSubmatchIndex(value, -1) {
+		if !variables[value[match[2]:match[3]]] {
+			continue
+		}
+
+		start, end := match[4], match[5]
+		if end-start >= 2 && strings.ContainsAny(value[start:start+1], `"'`) {
+			start, end = start+1, end-1
+		}
+		if start == end {
+			continue
+		}
+		images = append(images, yamlfmt.ImageValue{Node: assignments, Prefix: value[:start], Suffix: value[end:]})
+	}
+	return images
+}
+
+// scalars returns the node if it is a scalar or the scalars of a sequence
+func scalars(node *yaml.Node) []*yaml.Node {
+	result := make([]*yaml.Node, 0)
+	if node == nil {
+		return result
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		result = append(result, node)
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			item = yamlfmt.ResolveAlias(item)
+			if item.Kind == yaml.ScalarNode {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+func (format *travisCIFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return yamlfmt.ProcessImages(log, format.filename, format.content, format.images, w, imageNameProcessor)
+}
@@ -0,0 +1,112 @@
+package travisci
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const config = `language: go
+services:
+  - docker
+env:
+  global:
+    - DB_IMAGE=postgres:10 CACHE_IMAGE="redis:5"
+    - UNUSED=alpine:3.8
+before_install:
+  - docker pull $DB_IMAGE
+  - docker pull ${CACHE_IMAGE} && docker pull -q golang:1.11
+script:
+  - go test ./...
+jobs:
+  include:
+    - env: LINT_IMAGE=golangci/golangci-lint:v1.21
+      script: docker pull "$LINT_IMAGE"
+`
+
+func processTravisCI(t *testing.T, format dockfmt.Format, file string, imageNameProcessor func(r dockref.Reference) (string, error)) string {
+	err := format.ValidateInput(log, strings.NewReader(file), ".travis.yml")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.Process(log, strings.NewReader(file), buffer, imageNameProcessor)
+	assert.Nil(t, err)
+
+	return buffer.String()
+}
+
+func withDockerPull(t *testing.T) dockfmt.Format {
+	format, err := newTravisCIFormat().Configure(dockfmt.Options{TravisDockerPull: true})
+	assert.Nil(t, err)
+	return format
+}
+
+func collectImages(t *testing.T, format dockfmt.Format, file string) []string {
+	found := make([]string, 0)
+	processTravisCI(t, format, file, func(r dockref.Reference) (string, error) {
+		found = append(found, r.Original())
+		return "", nil
+	})
+	return found
+}
+
+func TestTravisCIFindsNoImagesByDefault(t *testing.T) {
+	assert.Empty(t, collectImages(t, New(), config))
+}
+
+func TestTravisCIFindsPulledImages(t *testing.T) {
+	assert.Equal(t, []string{"postgres:10", "redis:5", "golang:1.11", "golangci/golangci-lint:v1.21"}, collectImages(t, withDockerPull(t), config))
+}
+
+func TestTravisCIRecognizesTravisYmlByName(t *testing.T) {
+	file := "script: make\n"
+
+	err := New().ValidateInput(log, strings.NewReader(file), "project/.travis.yml")
+	assert.Nil(t, err)
+
+	err = New().ValidateInput(log, strings.NewReader(file), "project/ci.yml")
+	assert.Error(t, err)
+}
+
+func TestTravisCIProcessRewritesOnlyImages(t *testing.T) {
+	result := processTravisCI(t, withDockerPull(t), config, func(r dockref.Reference) (string, error) {
+		return r.Original() + "@sha256:1", nil
+	})
+
+	expected := strings.NewReplacer(
+		`DB_IMAGE=postgres:10 CACHE_IMAGE="redis:5"`, `DB_IMAGE=postgres:10@sha256:1 CACHE_IMAGE="redis:5@sha256:1"`,
+		"docker pull -q golang:1.11", "docker pull -q golang:1.11@sha256:1",
+		"golangci-lint:v1.21", "golangci-lint:v1.21@sha256:1",
+	).Replace(config)
+	assert.Equal(t, expected, result)
+}
+
+func TestTravisCIReportsLocations(t *testing.T) {
+	file := `language: go
+env: A=1 IMAGE=postgres:10
+script: docker pull $IMAGE && docker pull golang:1.11
+`
+
+	var locations []dockfmt.Location
+	processTravisCI(t, withDockerPull(t), file, func(r dockref.Reference) (string, error) {
+		location, ok := dockfmt.LocationOf(r)
+		assert.True(t, ok)
+		locations = append(locations, location)
+		return "", nil
+	})
+
+	assert.Equal(t, []dockfmt.Location{
+		{File: ".travis.yml", Line: 2, Column: 16, EndLine: 2, EndColumn: 27},
+		{File: ".travis.yml", Line: 3, Column: 43, EndLine: 3, EndColumn: 54},
+	}, locations)
+}
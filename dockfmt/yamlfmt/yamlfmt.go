@@ -0,0 +1,396 @@
+// Package yamlfmt contains the parts shared by formats based on YAML.
+// Image references are rewritten in the original content, so comments, quoting, anchors and
+// document separators are kept as they are.
+package yamlfmt
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImageValue is a scalar that contains an image reference
+type ImageValue struct {
+	Node *yaml.Node
+	// Prefix precedes the image reference in the scalar, e.g. BASE_IMAGE= in build args given as list
+	Prefix string
+	// Suffix follows the image reference in the scalar, e.g. further commands in a script line
+	Suffix string
+	// Parse creates the reference from the value, dockref.FromOriginal if nil
+	Parse func(original string) (dockref.Reference, error)
+}
+
+// Reference returns the image reference part of the scalar
+func (value ImageValue) Reference() string {
+	return strings.TrimSuffix(strings.TrimPrefix(value.Node.Value, value.Prefix), value.Suffix)
+}
+
+// Edit replaces the text Old, which starts after Prefix in the scalar Node, with New.
+// Without Node, New is inserted at the start of Line
+type Edit struct {
+	Node   *yaml.Node
+	Prefix string
+	Old    string
+	New    string
+	Line   int
+}
+
+// DecodeAll parses all documents of a YAML stream
+func DecodeAll(content []byte) ([]*yaml.Node, error) {
+	documents := make([]*yaml.Node, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		document := new(yaml.Node)
+		err := decoder.Decode(document)
+		if err == io.EOF {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+// Root returns the top-level node of the document or nil for empty documents
+func Root(document *yaml.Node) *yaml.Node {
+	if document == nil || document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil
+	}
+
+	root := ResolveAlias(document.Content[0])
+	if root.Kind == yaml.ScalarNode && root.Tag == "!!null" && root.Value == "" {
+		// e.g. after a trailing ---
+		return nil
+	}
+	return root
+}
+
+// ResolveAlias returns the node an alias like *defaults refers to, or the node itself
+func ResolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// MappingValue returns the value of key in the mapping, including values inherited via merge keys.
+// The result is nil if the node is no mapping or does not contain the key
+func MappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	mapping = ResolveAlias(mapping)
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return ResolveAlias(mapping.Content[i+1])
+		}
+	}
+
+	// values can be inherited via merge keys, e.g. <<: *defaults
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != "<<" {
+			continue
+		}
+
+		merged := ResolveAlias(mapping.Content[i+1])
+		sources := []*yaml.Node{merged}
+		if merged.Kind == yaml.SequenceNode {
+			sources = merged.Content
+		}
+
+		for _, source := range sources {
+			if value := MappingValue(source, key); value != nil {
+				return value
+			}
+		}
+	}
+
+	return nil
+}
+
+// ScalarValue returns the value of key if it is a scalar, empty string otherwise
+func ScalarValue(mapping *yaml.Node, key string) string {
+	value := MappingValue(mapping, key)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
+
+// Unique removes values of the same node. Aliased scalars are rewritten where they are anchored,
+// which must only happen once
+func Unique(values []ImageValue) []ImageValue {
+	unique := make([]ImageValue, 0, len(values))
+	seen := make(map[*yaml.Node]bool)
+	for _, value := range values {
+		if !seen[value.Node] {
+			seen[value.Node] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+func saveFlush(log logrus.FieldLogger, writer *bufio.Writer) {
+	err := writer.Flush()
+	if err != nil {
+		log.Errorf("Error flushing writer: %s", err.Error())
+	}
+}
+
+// ProcessImages passes the image references to the imageNameProcessor and writes content with
+// the references replaced by the results. Values that are no valid references, e.g. because they
+// contain variables, are skipped. The references carry their location in filename
+func ProcessImages(log logrus.FieldLogger, filename string, content []byte, images []ImageValue, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	lineOffsets := lineOffsets(content)
+	edits := make([]Edit, 0)
+	for _, image := range images {
+		original := image.Reference()
+		log.Infof("Found image %s", original)
+
+		parse := image.Parse
+		if parse == nil {
+			parse = dockref.FromOriginal
+		}
+
+		ref, err := parse(original)
+		if err != nil {
+			log.Warnf("Skipping image '%s' at line %d: %s", original, image.Node.Line, err.Error())
+			continue
+		}
+
+		location := imageLocation(filename, content, lineOffsets, image)
+		canonicalString, err := imageNameProcessor(dockfmt.LocatedReferenceNew(ref, location))
+		if err != nil {
+			return err
+		}
+
+		if canonicalString == "" || canonicalString == original {
+			continue
+		}
+
+		log.Infof("Pinning '%s' as '%s'", original, canonicalString)
+		edits = append(edits, Edit{
+			Node:   image.Node,
+			Prefix: image.Prefix,
+			Old:    original,
+			New:    canonicalString,
+		})
+	}
+
+	return ApplyEdits(log, content, edits, w)
+}
+
+// NodeLocation returns the location of the value of the scalar node
+func NodeLocation(filename string, content []byte, node *yaml.Node) dockfmt.Location {
+	return imageLocation(filename, content, lineOffsets(content), ImageValue{Node: node})
+}
+
+// imageLocation returns the location of the image reference, falling back to the position of the node
+// for values that span multiple lines
+func imageLocation(filename string, content []byte, lineOffsets []int, image ImageValue) dockfmt.Location {
+	offset, err := valueOffset(content, lineOffsets, image.Node)
+	if err != nil {
+		return dockfmt.Location{
+			File:      filename,
+			Line:      image.Node.Line,
+			Column:    image.Node.Column,
+			EndLine:   image.Node.Line,
+			EndColumn: image.Node.Column,
+		}
+	}
+
+	lineStart := lineOffsets[image.Node.Line-1]
+	line := string(content[lineStart:])
+	return dockfmt.LocationInLine(filename, image.Node.Line, line, offset-lineStart+len(image.Prefix), image.Reference())
+}
+
+type replacement struct {
+	offset int
+	length int
+	value  string
+}
+
+// ApplyEdits writes content with the edits applied
+func ApplyEdits(log logrus.FieldLogger, content []byte, edits []Edit, w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	defer saveFlush(log, writer)
+
+	lineOffsets := lineOffsets(content)
+
+	// lines inserted after the last line need to start on a new line
+	terminated := len(content) == 0 || bytes.HasSuffix(content, []byte("\n"))
+
+	var result *multierror.Error
+	replacements := make([]replacement, 0, len(edits))
+	for _, edit := range edits {
+		if edit.Node == nil {
+			offset, err := lineOffset(content, lineOffsets, edit.Line)
+			if err != nil {
+				result = multierror.Append(result, err)
+				continue
+			}
+
+			value := edit.New
+			if offset == len(content) && !terminated {
+				value = "\n" + value
+				terminated = true
+			}
+			replacements = append(replacements, replacement{offset: offset, value: value})
+			continue
+		}
+
+		offset, err := valueOffset(content, lineOffsets, edit.Node)
+		if err != nil {
+			result = multierror.Append(result, err)
+			continue
+		}
+
+		replacements = append(replacements, replacement{
+			offset: offset + len(edit.Prefix),
+			length: len(edit.Old),
+			value:  edit.New,
+		})
+	}
+
+	sort.SliceStable(replacements, func(i, j int) bool {
+		return replacements[i].offset < replacements[j].offset
+	})
+
+	position := 0
+	for _, r := range replacements {
+		_, err := writer.Write(content[position:r.offset])
+		result = multierror.Append(result, err)
+		_, err = writer.WriteString(r.value)
+		result = multierror.Append(result, err)
+		position = r.offset + r.length
+	}
+	_, err := writer.Write(content[position:])
+	result = multierror.Append(result, err)
+
+	return result.ErrorOrNil()
+}
+
+// lineOffset returns the offset of the start of the line, lines after the content start at its end
+func lineOffset(content []byte, lineOffsets []int, line int) (int, error) {
+	if line < 1 {
+		return 0, errors.Errorf("Invalid line %d", line)
+	}
+	if line > len(lineOffsets) {
+		return len(content), nil
+	}
+	return lineOffsets[line-1], nil
+}
+
+// ScalarEdit replaces the value of the scalar. Plain scalars are quoted when the new value would not be
+// read as string, e.g. 1.10
+func ScalarEdit(node *yaml.Node, value string) Edit {
+	text := value
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 {
+		text = plainText(value)
+	}
+	return Edit{Node: node, Old: node.Value, New: text}
+}
+
+// AppendKey returns the edit that adds key with the value after the last entry of the block mapping
+func AppendKey(content []byte, mapping *yaml.Node, key string, value string) (Edit, error) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode || len(mapping.Content) == 0 {
+		return Edit{}, errors.Errorf("Cannot add %s: no mapping", key)
+	}
+	if mapping.Style&yaml.FlowStyle != 0 {
+		return Edit{}, errors.Errorf("Cannot add %s to the mapping in line %d, only block mappings are supported", key, mapping.Line)
+	}
+
+	last, err := endLine(mapping)
+	if err != nil {
+		return Edit{}, errors.Wrapf(err, "Cannot add %s to the mapping in line %d", key, mapping.Line)
+	}
+
+	text := strings.Repeat(" ", mapping.Content[0].Column-1) + key + ": " + plainText(value) + "\n"
+	return Edit{Line: last + 1, New: text}, nil
+}
+
+// endLine returns the last line of the node
+func endLine(node *yaml.Node) (int, error) {
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			return node.Line, nil
+		}
+		return endLine(node.Content[len(node.Content)-1])
+	case yaml.ScalarNode:
+		if strings.Contains(node.Value, "\n") || node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			return 0, errors.Errorf("'%s' in line %d spans multiple lines", node.Value, node.Line)
+		}
+	}
+	return node.Line, nil
+}
+
+// plainText returns the value as plain scalar, or quoted when it would not be read as string
+func plainText(value string) string {
+	var decoded interface{}
+	err := yaml.Unmarshal([]byte(value), &decoded)
+	if s, ok := decoded.(string); err == nil && ok && s == value {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// valueOffset finds the byte offset of the scalar value in the content.
+// The node position points to the start of the scalar, which may be preceded by an anchor, a tag or a quote
+func valueOffset(content []byte, lineOffsets []int, node *yaml.Node) (int, error) {
+	value := node.Value
+	if node.Line < 1 || node.Line > len(lineOffsets) {
+		return 0, errors.Errorf("Invalid position of '%s'", value)
+	}
+
+	lineStart := lineOffsets[node.Line-1]
+	lineEnd := len(content)
+	if node.Line < len(lineOffsets) {
+		lineEnd = lineOffsets[node.Line]
+	}
+	line := content[lineStart:lineEnd]
+
+	// columns count characters, not bytes
+	start := len(line)
+	column := 1
+	for i := range string(line) {
+		if column == node.Column {
+			start = i
+			break
+		}
+		column++
+	}
+
+	index := bytes.Index(line[start:], []byte(value))
+	if value == "" && node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		// the empty value is between the quotes
+		index = bytes.IndexAny(line[start:], `"'`) + 1
+	}
+	if index < 0 {
+		return 0, errors.Errorf("Cannot rewrite '%s' in line %d, only single line values are supported", value, node.Line)
+	}
+
+	return lineStart + start + index, nil
+}
+
+func lineOffsets(content []byte) []int {
+	offsets := []int{0}
+	for i, c := range content {
+		if c == '\n' && i+1 < len(content) {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,131 @@
+package yamlfmt
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+func TestDecodeAllReturnsEveryDocument(t *testing.T) {
+	documents, err := DecodeAll([]byte("a: 1\n---\nb: 2\n---\n"))
+	assert.Nil(t, err)
+	assert.Len(t, documents, 3)
+
+	assert.Equal(t, "1", ScalarValue(Root(documents[0]), "a"))
+	assert.Equal(t, "2", ScalarValue(Root(documents[1]), "b"))
+	assert.Nil(t, Root(documents[2]))
+}
+
+func TestDecodeAllReportsErrors(t *testing.T) {
+	_, err := DecodeAll([]byte("a: [\n"))
+	assert.Error(t, err)
+}
+
+func TestMappingValueFollowsAliasesAndMergeKeys(t *testing.T) {
+	documents, _ := DecodeAll([]byte(`base: &base
+  image: nginx
+extra: &extra
+  tag: "1.15"
+service:
+  <<: [*base, *extra]
+  name: web
+alias: *base
+`))
+	root := Root(documents[0])
+
+	service := MappingValue(root, "service")
+	assert.Equal(t, "web", ScalarValue(service, "name"))
+	assert.Equal(t, "nginx", ScalarValue(service, "image"))
+	assert.Equal(t, "1.15", ScalarValue(service, "tag"))
+	assert.Equal(t, "nginx", ScalarValue(MappingValue(root, "alias"), "image"))
+	assert.Nil(t, MappingValue(service, "missing"))
+	assert.Nil(t, MappingValue(MappingValue(service, "name"), "image"))
+}
+
+func TestApplyEditsKeepsSurroundingContent(t *testing.T) {
+	content := []byte("# ünïcode\nfirst: &a 'nginx' # comment\nsecond: nginx\nthird: [\"ä\", nginx, nginx]\n")
+	documents, _ := DecodeAll(content)
+	root := Root(documents[0])
+
+	third := MappingValue(root, "third")
+	edits := []Edit{
+		{Node: third.Content[2], Old: "nginx", New: "alpine"},
+		{Node: MappingValue(root, "first"), Old: "nginx", New: "nginx:1.15"},
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	err := ApplyEdits(log, content, edits, buffer)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "# ünïcode\nfirst: &a 'nginx:1.15' # comment\nsecond: nginx\nthird: [\"ä\", nginx, alpine]\n", buffer.String())
+}
+
+func TestApplyEditsRejectsMultiLineValues(t *testing.T) {
+	content := []byte("image: >-\n  nginx\n")
+	documents, _ := DecodeAll(content)
+
+	edits := []Edit{{Node: MappingValue(Root(documents[0]), "image"), Old: "nginx", New: "alpine"}}
+
+	buffer := bytes.NewBuffer(nil)
+	err := ApplyEdits(log, content, edits, buffer)
+
+	assert.Error(t, err)
+	assert.Equal(t, string(content), buffer.String())
+}
+
+func TestScalarEditQuotesValuesThatAreNoStrings(t *testing.T) {
+	documents, _ := DecodeAll([]byte("a: 1.9\nb: '1.9'\n"))
+	root := Root(documents[0])
+
+	assert.Equal(t, Edit{Node: MappingValue(root, "a"), Old: "1.9", New: `"1.10"`}, ScalarEdit(MappingValue(root, "a"), "1.10"))
+	assert.Equal(t, Edit{Node: MappingValue(root, "a"), Old: "1.9", New: "v1.10"}, ScalarEdit(MappingValue(root, "a"), "v1.10"))
+	assert.Equal(t, Edit{Node: MappingValue(root, "b"), Old: "1.9", New: "1.10"}, ScalarEdit(MappingValue(root, "b"), "1.10"))
+}
+
+func TestAppendKeyAddsLinesAfterTheMapping(t *testing.T) {
+	content := []byte("images:\n  - name: nginx\n    newTag: 1.19.2 # comment\n  - name: alpine\n    newTag: \"3.8\"")
+	documents, _ := DecodeAll(content)
+	images := MappingValue(Root(documents[0]), "images")
+
+	edits := make([]Edit, 0)
+	for _, image := range images.Content {
+		edit, err := AppendKey(content, image, "digest", "sha256:1")
+		assert.Nil(t, err)
+		edits = append(edits, edit)
+	}
+	edit, err := AppendKey(content, images.Content[1], "newName", "true")
+	assert.Nil(t, err)
+	edits = append(edits, edit)
+
+	buffer := bytes.NewBuffer(nil)
+	err = ApplyEdits(log, content, edits, buffer)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "images:\n  - name: nginx\n    newTag: 1.19.2 # comment\n    digest: sha256:1\n"+
+		"  - name: alpine\n    newTag: \"3.8\"\n    digest: sha256:1\n    newName: \"true\"\n", buffer.String())
+}
+
+func TestAppendKeyRejectsFlowMappings(t *testing.T) {
+	content := []byte("image: {name: nginx}\n")
+	documents, _ := DecodeAll(content)
+
+	_, err := AppendKey(content, MappingValue(Root(documents[0]), "image"), "digest", "sha256:1")
+	assert.Error(t, err)
+}
+
+func TestUniqueRemovesDuplicateNodes(t *testing.T) {
+	a := &yaml.Node{Value: "a"}
+	b := &yaml.Node{Value: "b"}
+
+	unique := Unique([]ImageValue{{Node: a}, {Node: b}, {Node: a, Prefix: "X="}})
+
+	assert.Equal(t, []ImageValue{{Node: a}, {Node: b}}, unique)
+}
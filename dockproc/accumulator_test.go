@@ -156,3 +156,28 @@ func TestContainsAccumulator(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchesAccumulatorKeepsLocations(t *testing.T) {
+	location := dockfmt.Location{File: "Dockerfile", Line: 1, Column: 6, EndLine: 1, EndColumn: 11}
+	mockFormat := delegatingFormatMockNew()
+	mockFormat.ProcessDelegate = func(log logrus.FieldLogger, reader io.Reader, writer io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+		ref, _ := dockref.FromOriginal("nginx")
+		_, err := imageNameProcessor(dockfmt.LocatedReferenceNew(ref, location))
+		return err
+	}
+
+	p := new(PredicateMock)
+	p.On("Matches", mock.Anything).Return(true)
+
+	logger := logrus.New()
+	logger.SetOutput(bytes.NewBuffer(nil))
+	accumulator, _ := MatchesAccumulatorNew(p, logger, bytes.NewBuffer(nil))
+	err := accumulator.Accumulate(dockfmt.FormatProcessorNew(mockFormat, nil, nil))
+	assert.Nil(t, err)
+
+	matches := accumulator.Matches()
+	assert.Len(t, matches, 1)
+	actual, ok := dockfmt.LocationOf(matches[0])
+	assert.True(t, ok)
+	assert.Equal(t, location, actual)
+}
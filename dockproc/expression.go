@@ -0,0 +1,247 @@
+package dockproc
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// PredicateFactory creates the predicate for a name used in an expression from the arguments given in parentheses
+type PredicateFactory func(args []string) (Predicate, error)
+
+// ExpressionError is an error in an expression, Column is the position of the offending character starting at 1
+type ExpressionError struct {
+	Expression string
+	Column     int
+	Message    string
+}
+
+func (e ExpressionError) Error() string {
+	return fmt.Sprintf("%s at column %d of '%s'", e.Message, e.Column, e.Expression)
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenOpen
+	tokenClose
+	tokenComma
+)
+
+type token struct {
+	kind   tokenKind
+	value  string
+	column int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEnd:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string '%s'", t.value)
+	default:
+		return fmt.Sprintf("'%s'", t.value)
+	}
+}
+
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenIdentifier && strings.EqualFold(t.value, keyword)
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+func tokenize(expression string) ([]token, error) {
+	runes := []rune(expression)
+	tokens := make([]token, 0)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		column := i + 1
+		switch {
+		case unicode.IsSpace(r):
+		case r == '(':
+			tokens = append(tokens, token{tokenOpen, "(", column})
+		case r == ')':
+			tokens = append(tokens, token{tokenClose, ")", column})
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ",", column})
+		case r == '"' || r == '\'':
+			value := make([]rune, 0)
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == r || runes[i+1] == '\\') {
+					i++
+				} else if runes[i] == r {
+					closed = true
+					break
+				}
+				value = append(value, runes[i])
+			}
+			if !closed {
+				return nil, ExpressionError{expression, column, "Unterminated string"}
+			}
+			tokens = append(tokens, token{tokenString, string(value), column})
+		case isIdentifierRune(r):
+			start := i
+			for i+1 < len(runes) && isIdentifierRune(runes[i+1]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdentifier, string(runes[start : i+1]), column})
+		default:
+			return nil, ExpressionError{expression, column, fmt.Sprintf("Unexpected character '%c'", r)}
+		}
+	}
+	return append(tokens, token{tokenEnd, "", len(runes) + 1}), nil
+}
+
+type expressionParser struct {
+	expression string
+	tokens     []token
+	position   int
+	factories  map[string]PredicateFactory
+}
+
+// ParseExpression parses an expression like (unpinned or latest) and not domain("internal.example.com")
+// into a tree of predicates. Predicates are combined with and, or, not and parentheses; not binds strongest,
+// or weakest. The names of predicates are looked up in factories, arguments are quoted strings
+func ParseExpression(expression string, factories map[string]PredicateFactory) (Predicate, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &expressionParser{
+		expression: expression,
+		tokens:     tokens,
+		factories:  factories,
+	}
+
+	predicate, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if next := parser.peek(); next.kind != tokenEnd {
+		return nil, parser.errorAt(next, fmt.Sprintf("Unexpected %s", next))
+	}
+	return predicate, nil
+}
+
+func (p *expressionParser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *expressionParser) next() token {
+	t := p.tokens[p.position]
+	if t.kind != tokenEnd {
+		p.position++
+	}
+	return t
+}
+
+func (p *expressionParser) errorAt(t token, message string) error {
+	return ExpressionError{p.expression, t.column, message}
+}
+
+func (p *expressionParser) parseOr() (Predicate, error) {
+	return p.parseBinary("or", p.parseAnd, OrPredicateNew)
+}
+
+func (p *expressionParser) parseAnd() (Predicate, error) {
+	return p.parseBinary("and", p.parseUnary, AndPredicateNew)
+}
+
+func (p *expressionParser) parseBinary(keyword string, parseOperand func() (Predicate, error), combine func([]Predicate) Predicate) (Predicate, error) {
+	operand, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Predicate{operand}
+	for p.peek().isKeyword(keyword) {
+		p.next()
+		operand, err = parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return combine(operands), nil
+}
+
+func (p *expressionParser) parseUnary() (Predicate, error) {
+	if p.peek().isKeyword("not") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NotPredicateNew(operand), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (Predicate, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenOpen:
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenClose {
+			return nil, p.errorAt(closing, fmt.Sprintf("Expected ')' but found %s", closing))
+		}
+		return predicate, nil
+	case t.kind == tokenIdentifier && !t.isKeyword("and") && !t.isKeyword("or") && !t.isKeyword("not"):
+		return p.parsePredicate(t)
+	default:
+		return nil, p.errorAt(t, fmt.Sprintf("Expected predicate but found %s", t))
+	}
+}
+
+func (p *expressionParser) parsePredicate(name token) (Predicate, error) {
+	factory, ok := p.factories[name.value]
+	if !ok {
+		return nil, p.errorAt(name, fmt.Sprintf("Unknown predicate '%s'", name.value))
+	}
+
+	args := make([]string, 0)
+	if p.peek().kind == tokenOpen {
+		p.next()
+		if p.peek().kind == tokenClose {
+			p.next()
+		} else {
+			for {
+				arg := p.next()
+				if arg.kind != tokenString {
+					return nil, p.errorAt(arg, fmt.Sprintf("Expected quoted argument but found %s", arg))
+				}
+				args = append(args, arg.value)
+
+				separator := p.next()
+				if separator.kind == tokenClose {
+					break
+				}
+				if separator.kind != tokenComma {
+					return nil, p.errorAt(separator, fmt.Sprintf("Expected ',' or ')' but found %s", separator))
+				}
+			}
+		}
+	}
+
+	predicate, err := factory(args)
+	if err != nil {
+		return nil, p.errorAt(name, err.Error())
+	}
+	return predicate, nil
+}
@@ -0,0 +1,99 @@
+package dockproc
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// namedPredicate records the name and arguments it was created with
+type namedPredicate struct {
+	name string
+	args []string
+}
+
+func (namedPredicate) Matches(ref dockref.Reference) bool {
+	return true
+}
+
+func testFactories() map[string]PredicateFactory {
+	factory := func(name string) PredicateFactory {
+		return func(args []string) (Predicate, error) {
+			return namedPredicate{name, args}, nil
+		}
+	}
+
+	return map[string]PredicateFactory{
+		"unpinned": factory("unpinned"),
+		"latest":   factory("latest"),
+		"untagged": factory("untagged"),
+		"domain":   factory("domain"),
+		"failing": func(args []string) (Predicate, error) {
+			return nil, errors.New("Failing predicate")
+		},
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	unpinned := namedPredicate{"unpinned", []string{}}
+	latest := namedPredicate{"latest", []string{}}
+	untagged := namedPredicate{"untagged", []string{}}
+
+	expectations := map[string]Predicate{
+		"unpinned":                          unpinned,
+		"unpinned or latest":                OrPredicateNew([]Predicate{unpinned, latest}),
+		"unpinned and latest":               AndPredicateNew([]Predicate{unpinned, latest}),
+		"not unpinned":                      NotPredicateNew(unpinned),
+		"not not unpinned":                  NotPredicateNew(NotPredicateNew(unpinned)),
+		"unpinned or latest and untagged":   OrPredicateNew([]Predicate{unpinned, AndPredicateNew([]Predicate{latest, untagged})}),
+		"(unpinned or latest) and untagged": AndPredicateNew([]Predicate{OrPredicateNew([]Predicate{unpinned, latest}), untagged}),
+		"not unpinned and latest":           AndPredicateNew([]Predicate{NotPredicateNew(unpinned), latest}),
+		"unpinned OR latest":                OrPredicateNew([]Predicate{unpinned, latest}),
+		"latest()":                          latest,
+		`domain("a.io", 'b.io')`:            namedPredicate{"domain", []string{"a.io", "b.io"}},
+		`domain("with \" quote")`:           namedPredicate{"domain", []string{`with " quote`}},
+		`(unpinned or latest) and not domain("internal.example.com")`: AndPredicateNew([]Predicate{
+			OrPredicateNew([]Predicate{unpinned, latest}),
+			NotPredicateNew(namedPredicate{"domain", []string{"internal.example.com"}}),
+		}),
+	}
+
+	for expression, expected := range expectations {
+		t.Run(expression, func(t *testing.T) {
+			predicate, err := ParseExpression(expression, testFactories())
+			assert.Nil(t, err)
+			assert.Equal(t, expected, predicate)
+		})
+	}
+}
+
+func TestParseExpressionReportsColumn(t *testing.T) {
+	expectations := map[string]ExpressionError{
+		"":                      {Column: 1, Message: "Expected predicate but found end of expression"},
+		"unpinned or":           {Column: 12, Message: "Expected predicate but found end of expression"},
+		"unpinned latest":       {Column: 10, Message: "Unexpected 'latest'"},
+		"(unpinned or latest":   {Column: 20, Message: "Expected ')' but found end of expression"},
+		"unpinned or lates":     {Column: 13, Message: "Unknown predicate 'lates'"},
+		"unpinned and ) ":       {Column: 14, Message: "Expected predicate but found ')'"},
+		`domain("a.io" "b.io")`: {Column: 15, Message: "Expected ',' or ')' but found string 'b.io'"},
+		`domain(a)`:             {Column: 8, Message: "Expected quoted argument but found 'a'"},
+		`domain("a.io`:          {Column: 8, Message: "Unterminated string"},
+		"unpinned && latest":    {Column: 10, Message: "Unexpected character '&'"},
+		"ä or failing":          {Column: 1, Message: "Unknown predicate 'ä'"},
+		"latest or failing":     {Column: 11, Message: "Failing predicate"},
+	}
+
+	for expression, expected := range expectations {
+		t.Run(expression, func(t *testing.T) {
+			_, err := ParseExpression(expression, testFactories())
+			expected.Expression = expression
+			assert.Equal(t, expected, err)
+		})
+	}
+}
+
+func TestExpressionErrorMessage(t *testing.T) {
+	_, err := ParseExpression("unpinned or lates", testFactories())
+	assert.EqualError(t, err, "Unknown predicate 'lates' at column 13 of 'unpinned or lates'")
+}
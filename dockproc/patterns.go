@@ -0,0 +1,164 @@
+package dockproc
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+var _ Predicate = (*patternsPredicate)(nil)
+
+// patternsPredicate matches when any of the values of a reference matches any of the patterns
+type patternsPredicate struct {
+	patterns []*regexp.Regexp
+	values   func(ref dockref.Reference) []string
+}
+
+func (p patternsPredicate) Matches(ref dockref.Reference) bool {
+	for _, value := range p.values(ref) {
+		for _, pattern := range p.patterns {
+			if pattern.MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func domainValues(ref dockref.Reference) []string {
+	return []string{ref.Domain()}
+}
+
+// nameValues are the forms a name can be written in, e.g. nginx, library/nginx and docker.io/library/nginx
+func nameValues(ref dockref.Reference) []string {
+	if ref.Named() == nil {
+		return nil
+	}
+	return []string{reference.FamiliarName(ref.Named()), ref.Path(), ref.Name()}
+}
+
+func tagValues(ref dockref.Reference) []string {
+	return []string{ref.Tag()}
+}
+
+// DomainPatternsPredicateNew matches domains like DomainsPredicateNew, but the domains may be globs like *.example.com
+func DomainPatternsPredicateNew(domains []string) (Predicate, error) {
+	if !containsGlob(domains) {
+		return DomainsPredicateNew(domains), nil
+	}
+	return globsPredicateNew(domains, domainValues)
+}
+
+// NamePatternsPredicateNew matches names like NamesPredicateNew, but the names may be globs like myorg/*
+func NamePatternsPredicateNew(names []string) (Predicate, error) {
+	if !containsGlob(names) {
+		return NamesPredicateNew(names), nil
+	}
+	return globsPredicateNew(names, nameValues)
+}
+
+// TagPatternsPredicateNew matches tags like TagsPredicateNew, but the tags may be globs like 1.*-alpine
+func TagPatternsPredicateNew(tags []string) (Predicate, error) {
+	if !containsGlob(tags) {
+		return TagsPredicateNew(tags), nil
+	}
+	return globsPredicateNew(tags, tagValues)
+}
+
+// DomainRegexpsPredicateNew matches domains that contain a match of one of the regular expressions
+func DomainRegexpsPredicateNew(expressions []string) (Predicate, error) {
+	return regexpsPredicateNew(expressions, domainValues)
+}
+
+// NameRegexpsPredicateNew matches names that contain a match of one of the regular expressions.
+// Like with NamesPredicateNew, the familiar name, the path and the fully qualified name are tried
+func NameRegexpsPredicateNew(expressions []string) (Predicate, error) {
+	return regexpsPredicateNew(expressions, nameValues)
+}
+
+// TagRegexpsPredicateNew matches tags that contain a match of one of the regular expressions
+func TagRegexpsPredicateNew(expressions []string) (Predicate, error) {
+	return regexpsPredicateNew(expressions, tagValues)
+}
+
+func regexpsPredicateNew(expressions []string, values func(ref dockref.Reference) []string) (Predicate, error) {
+	patterns := make([]*regexp.Regexp, 0, len(expressions))
+	for _, expression := range expressions {
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, errors.Errorf("Invalid regular expression '%s': %s", expression, err.Error())
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patternsPredicate{patterns: patterns, values: values}, nil
+}
+
+func globsPredicateNew(globs []string, values func(ref dockref.Reference) []string) (Predicate, error) {
+	patterns := make([]*regexp.Regexp, 0, len(globs))
+	for _, glob := range globs {
+		pattern, err := globRegexp(glob)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patternsPredicate{patterns: patterns, values: values}, nil
+}
+
+func containsGlob(values []string) bool {
+	for _, value := range values {
+		if strings.ContainsAny(value, "*?[") {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp compiles a glob to a regular expression that matches the complete value.
+// Like in paths, * and ? do not match /, ** matches anything. Character classes like [a-z] and [!0-9]
+// are supported, \ escapes the next character
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	pattern := "^"
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				pattern += ".*"
+				i++
+			} else {
+				pattern += "[^/]*"
+			}
+		case '?':
+			pattern += "[^/]"
+		case '[':
+			end := strings.Index(glob[i+1:], "]")
+			if end < 0 {
+				return nil, errors.Errorf("Invalid glob '%s': missing ]", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			pattern += "[" + class + "]"
+			i += end + 1
+		case '\\':
+			if i+1 >= len(glob) {
+				return nil, errors.Errorf("Invalid glob '%s': trailing \\", glob)
+			}
+			pattern += regexp.QuoteMeta(glob[i+1 : i+2])
+			i++
+		default:
+			pattern += regexp.QuoteMeta(string(c))
+		}
+	}
+	pattern += "$"
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Errorf("Invalid glob '%s': %s", glob, err.Error())
+	}
+	return compiled, nil
+}
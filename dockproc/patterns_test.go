@@ -0,0 +1,99 @@
+package dockproc
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func assertMatches(t *testing.T, predicate Predicate, matching []string, notMatching []string) {
+	for _, original := range matching {
+		ref, err := dockref.FromOriginal(original)
+		assert.Nil(t, err)
+		assert.True(t, predicate.Matches(ref), "%s should match", original)
+	}
+	for _, original := range notMatching {
+		ref, err := dockref.FromOriginal(original)
+		assert.Nil(t, err)
+		assert.False(t, predicate.Matches(ref), "%s should not match", original)
+	}
+}
+
+func TestPatternsPredicatesWithoutGlobsMatchExactly(t *testing.T) {
+	p, err := DomainPatternsPredicateNew([]string{"quay.io"})
+	assert.Nil(t, err)
+	assert.IsType(t, DomainsPredicateNew(nil), p)
+
+	p, err = NamePatternsPredicateNew([]string{"nginx"})
+	assert.Nil(t, err)
+	assert.IsType(t, NamesPredicateNew(nil), p)
+
+	p, err = TagPatternsPredicateNew([]string{"1.15"})
+	assert.Nil(t, err)
+	assert.IsType(t, TagsPredicateNew(nil), p)
+}
+
+func TestDomainPatternsPredicate(t *testing.T) {
+	p, err := DomainPatternsPredicateNew([]string{"*.corp.example.com", "quay.io"})
+	assert.Nil(t, err)
+	assertMatches(t, p,
+		[]string{"registry.corp.example.com/app", "eu.corp.example.com/team/app:1.0", "quay.io/coreos/etcd"},
+		[]string{"corp.example.com/app", "nginx", "registry.corp.example.com.evil.io/app"})
+}
+
+func TestNamePatternsPredicate(t *testing.T) {
+	p, err := NamePatternsPredicateNew([]string{"myorg/*"})
+	assert.Nil(t, err)
+	assertMatches(t, p,
+		[]string{"myorg/app", "myorg/app:1.0", "quay.io/myorg/tool", "docker.io/myorg/app"},
+		[]string{"myorg/team/app", "nginx", "otherorg/app"})
+
+	p, err = NamePatternsPredicateNew([]string{"myorg/**", "ngin?"})
+	assert.Nil(t, err)
+	assertMatches(t, p,
+		[]string{"myorg/team/app", "nginx", "docker.io/library/nginx"},
+		[]string{"nginx-unit", "otherorg/app"})
+}
+
+func TestTagPatternsPredicate(t *testing.T) {
+	p, err := TagPatternsPredicateNew([]string{"1.*-alpine", "[0-9].[!0-9]"})
+	assert.Nil(t, err)
+	assertMatches(t, p,
+		[]string{"nginx:1.15-alpine", "nginx:1.15.3-alpine", "nginx:2.x"},
+		[]string{"nginx:1.15", "nginx", "nginx:2.15-alpine", "nginx:2.1"})
+}
+
+func TestRegexpsPredicates(t *testing.T) {
+	p, err := TagRegexpsPredicateNew([]string{`^\d+\.\d+-alpine$`})
+	assert.Nil(t, err)
+	assertMatches(t, p,
+		[]string{"nginx:1.15-alpine"},
+		[]string{"nginx:1.15.3-alpine", "nginx:1.15", "nginx"})
+
+	p, err = DomainRegexpsPredicateNew([]string{`\.example\.com$`})
+	assert.Nil(t, err)
+	assertMatches(t, p,
+		[]string{"registry.example.com/app"},
+		[]string{"nginx", "example.com.io/app"})
+
+	p, err = NameRegexpsPredicateNew([]string{`^myorg/`, `^nginx$`})
+	assert.Nil(t, err)
+	assertMatches(t, p,
+		[]string{"myorg/app", "quay.io/myorg/app", "nginx:1.15"},
+		[]string{"library/nginx-unit", "otherorg/myorg"})
+}
+
+func TestInvalidPatternsAreReported(t *testing.T) {
+	_, err := TagRegexpsPredicateNew([]string{`^(\d+`})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid regular expression '^(\\d+'")
+
+	_, err = NamePatternsPredicateNew([]string{"myorg/[a-z"})
+	assert.EqualError(t, err, "Invalid glob 'myorg/[a-z': missing ]")
+
+	_, err = DomainPatternsPredicateNew([]string{"*.example.com\\"})
+	assert.EqualError(t, err, "Invalid glob '*.example.com\\': trailing \\")
+
+	_, err = TagPatternsPredicateNew([]string{"[z-a]*"})
+	assert.Error(t, err)
+}
@@ -57,18 +57,49 @@ func UnpinnedPredicateNew() Predicate {
 	return unpinnedPredicate{}
 }
 
-//var _ Predicate = (*outdatedPredicate)(nil)
-//
-//type outdatedPredicate struct {
-//}
-//
-//func (outdatedPredicate) Matches(ref dockref.Reference) bool {
-//	panic("not implemented")
-//}
-//
-//func OutdatedPredicateNew() Predicate {
-//	return outdatedPredicate{}
-//}
+// TagSource lists the tags available for the repository of an image reference
+type TagSource interface {
+	ListTags(ref dockref.Reference) ([]string, error)
+}
+
+var _ Predicate = (*outdatedPredicate)(nil)
+
+type outdatedPredicate struct {
+	tagSource TagSource
+}
+
+func (p outdatedPredicate) Matches(ref dockref.Reference) bool {
+	if ref.Named() == nil {
+		return false
+	}
+
+	current, err := dockref.ParseTagVersion(ref.Tag())
+	if err != nil {
+		return false
+	}
+
+	tags, err := p.tagSource.ListTags(ref)
+	if err != nil {
+		return false
+	}
+
+	for _, tag := range tags {
+		candidate, err := dockref.ParseTagVersion(tag)
+		if err != nil {
+			continue
+		}
+
+		if current.IsOlderThan(candidate) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func OutdatedPredicateNew(tagSource TagSource) Predicate {
+	return outdatedPredicate{tagSource: tagSource}
+}
 
 var _ Predicate = (*untaggedPredicate)(nil)
 
@@ -160,6 +191,30 @@ func TagsPredicateNew(tags []string) Predicate {
 	return tagsPredicate{tags: tags}
 }
 
+// NonSemverHandler decides whether an image reference matches when its tag is no semantic version
+type NonSemverHandler func(ref dockref.Reference) bool
+
+var _ Predicate = (*semverPredicate)(nil)
+
+type semverPredicate struct {
+	versionRange dockref.VersionRange
+	nonSemver    NonSemverHandler
+}
+
+func (p semverPredicate) Matches(ref dockref.Reference) bool {
+	version, err := dockref.ParseTagVersion(ref.Tag())
+	if err != nil {
+		return p.nonSemver(ref)
+	}
+	return p.versionRange.Contains(version)
+}
+
+// SemverPredicateNew matches tags like 1.11, v1.11.3 or 1.11.3-alpine that are semantic versions within the range.
+// Image references without tag or with a tag like latest are passed to nonSemver
+func SemverPredicateNew(versionRange dockref.VersionRange, nonSemver NonSemverHandler) Predicate {
+	return semverPredicate{versionRange: versionRange, nonSemver: nonSemver}
+}
+
 var _ Predicate = (*digestsPredicate)(nil)
 
 type digestsPredicate struct {
@@ -206,3 +261,54 @@ func (a andPredicate) Matches(ref dockref.Reference) bool {
 func AndPredicateNew(predicates []Predicate) Predicate {
 	return andPredicate{predicates: predicates}
 }
+
+type OrPredicate interface {
+	Predicate
+	Predicates() []Predicate
+}
+
+var _ OrPredicate = (*orPredicate)(nil)
+
+type orPredicate struct {
+	predicates []Predicate
+}
+
+func (o orPredicate) Predicates() []Predicate {
+	return o.predicates
+}
+
+func (o orPredicate) Matches(ref dockref.Reference) bool {
+	for _, p := range o.predicates {
+		if p.Matches(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+func OrPredicateNew(predicates []Predicate) Predicate {
+	return orPredicate{predicates: predicates}
+}
+
+type NotPredicate interface {
+	Predicate
+	Predicate() Predicate
+}
+
+var _ NotPredicate = (*notPredicate)(nil)
+
+type notPredicate struct {
+	predicate Predicate
+}
+
+func (n notPredicate) Predicate() Predicate {
+	return n.predicate
+}
+
+func (n notPredicate) Matches(ref dockref.Reference) bool {
+	return !n.predicate.Matches(ref)
+}
+
+func NotPredicateNew(predicate Predicate) Predicate {
+	return notPredicate{predicate: predicate}
+}
@@ -2,6 +2,7 @@ package dockproc
 
 import (
 	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -118,6 +119,74 @@ func TestUntaggedPredicate(t *testing.T) {
 
 }
 
+var _ TagSource = (*tagSourceFake)(nil)
+
+type tagSourceFake struct {
+	tags  map[string][]string
+	calls int
+}
+
+func (f *tagSourceFake) ListTags(ref dockref.Reference) ([]string, error) {
+	f.calls++
+	tags, ok := f.tags[ref.Name()]
+	if !ok {
+		return nil, errors.Errorf("unknown repository %s", ref.Name())
+	}
+	return tags, nil
+}
+
+// models the image series created by test_images/create.sh
+func testImageATagSource() *tagSourceFake {
+	return &tagSourceFake{tags: map[string][]string{
+		"docker.io/menedev/testimagea": {"1.0.0", "1.0.1", "1.1.0", "1.1.1", "2.0.0", "latest"},
+	}}
+}
+
+func TestOutdatedPredicate(t *testing.T) {
+
+	predicate := OutdatedPredicateNew(testImageATagSource())
+
+	shouldMatches := []string{"menedev/testimagea:1.0.0", "menedev/testimagea:1.0.1", "menedev/testimagea:1.1.0",
+		"menedev/testimagea:1.1.1", "menedev/testimagea:1", "menedev/testimagea:v1.1",
+		"menedev/testimagea:1.1.1@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240",
+	}
+
+	for _, original := range shouldMatches {
+		t.Run("Matches "+original, func(t *testing.T) {
+			ref, e := dockref.FromOriginal(original)
+
+			assert.Nil(t, e)
+			assert.True(t, predicate.Matches(ref))
+		})
+	}
+
+	shouldNotMatches := []string{"menedev/testimagea:2.0.0", "menedev/testimagea:2", "menedev/testimagea:latest",
+		"menedev/testimagea", "menedev/testimagea:1.0.0-alpine", "menedev/testimagea:3.0.0",
+		"menedev/testimagea@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240",
+		"d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240",
+		"menedev/unknown:1.0.0",
+	}
+
+	for _, original := range shouldNotMatches {
+		t.Run("Not matching "+original, func(t *testing.T) {
+			ref, e := dockref.FromOriginal(original)
+
+			assert.Nil(t, e)
+			assert.False(t, predicate.Matches(ref))
+		})
+	}
+}
+
+func TestOutdatedPredicateDoesNotListTagsOfNonVersionTags(t *testing.T) {
+	tagSource := testImageATagSource()
+	predicate := OutdatedPredicateNew(tagSource)
+
+	ref, _ := dockref.FromOriginal("menedev/testimagea:latest")
+	predicate.Matches(ref)
+
+	assert.Equal(t, 0, tagSource.calls)
+}
+
 func TestDomainsPredicate(t *testing.T) {
 
 	predicate := DomainsPredicateNew([]string{"my.com", "my2.com"})
@@ -243,6 +312,51 @@ func TestTagsPredicate(t *testing.T) {
 
 }
 
+func TestSemverPredicate(t *testing.T) {
+	versionRange, err := dockref.ParseVersionRange("<1.12 || >=2.0.0-0")
+	assert.Nil(t, err)
+
+	nonSemver := make([]string, 0)
+	predicate := SemverPredicateNew(versionRange, func(ref dockref.Reference) bool {
+		nonSemver = append(nonSemver, ref.Original())
+		return ref.Tag() == "latest"
+	})
+
+	shouldMatches := []string{
+		"golang:1.11",
+		"golang:v1.11.5-alpine",
+		"my.com/golang:2.0.0-rc.1@sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240",
+		"golang:latest",
+	}
+
+	for _, original := range shouldMatches {
+		t.Run("Matches "+original, func(t *testing.T) {
+			ref, e := dockref.FromOriginal(original)
+
+			assert.Nil(t, e)
+			assert.True(t, predicate.Matches(ref))
+		})
+	}
+
+	shouldNotMatches := []string{
+		"golang:1.12",
+		"golang:1.15.3-alpine",
+		"golang",
+		"golang:stretch",
+	}
+
+	for _, original := range shouldNotMatches {
+		t.Run("Not matching "+original, func(t *testing.T) {
+			ref, e := dockref.FromOriginal(original)
+
+			assert.Nil(t, e)
+			assert.False(t, predicate.Matches(ref))
+		})
+	}
+
+	assert.Equal(t, []string{"golang:latest", "golang", "golang:stretch"}, nonSemver)
+}
+
 func TestDigestsPredicate(t *testing.T) {
 
 	predicate := DigestsPredicateNew([]string{
@@ -352,3 +466,56 @@ func TestAndPredicate_Predicates(t *testing.T) {
 	assert.Contains(t, ps, p2)
 	assert.Contains(t, ps, p3)
 }
+
+func TestOrPredicate_Matches(t *testing.T) {
+	ref, _ := dockref.FromOriginal("a")
+	or := func(matches ...bool) Predicate {
+		predicates := make([]Predicate, 0)
+
+		for _, v := range matches {
+			predicates = append(predicates, mockPredicate{v})
+		}
+
+		return OrPredicateNew(predicates)
+	}
+
+	t.Run("Not matching without predicates", func(t *testing.T) {
+		assert.False(t, or().Matches(ref))
+	})
+
+	t.Run("Matches when only predicate matches", func(t *testing.T) {
+		assert.True(t, or(true).Matches(ref))
+	})
+
+	t.Run("Matches when one of two predicates matches", func(t *testing.T) {
+		assert.True(t, or(true, false).Matches(ref))
+		assert.True(t, or(false, true).Matches(ref))
+	})
+
+	t.Run("Not matching when no predicate matches", func(t *testing.T) {
+		assert.False(t, or(false, false, false).Matches(ref))
+	})
+}
+
+func TestOrPredicate_Predicates(t *testing.T) {
+	p1 := mockPredicate{true}
+	p2 := mockPredicate{false}
+
+	p := OrPredicateNew([]Predicate{p1, p2})
+
+	o, ok := p.(OrPredicate)
+	assert.True(t, ok)
+	assert.Equal(t, []Predicate{p1, p2}, o.Predicates())
+}
+
+func TestNotPredicate(t *testing.T) {
+	ref, _ := dockref.FromOriginal("a")
+
+	assert.False(t, NotPredicateNew(mockPredicate{true}).Matches(ref))
+	assert.True(t, NotPredicateNew(mockPredicate{false}).Matches(ref))
+
+	p := mockPredicate{true}
+	n, ok := NotPredicateNew(p).(NotPredicate)
+	assert.True(t, ok)
+	assert.Equal(t, p, n.Predicate())
+}
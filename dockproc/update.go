@@ -0,0 +1,99 @@
+package dockproc
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+)
+
+// UpdateStrategy limits which newer versions a tag may be updated to
+type UpdateStrategy int
+
+const (
+	// UpdatePatch updates to the newest version with the same major and minor version, tags like 1.15
+	// that have no patch version are updated to the newest patch version, e.g. 1.15.3
+	UpdatePatch UpdateStrategy = iota
+	// UpdateMinor updates to the newest version with the same major version
+	UpdateMinor UpdateStrategy = iota
+	// UpdateMajor updates to the newest version
+	UpdateMajor UpdateStrategy = iota
+	// UpdateLatest updates to the newest version, even if it is more or less specific than the current tag
+	UpdateLatest UpdateStrategy = iota
+)
+
+var updateStrategyNames = map[string]UpdateStrategy{
+	"patch":  UpdatePatch,
+	"minor":  UpdateMinor,
+	"major":  UpdateMajor,
+	"latest": UpdateLatest,
+}
+
+// UpdateStrategyFromString returns the strategy with the given name, i.e. patch, minor, major or latest
+func UpdateStrategyFromString(name string) (UpdateStrategy, error) {
+	strategy, ok := updateStrategyNames[name]
+	if !ok {
+		return 0, errors.Errorf("Unknown update strategy '%s'", name)
+	}
+	return strategy, nil
+}
+
+// FindUpdate returns the tag ref should be updated to according to the strategy.
+// Only tags of the same variant (e.g. -alpine) are considered and all strategies but UpdateLatest
+// only consider tags as specific as the current one, i.e. 1.12 is updated to 1.13, but not to 1.13.1.
+// UpdatePatch updates tags without patch version to the newest patch version instead, i.e. 1.12 to 1.12.3.
+// The boolean result is false when there is no newer tag.
+func FindUpdate(ref dockref.Reference, tags []string, strategy UpdateStrategy) (string, bool) {
+	current, err := dockref.ParseTagVersion(ref.Tag())
+	if err != nil {
+		return "", false
+	}
+
+	if strategy == UpdatePatch && current.Precision == 2 {
+		// 1.12 has no newer patch version of the same precision, so it is compared as 1.12.0
+		current.Precision = 3
+	}
+
+	bestTag := ""
+	var best dockref.TagVersion
+	for _, tag := range tags {
+		candidate, err := dockref.ParseTagVersion(tag)
+		if err != nil {
+			continue
+		}
+
+		if !current.IsOlderThan(candidate) || !allowedByStrategy(current, candidate, strategy) {
+			continue
+		}
+
+		if bestTag == "" || isBetterUpdate(candidate, best) {
+			bestTag = tag
+			best = candidate
+		}
+	}
+
+	return bestTag, bestTag != ""
+}
+
+func allowedByStrategy(current dockref.TagVersion, candidate dockref.TagVersion, strategy UpdateStrategy) bool {
+	if strategy != UpdateLatest && candidate.Precision != current.Precision {
+		return false
+	}
+
+	switch strategy {
+	case UpdatePatch:
+		return candidate.Major == current.Major && candidate.Minor == current.Minor
+	case UpdateMinor:
+		return candidate.Major == current.Major
+	default:
+		return true
+	}
+}
+
+func isBetterUpdate(candidate dockref.TagVersion, best dockref.TagVersion) bool {
+	c := candidate.Compare(best)
+	if c != 0 {
+		return c > 0
+	}
+
+	// prefer the more specific tag of equal versions, e.g. 2.0.0 over 2
+	return candidate.Precision > best.Precision
+}
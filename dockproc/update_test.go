@@ -0,0 +1,67 @@
+package dockproc
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var updateTestTags = []string{"latest", "1", "1.14", "1.14.0", "1.14.2", "1.15", "1.15.0", "1.15.3", "1.15.3-alpine",
+	"1.16-alpine", "1.16.1-alpine", "2", "2.0", "2.0.0", "2.0.1-rc1", "2.1.0-rc1"}
+
+func TestFindUpdate(t *testing.T) {
+	expectations := []struct {
+		original string
+		strategy UpdateStrategy
+		expected string
+	}{
+		{"nginx:1.14.0", UpdatePatch, "1.14.2"},
+		{"nginx:1.14.0", UpdateMinor, "1.15.3"},
+		{"nginx:1.14.0", UpdateMajor, "2.0.0"},
+		{"nginx:1.14", UpdatePatch, "1.14.2"},
+		{"nginx:1.15", UpdatePatch, "1.15.3"},
+		{"nginx:1.15-alpine", UpdatePatch, "1.15.3-alpine"},
+		{"nginx:1", UpdatePatch, ""},
+		{"nginx:1.14", UpdateMinor, "1.15"},
+		{"nginx:1.14", UpdateMajor, "2.0"},
+		{"nginx:1", UpdateMajor, "2"},
+		{"nginx:1", UpdateLatest, "2.0.0"},
+		{"nginx:1.15.3-alpine", UpdatePatch, ""},
+		{"nginx:1.15-alpine", UpdateMinor, "1.16-alpine"},
+		{"nginx:1.15-alpine", UpdateLatest, "1.16.1-alpine"},
+		{"nginx:2.0.1-rc1", UpdateMinor, "2.1.0-rc1"},
+		{"nginx:2.0.0", UpdateMinor, ""},
+		{"nginx:latest", UpdateLatest, ""},
+		{"nginx", UpdateLatest, ""},
+	}
+
+	for _, e := range expectations {
+		e := e
+		t.Run(e.original, func(t *testing.T) {
+			ref, err := dockref.FromOriginal(e.original)
+			assert.Nil(t, err)
+
+			tag, found := FindUpdate(ref, updateTestTags, e.strategy)
+			assert.Equal(t, e.expected, tag)
+			assert.Equal(t, e.expected != "", found)
+		})
+	}
+}
+
+func TestUpdateStrategyFromString(t *testing.T) {
+	expectations := map[string]UpdateStrategy{
+		"patch":  UpdatePatch,
+		"minor":  UpdateMinor,
+		"major":  UpdateMajor,
+		"latest": UpdateLatest,
+	}
+
+	for name, expected := range expectations {
+		strategy, err := UpdateStrategyFromString(name)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, strategy)
+	}
+
+	_, err := UpdateStrategyFromString("newest")
+	assert.Error(t, err)
+}
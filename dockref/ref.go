@@ -4,6 +4,8 @@ import (
 	_ "crypto/sha256" // side effect: register sha256
 	"github.com/docker/distribution/reference"
 	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"strings"
 )
 
 func FromOriginal(original string) (ref Reference, e error) {
@@ -54,6 +56,8 @@ type Reference interface {
 	Domain() string
 	Path() string
 	Named() reference.Named
+	WithDigest(dig digest.Digest) (Reference, error)
+	WithTag(tag string) (Reference, error)
 }
 
 var _ Reference = (*dockref)(nil)
@@ -99,3 +103,63 @@ func (r dockref) Domain() string {
 func (r dockref) Path() string {
 	return r.path
 }
+
+// WithDigest returns a copy of the reference that is pinned to the given digest.
+// The tag is kept and the name is written exactly as in the original.
+func (r dockref) WithDigest(dig digest.Digest) (Reference, error) {
+	if r.named == nil {
+		return nil, errors.Errorf("Cannot pin '%s': reference has no name", r.original)
+	}
+
+	var named = reference.TrimNamed(r.named)
+	if r.tag != "" {
+		tagged, err := reference.WithTag(named, r.tag)
+		if err != nil {
+			return nil, err
+		}
+		named = tagged
+	}
+
+	_, err := reference.WithDigest(named, dig)
+	if err != nil {
+		return nil, err
+	}
+
+	original := r.originalName()
+	if r.tag != "" {
+		original += ":" + r.tag
+	}
+
+	return FromOriginal(original + "@" + string(dig))
+}
+
+// WithTag returns a copy of the reference with the given tag and without digest, an empty tag removes the tag.
+// The name is written exactly as in the original.
+func (r dockref) WithTag(tag string) (Reference, error) {
+	if r.named == nil {
+		return nil, errors.Errorf("Cannot tag '%s': reference has no name", r.original)
+	}
+
+	if tag == "" {
+		return FromOriginal(r.originalName())
+	}
+
+	_, err := reference.WithTag(reference.TrimNamed(r.named), tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromOriginal(r.originalName() + ":" + tag)
+}
+
+// originalName returns the name as written in the original, e.g. library/nginx of library/nginx:1.15@sha256:...
+func (r dockref) originalName() string {
+	name := r.original
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return name
+}
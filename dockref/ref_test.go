@@ -1,6 +1,7 @@
 package dockref
 
 import (
+	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -129,4 +130,83 @@ func TestDockref_Named(t *testing.T) {
 		assert.Nil(t, e)
 		assert.Nil(t, ref.Named())
 	})
-}
\ No newline at end of file
+}
+
+func TestDockref_WithDigest(t *testing.T) {
+	dig := digest.Digest("sha256:d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240")
+
+	expectations := map[string]string{
+		"nginx":                         "nginx@" + string(dig),
+		"nginx:1.15":                    "nginx:1.15@" + string(dig),
+		"docker.io/library/nginx:1.15":  "docker.io/library/nginx:1.15@" + string(dig),
+		"example.com/image-name:latest": "example.com/image-name:latest@" + string(dig),
+		"localhost:5000/image-name":     "localhost:5000/image-name@" + string(dig),
+		"library/nginx:1.15":            "library/nginx:1.15@" + string(dig),
+		"docker.io/nginx":               "docker.io/nginx@" + string(dig),
+		"nginx:1.15@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf": "nginx:1.15@" + string(dig),
+	}
+
+	for original, expected := range expectations {
+		t.Run("Pins "+original, func(t *testing.T) {
+			ref, e := FromOriginal(original)
+			assert.Nil(t, e)
+
+			pinned, e := ref.WithDigest(dig)
+			assert.Nil(t, e)
+			assert.Equal(t, expected, pinned.Original())
+			assert.Equal(t, dig, pinned.Digest())
+			assert.Equal(t, ref.Tag(), pinned.Tag())
+			assert.Equal(t, ref.Name(), pinned.Name())
+		})
+	}
+
+	t.Run("Fails for unnamed references", func(t *testing.T) {
+		ref, e := FromOriginal("d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240")
+		assert.Nil(t, e)
+
+		pinned, e := ref.WithDigest(dig)
+		assert.Nil(t, pinned)
+		assert.Error(t, e)
+	})
+}
+
+func TestDockref_WithTag(t *testing.T) {
+	expectations := map[string]string{
+		"nginx":                         "nginx:1.16",
+		"nginx:1.15":                    "nginx:1.16",
+		"docker.io/library/nginx:1.15":  "docker.io/library/nginx:1.16",
+		"localhost:5000/image-name:1.0": "localhost:5000/image-name:1.16",
+		"library/nginx:1.15":            "library/nginx:1.16",
+		"docker.io/nginx:1.15":          "docker.io/nginx:1.16",
+		"nginx:1.15@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf": "nginx:1.16",
+	}
+
+	for original, expected := range expectations {
+		t.Run("Tags "+original, func(t *testing.T) {
+			ref, e := FromOriginal(original)
+			assert.Nil(t, e)
+
+			tagged, e := ref.WithTag("1.16")
+			assert.Nil(t, e)
+			assert.Equal(t, expected, tagged.Original())
+			assert.Equal(t, "1.16", tagged.Tag())
+			assert.Empty(t, tagged.DigestString())
+		})
+	}
+
+	t.Run("Removes tag and digest for empty tags", func(t *testing.T) {
+		ref, _ := FromOriginal("library/nginx:1.15@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf")
+		untagged, e := ref.WithTag("")
+		assert.Nil(t, e)
+		assert.Equal(t, "library/nginx", untagged.Original())
+		assert.Empty(t, untagged.Tag())
+		assert.Empty(t, untagged.DigestString())
+	})
+
+	t.Run("Fails for invalid tags", func(t *testing.T) {
+		ref, _ := FromOriginal("nginx")
+		tagged, e := ref.WithTag("in:valid")
+		assert.Nil(t, tagged)
+		assert.Error(t, e)
+	})
+}
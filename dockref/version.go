@@ -0,0 +1,182 @@
+package dockref
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TagVersion is the semantic version encoded in a tag, e.g. 1.15.3 in nginx:1.15.3-alpine
+type TagVersion struct {
+	Major uint64
+	Minor uint64
+	Patch uint64
+
+	// Precision is the number of version components given in the tag, e.g. 2 for 1.12
+	Precision int
+
+	// Prerelease is the pre-release part of the tag, e.g. rc.1 in 2.0.0-rc.1
+	Prerelease string
+
+	// Suffix is the variant following the version, e.g. alpine in 1.15.3-alpine
+	Suffix string
+}
+
+var tagVersionRegexp = regexp.MustCompile(`^[vV]?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-(.+))?$`)
+var prereleaseRegexp = regexp.MustCompile(`^(?i:alpha|beta|rc|pre|preview|dev|snapshot)(?:[.]?\d+)*$`)
+
+// ParseTagVersion parses a tag like 1, v1.12 or 1.15.3-alpine as semantic version
+func ParseTagVersion(tag string) (TagVersion, error) {
+	matches := tagVersionRegexp.FindStringSubmatch(tag)
+	if matches == nil {
+		return TagVersion{}, errors.Errorf("Tag '%s' is not a semantic version", tag)
+	}
+
+	var version TagVersion
+	components := []*uint64{&version.Major, &version.Minor, &version.Patch}
+	for i, component := range components {
+		str := matches[i+1]
+		if str == "" {
+			break
+		}
+
+		value, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return TagVersion{}, errors.Wrapf(err, "Tag '%s' is not a semantic version", tag)
+		}
+
+		*component = value
+		version.Precision = i + 1
+	}
+
+	if matches[4] != "" {
+		parts := strings.Split(matches[4], "-")
+		i := 0
+		for i < len(parts) && prereleaseRegexp.MatchString(parts[i]) {
+			i++
+		}
+		version.Prerelease = strings.Join(parts[:i], "-")
+		version.Suffix = strings.Join(parts[i:], "-")
+	}
+
+	return version, nil
+}
+
+// Truncate returns a copy of the version with all components beyond precision set to zero
+func (v TagVersion) Truncate(precision int) TagVersion {
+	if precision < 3 {
+		v.Patch = 0
+	}
+	if precision < 2 {
+		v.Minor = 0
+	}
+	if precision < v.Precision {
+		v.Precision = precision
+	}
+	return v
+}
+
+// Compare compares the versions by semantic version precedence, ignoring the suffix.
+// The result is 0 if v == other, -1 if v < other, and +1 if v > other.
+func (v TagVersion) Compare(other TagVersion) int {
+	if c := compareUint(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// IsOlderThan reports whether candidate is a newer version of the same variant.
+// Only the components given in v are compared, so 1.12 is not older than 1.12.1, but older than 1.13.
+// Pre-releases are only considered newer when v is a pre-release itself.
+func (v TagVersion) IsOlderThan(candidate TagVersion) bool {
+	if v.Suffix != candidate.Suffix {
+		return false
+	}
+
+	if candidate.Prerelease != "" && v.Prerelease == "" {
+		return false
+	}
+
+	return candidate.Truncate(v.Precision).Compare(v) > 0
+}
+
+func compareUint(a uint64, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func comparePrerelease(a string, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		// a release has higher precedence than its pre-releases
+		return 1
+	case b == "":
+		return -1
+	}
+
+	aParts := prereleaseIdentifiers(a)
+	bParts := prereleaseIdentifiers(b)
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.ParseUint(aParts[i], 10, 64)
+		bNum, bErr := strconv.ParseUint(bParts[i], 10, 64)
+
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareUint(aNum, bNum)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(strings.ToLower(aParts[i]), strings.ToLower(bParts[i]))
+		}
+
+		if c != 0 {
+			return c
+		}
+	}
+
+	return compareUint(uint64(len(aParts)), uint64(len(bParts)))
+}
+
+// prereleaseIdentifiers splits a pre-release like rc10 or beta.2 into rc, 10 and beta, 2
+func prereleaseIdentifiers(prerelease string) []string {
+	identifiers := make([]string, 0)
+	start := 0
+	for i := 1; i <= len(prerelease); i++ {
+		if i < len(prerelease) && isDigit(prerelease[i]) == isDigit(prerelease[i-1]) && !isPrereleaseSeparator(prerelease[i]) {
+			continue
+		}
+
+		identifier := strings.Trim(prerelease[start:i], ".-")
+		if identifier != "" {
+			identifiers = append(identifiers, identifier)
+		}
+		start = i
+	}
+	return identifiers
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isPrereleaseSeparator(c byte) bool {
+	return c == '.' || c == '-'
+}
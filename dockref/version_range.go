@@ -0,0 +1,273 @@
+package dockref
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type rangeOperator int
+
+const (
+	rangeLess rangeOperator = iota
+	rangeLessOrEqual
+	rangeGreater
+	rangeGreaterOrEqual
+	rangeEqual
+)
+
+// comparator compares a version to the version of a range, e.g. >=1.12.0
+type comparator struct {
+	operator rangeOperator
+	version  TagVersion
+}
+
+func (c comparator) contains(v TagVersion) bool {
+	cmp := v.Compare(c.version)
+	switch c.operator {
+	case rangeLess:
+		return cmp < 0
+	case rangeLessOrEqual:
+		return cmp <= 0
+	case rangeGreater:
+		return cmp > 0
+	case rangeGreaterOrEqual:
+		return cmp >= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// VersionRange is a set of semantic versions, e.g. '<1.12 || >=2.0.0-0'.
+// The syntax follows the ranges of npm: comparators separated by whitespace must all be satisfied,
+// alternatives are separated by ||. Supported are <, <=, >, >=, =, hyphen ranges like 1.2 - 1.4,
+// x-ranges like 1.x or 1.12, tilde ranges like ~1.2.3 and caret ranges like ^1.2.3.
+type VersionRange struct {
+	text         string
+	alternatives [][]comparator
+}
+
+var rangeVersionRegexp = regexp.MustCompile(`^[vV]?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z.-]+))?$`)
+var rangeOperatorRegexp = regexp.MustCompile(`^(<=|>=|<|>|=|~|\^)?(.*)$`)
+
+// ParseVersionRange parses a range like '<1.12 || >=2.0.0-0'
+func ParseVersionRange(text string) (VersionRange, error) {
+	versionRange := VersionRange{text: text}
+
+	for _, alternative := range strings.Split(text, "||") {
+		comparators, err := parseRangeAlternative(alternative)
+		if err != nil {
+			return VersionRange{}, errors.Errorf("Invalid version range '%s': %s", text, err.Error())
+		}
+		versionRange.alternatives = append(versionRange.alternatives, comparators)
+	}
+
+	return versionRange, nil
+}
+
+// Contains reports whether the version satisfies any of the alternatives of the range. Pre-releases
+// are ordered before their release, the suffix of the version is ignored
+func (r VersionRange) Contains(v TagVersion) bool {
+	for _, comparators := range r.alternatives {
+		if allContain(comparators, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r VersionRange) String() string {
+	return r.text
+}
+
+func allContain(comparators []comparator, v TagVersion) bool {
+	for _, c := range comparators {
+		if !c.contains(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseRangeAlternative(alternative string) ([]comparator, error) {
+	fields := strings.Fields(alternative)
+
+	// hyphen range like 1.2 - 1.4
+	if len(fields) == 3 && fields[1] == "-" {
+		lower, err := parseRangeVersion(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		upper, err := parseRangeVersion(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		return append(desugar(rangeGreaterOrEqual, lower), desugar(rangeLessOrEqual, upper)...), nil
+	}
+
+	// an empty alternative matches everything, like *
+	comparators := make([]comparator, 0)
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		// allow whitespace between operator and version, like >= 1.12
+		if rangeOperatorRegexp.FindStringSubmatch(field)[2] == "" {
+			if i+1 >= len(fields) {
+				return nil, errors.Errorf("missing version after '%s'", field)
+			}
+			i++
+			field += fields[i]
+		}
+
+		c, err := parseRangeComparator(field)
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, c...)
+	}
+
+	return comparators, nil
+}
+
+func parseRangeComparator(field string) ([]comparator, error) {
+	matches := rangeOperatorRegexp.FindStringSubmatch(field)
+
+	v, err := parseRangeVersion(matches[2])
+	if err != nil {
+		return nil, err
+	}
+
+	switch matches[1] {
+	case "<":
+		return desugar(rangeLess, v), nil
+	case "<=":
+		return desugar(rangeLessOrEqual, v), nil
+	case ">":
+		return desugar(rangeGreater, v), nil
+	case ">=":
+		return desugar(rangeGreaterOrEqual, v), nil
+	case "~":
+		return tildeRange(v), nil
+	case "^":
+		return caretRange(v), nil
+	default:
+		return desugar(rangeEqual, v), nil
+	}
+}
+
+// parseRangeVersion parses a version of a range. Components may be missing or wildcards, the precision
+// is the number of components given. Unlike in tags, anything after - is the pre-release
+func parseRangeVersion(text string) (TagVersion, error) {
+	matches := rangeVersionRegexp.FindStringSubmatch(text)
+	if matches == nil {
+		return TagVersion{}, errors.Errorf("'%s' is not a version", text)
+	}
+
+	var version TagVersion
+	components := []*uint64{&version.Major, &version.Minor, &version.Patch}
+	for i, component := range components {
+		str := matches[i+1]
+		if str == "" || strings.ContainsAny(str, "xX*") {
+			break
+		}
+
+		value, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return TagVersion{}, errors.Errorf("'%s' is not a version", text)
+		}
+
+		*component = value
+		version.Precision = i + 1
+	}
+
+	if matches[4] != "" {
+		if version.Precision < 3 {
+			return TagVersion{}, errors.Errorf("'%s' has a pre-release but not all components", text)
+		}
+		version.Prerelease = matches[4]
+	}
+
+	return version, nil
+}
+
+// bump returns the lowest version that is greater than all versions starting with the first precision
+// components of v. The pre-release 0 orders before all other pre-releases, so e.g. <2.0.0-0 excludes
+// 2.0.0-rc.1
+func bump(v TagVersion, precision int) TagVersion {
+	switch precision {
+	case 1:
+		return TagVersion{Major: v.Major + 1, Precision: 3, Prerelease: "0"}
+	case 2:
+		return TagVersion{Major: v.Major, Minor: v.Minor + 1, Precision: 3, Prerelease: "0"}
+	default:
+		return TagVersion{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, Precision: 3, Prerelease: "0"}
+	}
+}
+
+// complete returns v with the missing components set to zero and the given pre-release
+func complete(v TagVersion, prerelease string) TagVersion {
+	v.Precision = 3
+	v.Prerelease = prerelease
+	return v
+}
+
+// desugar converts a comparator with a partial version like <=1.12 into comparators with complete versions
+func desugar(operator rangeOperator, v TagVersion) []comparator {
+	if v.Precision == 3 {
+		return []comparator{{operator, v}}
+	}
+
+	if v.Precision == 0 {
+		switch operator {
+		case rangeLess, rangeGreater:
+			// nothing is below or above all versions
+			return []comparator{{rangeLess, TagVersion{Precision: 3, Prerelease: "0"}}}
+		default:
+			return []comparator{}
+		}
+	}
+
+	switch operator {
+	case rangeLess:
+		// like the upper bounds of the other ranges, <1.12 excludes the pre-releases of 1.12.0
+		return []comparator{{rangeLess, complete(v, "0")}}
+	case rangeLessOrEqual:
+		return []comparator{{rangeLess, bump(v, v.Precision)}}
+	case rangeGreater:
+		return []comparator{{rangeGreaterOrEqual, complete(bump(v, v.Precision), "")}}
+	case rangeGreaterOrEqual:
+		return []comparator{{rangeGreaterOrEqual, complete(v, v.Prerelease)}}
+	default:
+		return []comparator{{rangeGreaterOrEqual, complete(v, v.Prerelease)}, {rangeLess, bump(v, v.Precision)}}
+	}
+}
+
+// tildeRange allows patch updates, or minor updates when only the major version is given
+func tildeRange(v TagVersion) []comparator {
+	if v.Precision == 0 {
+		return []comparator{}
+	}
+
+	precision := v.Precision
+	if precision > 2 {
+		precision = 2
+	}
+	return []comparator{{rangeGreaterOrEqual, complete(v, v.Prerelease)}, {rangeLess, bump(v, precision)}}
+}
+
+// caretRange allows updates that do not change the left-most non-zero component
+func caretRange(v TagVersion) []comparator {
+	if v.Precision == 0 {
+		return []comparator{}
+	}
+
+	precision := 3
+	switch {
+	case v.Major > 0 || v.Precision == 1:
+		precision = 1
+	case v.Minor > 0 || v.Precision == 2:
+		precision = 2
+	}
+	return []comparator{{rangeGreaterOrEqual, complete(v, v.Prerelease)}, {rangeLess, bump(v, precision)}}
+}
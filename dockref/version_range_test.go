@@ -0,0 +1,111 @@
+package dockref
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVersionRange_Contains(t *testing.T) {
+	type expectation struct {
+		contained    []string
+		notContained []string
+	}
+
+	expectations := map[string]expectation{
+		"<1.12 || >=2.0.0-0": {
+			[]string{"1.11", "1.11.9-alpine", "v1.2", "2.0.0-rc.1", "2.0.0", "3"},
+			[]string{"1.12", "1.12.0-rc1", "1.12.1", "1.99.0"},
+		},
+		">=1.12 <2": {
+			[]string{"1.12", "1.12.0", "1.99.1-alpine"},
+			[]string{"1.11.9", "1.12.0-rc1", "2.0.0-rc.1", "2.0"},
+		},
+		">= 1.12": {
+			[]string{"1.12", "2"},
+			[]string{"1.11"},
+		},
+		"1.12": {
+			[]string{"1.12", "1.12.5"},
+			[]string{"1.11.9", "1.13.0"},
+		},
+		"=1.12.3": {
+			[]string{"1.12.3", "v1.12.3-alpine"},
+			[]string{"1.12.4", "1.12"},
+		},
+		"1.x": {
+			[]string{"1.0", "1.99.99"},
+			[]string{"0.9", "2.0.0"},
+		},
+		"*": {
+			[]string{"0.0.1", "1.0.0-rc1", "99"},
+			[]string{},
+		},
+		">1.2": {
+			[]string{"1.3.0", "2"},
+			[]string{"1.2.9", "1.3.0-rc1"},
+		},
+		"<=1.2": {
+			[]string{"1.2.9", "1.0"},
+			[]string{"1.3.0-rc1", "1.3"},
+		},
+		"~1.2.3": {
+			[]string{"1.2.3", "1.2.9"},
+			[]string{"1.2.2", "1.3.0"},
+		},
+		"~1": {
+			[]string{"1.0.0", "1.9"},
+			[]string{"2.0.0"},
+		},
+		"^1.2.3": {
+			[]string{"1.2.3", "1.9.0"},
+			[]string{"1.2.2", "2.0.0"},
+		},
+		"^0.2.3": {
+			[]string{"0.2.3", "0.2.9"},
+			[]string{"0.3.0"},
+		},
+		"^0.0.3": {
+			[]string{"0.0.3"},
+			[]string{"0.0.4"},
+		},
+		"1.2 - 1.4": {
+			[]string{"1.2.0", "1.4.9"},
+			[]string{"1.1.9", "1.5.0"},
+		},
+		"~2.0.0-rc.1": {
+			[]string{"2.0.0-rc.1", "2.0.0-rc.2", "2.0.1"},
+			[]string{"2.0.0-beta.1", "2.1.0"},
+		},
+	}
+
+	for text, e := range expectations {
+		t.Run(text, func(t *testing.T) {
+			versionRange, err := ParseVersionRange(text)
+			assert.Nil(t, err)
+			assert.Equal(t, text, versionRange.String())
+
+			for _, tag := range e.contained {
+				assert.True(t, versionRange.Contains(mustParseTagVersion(tag)), "%s should contain %s", text, tag)
+			}
+			for _, tag := range e.notContained {
+				assert.False(t, versionRange.Contains(mustParseTagVersion(tag)), "%s should not contain %s", text, tag)
+			}
+		})
+	}
+}
+
+func TestParseVersionRangeRejectsInvalidRanges(t *testing.T) {
+	expectations := map[string]string{
+		"<1.12 || latest": "Invalid version range '<1.12 || latest': 'latest' is not a version",
+		">=":              "Invalid version range '>=': missing version after '>='",
+		"<=1.2-rc1":       "Invalid version range '<=1.2-rc1': '1.2-rc1' has a pre-release but not all components",
+		"1.2.3.4":         "Invalid version range '1.2.3.4': '1.2.3.4' is not a version",
+	}
+
+	for text, expected := range expectations {
+		t.Run(text, func(t *testing.T) {
+			_, err := ParseVersionRange(text)
+			assert.EqualError(t, err, expected)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package dockref
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseTagVersion(t *testing.T) {
+	expectations := map[string]TagVersion{
+		"1":                                 {Major: 1, Precision: 1},
+		"1.12":                              {Major: 1, Minor: 12, Precision: 2},
+		"v1.12":                             {Major: 1, Minor: 12, Precision: 2},
+		"1.15.3":                            {Major: 1, Minor: 15, Patch: 3, Precision: 3},
+		"1.15.3-alpine":                     {Major: 1, Minor: 15, Patch: 3, Precision: 3, Suffix: "alpine"},
+		"1.15.2-alpine-perl":                {Major: 1, Minor: 15, Patch: 2, Precision: 3, Suffix: "alpine-perl"},
+		"2.0.0-rc.1":                        {Major: 2, Precision: 3, Prerelease: "rc.1"},
+		"2.0.0-beta2-alpine":                {Major: 2, Precision: 3, Prerelease: "beta2", Suffix: "alpine"},
+		"3.4.16-windowsservercore-ltsc2016": {Major: 3, Minor: 4, Patch: 16, Precision: 3, Suffix: "windowsservercore-ltsc2016"},
+	}
+
+	for tag, expected := range expectations {
+		t.Run("Parses "+tag, func(t *testing.T) {
+			version, err := ParseTagVersion(tag)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, version)
+		})
+	}
+
+	for _, tag := range []string{"", "latest", "alpine", "1.2.3.4", "stable-1.2", "1.x"} {
+		t.Run("Rejects "+tag, func(t *testing.T) {
+			_, err := ParseTagVersion(tag)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func mustParseTagVersion(tag string) TagVersion {
+	version, err := ParseTagVersion(tag)
+	if err != nil {
+		panic(err)
+	}
+	return version
+}
+
+func TestTagVersion_Compare(t *testing.T) {
+	ascending := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11",
+		"1.0.0-rc1", "1.0.0-rc10", "1.0.0", "1.0.1", "1.1.0", "2.0.0"}
+
+	for i := 0; i < len(ascending); i++ {
+		for j := 0; j < len(ascending); j++ {
+			a := mustParseTagVersion(ascending[i])
+			b := mustParseTagVersion(ascending[j])
+
+			expected := compareUint(uint64(i), uint64(j))
+			assert.Equal(t, expected, a.Compare(b), "comparing %s and %s", ascending[i], ascending[j])
+		}
+	}
+}
+
+func TestTagVersion_IsOlderThan(t *testing.T) {
+	older := map[string][]string{
+		"1.0.0":       {"1.0.1", "1.1.0", "2.0.0", "v2"},
+		"1.12":        {"1.13", "1.13.1", "2.0"},
+		"1":           {"2", "2.0.0"},
+		"1.15-alpine": {"1.16-alpine", "1.16.1-alpine"},
+		"2.0.0-rc1":   {"2.0.0", "2.0.0-rc2"},
+	}
+	for current, candidates := range older {
+		for _, candidate := range candidates {
+			t.Run(current+" is older than "+candidate, func(t *testing.T) {
+				assert.True(t, mustParseTagVersion(current).IsOlderThan(mustParseTagVersion(candidate)))
+			})
+		}
+	}
+
+	notOlder := map[string][]string{
+		"1.0.0":       {"1.0.0", "0.9.0", "1.0.1-alpine", "1.1.0-rc1"},
+		"1.12":        {"1.12.1", "1.12", "1.11.9"},
+		"1":           {"1.9.9"},
+		"1.15-alpine": {"1.16", "1.16-perl"},
+		"2.0.0":       {"2.0.0-rc2"},
+	}
+	for current, candidates := range notOlder {
+		for _, candidate := range candidates {
+			t.Run(current+" is not older than "+candidate, func(t *testing.T) {
+				assert.False(t, mustParseTagVersion(current).IsOlderThan(mustParseTagVersion(candidate)))
+			})
+		}
+	}
+}
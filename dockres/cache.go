@@ -0,0 +1,197 @@
+package dockres
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheMode controls whether a cached resolver looks up digests and tags or serves them from the cache
+type CacheMode int
+
+const (
+	// CacheUse serves entries younger than the ttl from the cache, others are looked up and cached
+	CacheUse CacheMode = iota
+	// CacheOffline serves all entries from the cache regardless of their age and never looks up
+	CacheOffline
+	// CacheRefresh always looks up and caches the results
+	CacheRefresh
+)
+
+const (
+	cacheKindDigests = "digests"
+	cacheKindTags    = "tags"
+)
+
+var _ Resolver = (*cachedResolver)(nil)
+
+// cachedResolver stores the digests and tag lists of another resolver on disk. Each entry is a file that is
+// replaced atomically, so concurrent processes sharing the cache directory never see partially written entries
+type cachedResolver struct {
+	resolver Resolver
+	dir      string
+	ttl      time.Duration
+	mode     CacheMode
+	now      func() time.Time
+}
+
+type cacheEntry struct {
+	Key    string        `json:"key"`
+	Stored time.Time     `json:"stored"`
+	Digest digest.Digest `json:"digest,omitempty"`
+	Tags   []string      `json:"tags,omitempty"`
+}
+
+// CachedResolverNew creates a Resolver that caches the digests and tag lists of resolver in dir
+func CachedResolverNew(resolver Resolver, dir string, ttl time.Duration, mode CacheMode) Resolver {
+	return &cachedResolver{
+		resolver: resolver,
+		dir:      dir,
+		ttl:      ttl,
+		mode:     mode,
+		now:      time.Now,
+	}
+}
+
+// CacheDirectory returns the directory for cached data of dockmoor, following the XDG Base Directory
+// Specification: $XDG_CACHE_HOME/dockmoor or ~/.cache/dockmoor
+func CacheDirectory() (string, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return "", errors.New("Could not determine cache directory: neither XDG_CACHE_HOME nor HOME are set")
+		}
+		base = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(base, "dockmoor"), nil
+}
+
+func (cache *cachedResolver) ResolveDigest(ref dockref.Reference) (digest.Digest, error) {
+	if ref.Named() == nil {
+		return "", errors.Errorf("Cannot resolve '%s': reference has no name", ref.Original())
+	}
+
+	if ref.Tag() == "" && ref.DigestString() != "" {
+		// pinned without a tag, there is nothing to resolve
+		return ref.Digest(), nil
+	}
+
+	tag := ref.Tag()
+	if tag == "" {
+		tag = "latest"
+	}
+	key := ref.Name() + ":" + tag
+
+	if entry, ok := cache.load(cacheKindDigests, key); ok {
+		return entry.Digest, nil
+	}
+	if cache.mode == CacheOffline {
+		return "", errors.Errorf("Cannot resolve '%s' offline: digest of %s is not cached", ref.Original(), key)
+	}
+
+	dig, err := cache.resolver.ResolveDigest(ref)
+	if err != nil {
+		return "", err
+	}
+
+	cache.store(cacheKindDigests, cacheEntry{Key: key, Digest: dig})
+	return dig, nil
+}
+
+func (cache *cachedResolver) ListTags(ref dockref.Reference) ([]string, error) {
+	if ref.Named() == nil {
+		return nil, errors.Errorf("Cannot list tags of '%s': reference has no name", ref.Original())
+	}
+
+	key := ref.Name()
+
+	if entry, ok := cache.load(cacheKindTags, key); ok {
+		return entry.Tags, nil
+	}
+	if cache.mode == CacheOffline {
+		return nil, errors.Errorf("Cannot list tags of '%s' offline: tags of %s are not cached", ref.Original(), key)
+	}
+
+	tags, err := cache.resolver.ListTags(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.store(cacheKindTags, cacheEntry{Key: key, Tags: tags})
+	return tags, nil
+}
+
+// FetchManifest is not cached, manifests are only fetched to inspect them
+func (cache *cachedResolver) FetchManifest(ref dockref.Reference) (Manifest, error) {
+	if cache.mode == CacheOffline {
+		return Manifest{}, errors.Errorf("Cannot fetch manifest of '%s' offline", ref.Original())
+	}
+	return cache.resolver.FetchManifest(ref)
+}
+
+func (cache *cachedResolver) file(kind string, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(cache.dir, kind, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the cached entry unless it is missing, invalid or expired. Offline, entries never expire
+func (cache *cachedResolver) load(kind string, key string) (cacheEntry, bool) {
+	if cache.mode == CacheRefresh {
+		return cacheEntry{}, false
+	}
+
+	content, err := ioutil.ReadFile(cache.file(kind, key))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil || entry.Key != key {
+		return cacheEntry{}, false
+	}
+
+	if cache.mode != CacheOffline && cache.now().Sub(entry.Stored) > cache.ttl {
+		return cacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+// store writes the entry to a temporary file and renames it, so readers see either the old or the new entry.
+// The cache is only an optimization, entries that cannot be stored are looked up again next time
+func (cache *cachedResolver) store(kind string, entry cacheEntry) {
+	entry.Stored = cache.now()
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	file := cache.file(kind, entry.Key)
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(content)
+	errClose := tmp.Close()
+	if err != nil || errClose != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
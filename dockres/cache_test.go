@@ -0,0 +1,186 @@
+package dockres
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingResolver struct {
+	mutex   sync.Mutex
+	lookups int
+	digest  digest.Digest
+	tags    []string
+}
+
+func (resolver *countingResolver) count() {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+	resolver.lookups++
+}
+
+func (resolver *countingResolver) ResolveDigest(ref dockref.Reference) (digest.Digest, error) {
+	resolver.count()
+	return resolver.digest, nil
+}
+
+func (resolver *countingResolver) ListTags(ref dockref.Reference) ([]string, error) {
+	resolver.count()
+	return resolver.tags, nil
+}
+
+func (resolver *countingResolver) FetchManifest(ref dockref.Reference) (Manifest, error) {
+	resolver.count()
+	return Manifest{}, errors.New("not implemented")
+}
+
+func cachedResolverTestNew(t *testing.T, mode CacheMode) (*cachedResolver, *countingResolver, func()) {
+	dir, err := ioutil.TempDir("", "dockmoor")
+	assert.Nil(t, err)
+
+	counting := &countingResolver{digest: digest.FromString("1.0"), tags: []string{"1.0", "1.1"}}
+	cache := CachedResolverNew(counting, dir, time.Hour, mode).(*cachedResolver)
+	return cache, counting, func() { os.RemoveAll(dir) }
+}
+
+func mustRef(t *testing.T, original string) dockref.Reference {
+	ref, err := dockref.FromOriginal(original)
+	assert.Nil(t, err)
+	return ref
+}
+
+func TestCachedResolver_CachesDigestsAndTags(t *testing.T) {
+	cache, counting, cleanup := cachedResolverTestNew(t, CacheUse)
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		dig, err := cache.ResolveDigest(mustRef(t, "nginx:1.0"))
+		assert.Nil(t, err)
+		assert.Equal(t, counting.digest, dig)
+
+		tags, err := cache.ListTags(mustRef(t, "nginx"))
+		assert.Nil(t, err)
+		assert.Equal(t, counting.tags, tags)
+	}
+	assert.Equal(t, 2, counting.lookups)
+
+	// keys are normalized
+	_, err := cache.ResolveDigest(mustRef(t, "docker.io/library/nginx:1.0"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, counting.lookups)
+
+	_, err = cache.ResolveDigest(mustRef(t, "nginx:1.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, counting.lookups)
+}
+
+func TestCachedResolver_ExpiresEntries(t *testing.T) {
+	cache, counting, cleanup := cachedResolverTestNew(t, CacheUse)
+	defer cleanup()
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	_, err := cache.ResolveDigest(mustRef(t, "nginx:1.0"))
+	assert.Nil(t, err)
+
+	now = now.Add(2 * time.Hour)
+	_, err = cache.ResolveDigest(mustRef(t, "nginx:1.0"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, counting.lookups)
+
+	offline := CachedResolverNew(counting, cache.dir, time.Hour, CacheOffline).(*cachedResolver)
+	offline.now = func() time.Time { return now.Add(24 * time.Hour) }
+	_, err = offline.ResolveDigest(mustRef(t, "nginx:1.0"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, counting.lookups)
+}
+
+func TestCachedResolver_Offline(t *testing.T) {
+	cache, counting, cleanup := cachedResolverTestNew(t, CacheOffline)
+	defer cleanup()
+
+	_, err := cache.ResolveDigest(mustRef(t, "nginx:1.0"))
+	assert.EqualError(t, err, "Cannot resolve 'nginx:1.0' offline: digest of docker.io/library/nginx:1.0 is not cached")
+
+	_, err = cache.ListTags(mustRef(t, "nginx"))
+	assert.EqualError(t, err, "Cannot list tags of 'nginx' offline: tags of docker.io/library/nginx are not cached")
+
+	_, err = cache.FetchManifest(mustRef(t, "nginx"))
+	assert.Error(t, err)
+
+	pinned := mustRef(t, "nginx@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf")
+	dig, err := cache.ResolveDigest(pinned)
+	assert.Nil(t, err)
+	assert.Equal(t, pinned.Digest(), dig)
+
+	assert.Equal(t, 0, counting.lookups)
+}
+
+func TestCachedResolver_Refresh(t *testing.T) {
+	cache, counting, cleanup := cachedResolverTestNew(t, CacheUse)
+	defer cleanup()
+
+	_, err := cache.ResolveDigest(mustRef(t, "nginx:1.0"))
+	assert.Nil(t, err)
+
+	refresh := CachedResolverNew(counting, cache.dir, time.Hour, CacheRefresh)
+	counting.digest = digest.FromString("1.0 rebuilt")
+	dig, err := refresh.ResolveDigest(mustRef(t, "nginx:1.0"))
+	assert.Nil(t, err)
+	assert.Equal(t, counting.digest, dig)
+	assert.Equal(t, 2, counting.lookups)
+
+	// the refreshed digest is cached
+	dig, err = cache.ResolveDigest(mustRef(t, "nginx:1.0"))
+	assert.Nil(t, err)
+	assert.Equal(t, counting.digest, dig)
+	assert.Equal(t, 2, counting.lookups)
+}
+
+func TestCachedResolver_ConcurrentProcesses(t *testing.T) {
+	cache, counting, cleanup := cachedResolverTestNew(t, CacheRefresh)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// separate resolvers share the directory like separate processes
+			writer := CachedResolverNew(counting, cache.dir, time.Hour, CacheRefresh)
+			reader := CachedResolverNew(counting, cache.dir, time.Hour, CacheUse)
+			for j := 0; j < 20; j++ {
+				_, err := writer.ListTags(mustRef(t, "nginx"))
+				assert.Nil(t, err)
+				tags, err := reader.ListTags(mustRef(t, "nginx"))
+				assert.Nil(t, err)
+				assert.Equal(t, []string{"1.0", "1.1"}, tags)
+			}
+		}()
+	}
+	wg.Wait()
+
+	files, err := ioutil.ReadDir(cache.dir + "/" + cacheKindTags)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1, "temporary files must be removed")
+}
+
+func TestCacheDirectory(t *testing.T) {
+	defer withEnv(t, "XDG_CACHE_HOME", "/xdg/cache")()
+	dir, err := CacheDirectory()
+	assert.Nil(t, err)
+	assert.Equal(t, "/xdg/cache/dockmoor", dir)
+
+	os.Unsetenv("XDG_CACHE_HOME")
+	defer withEnv(t, "HOME", "/home/user")()
+	dir, err = CacheDirectory()
+	assert.Nil(t, err)
+	assert.Equal(t, "/home/user/.cache/dockmoor", dir)
+}
@@ -0,0 +1,214 @@
+package dockres
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const dockerConfigFileName = "config.json"
+
+// dockerHubServerAddress is the key of Docker Hub in the auths of config.json and for credential helpers
+const dockerHubServerAddress = "https://index.docker.io/v1/"
+
+// identityTokenUsername is reported by credential helpers as username when the secret is an identity token
+const identityTokenUsername = "<token>"
+
+// Credentials authenticate at a registry, either with username and password or with an identity token
+type Credentials struct {
+	Username      string
+	Password      string
+	IdentityToken string
+}
+
+// IsEmpty reports whether there are no credentials
+func (c Credentials) IsEmpty() bool {
+	return c.Username == "" && c.Password == "" && c.IdentityToken == ""
+}
+
+// CredentialStore looks up the credentials of registries
+type CredentialStore interface {
+	// Credentials returns the credentials for the registry host, or empty Credentials if there are none
+	Credentials(host string) (Credentials, error)
+}
+
+var _ CredentialStore = (*dockerConfigCredentialStore)(nil)
+
+// dockerConfig is the part of the docker cli configuration that describes credentials
+type dockerConfig struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+type dockerConfigCredentialStore struct {
+	config dockerConfig
+
+	cacheMutex sync.Mutex
+	cache      map[string]Credentials
+}
+
+// DockerConfigCredentialStoreNew creates a CredentialStore that uses the credentials of the docker cli. They
+// are read from config.json in the directory given by DOCKER_CONFIG, or ~/.docker, just like the docker cli
+// does. Credentials in credHelpers and credsStore are requested from the docker-credential-* executables.
+func DockerConfigCredentialStoreNew() (CredentialStore, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return dockerConfigCredentialStoreNew(dockerConfig{}), nil
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	configFile := filepath.Join(dir, dockerConfigFileName)
+	content, err := ioutil.ReadFile(filepath.Clean(configFile))
+	if os.IsNotExist(err) {
+		return dockerConfigCredentialStoreNew(dockerConfig{}), nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not read docker configuration %s", configFile)
+	}
+
+	var config dockerConfig
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid docker configuration %s", configFile)
+	}
+
+	return dockerConfigCredentialStoreNew(config), nil
+}
+
+func dockerConfigCredentialStoreNew(config dockerConfig) *dockerConfigCredentialStore {
+	return &dockerConfigCredentialStore{
+		config: config,
+		cache:  make(map[string]Credentials),
+	}
+}
+
+func (store *dockerConfigCredentialStore) Credentials(host string) (Credentials, error) {
+	store.cacheMutex.Lock()
+	defer store.cacheMutex.Unlock()
+
+	if credentials, ok := store.cache[host]; ok {
+		return credentials, nil
+	}
+
+	credentials, err := store.lookup(host)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	store.cache[host] = credentials
+	return credentials, nil
+}
+
+func (store *dockerConfigCredentialStore) lookup(host string) (Credentials, error) {
+	serverAddress := host
+	if host == dockerHubRegistry || host == dockerHubDomain {
+		serverAddress = dockerHubServerAddress
+	}
+
+	helper := store.config.CredHelpers[host]
+	if helper == "" {
+		helper = store.config.CredsStore
+	}
+	if helper != "" {
+		credentials, err := helperCredentials(helper, serverAddress)
+		if err != nil || !credentials.IsEmpty() {
+			return credentials, err
+		}
+	}
+
+	for key, auth := range store.config.Auths {
+		if authHost(key) == authHost(serverAddress) {
+			return auth.credentials(key)
+		}
+	}
+
+	return Credentials{}, nil
+}
+
+// authHost returns the host of a key of the auths, which may be a host or an url like https://index.docker.io/v1/
+func authHost(key string) string {
+	key = strings.TrimPrefix(key, "https://")
+	key = strings.TrimPrefix(key, "http://")
+	if i := strings.IndexByte(key, '/'); i >= 0 {
+		key = key[:i]
+	}
+	return key
+}
+
+func (auth dockerConfigAuth) credentials(key string) (Credentials, error) {
+	credentials := Credentials{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		IdentityToken: auth.IdentityToken,
+	}
+
+	if auth.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return Credentials{}, errors.Wrapf(err, "Invalid auth of '%s' in docker configuration", key)
+		}
+
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return Credentials{}, errors.Errorf("Invalid auth of '%s' in docker configuration: expected username:password", key)
+		}
+		credentials.Username, credentials.Password = parts[0], parts[1]
+	}
+
+	return credentials, nil
+}
+
+// helperCredentials requests the credentials of the server from a credential helper. The helper reads the
+// server address from stdin and writes the credentials as JSON to stdout
+func helperCredentials(helper string, serverAddress string) (Credentials, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverAddress)
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if err != nil {
+		output := strings.TrimSpace(stdout.String() + " " + stderr.String())
+		// helpers report unknown servers with exit code 1 and this message
+		if strings.Contains(output, "credentials not found") {
+			return Credentials{}, nil
+		}
+		return Credentials{}, errors.Errorf("Credential helper docker-credential-%s failed for '%s': %s %s",
+			helper, serverAddress, err.Error(), output)
+	}
+
+	var response struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	err = json.Unmarshal(stdout.Bytes(), &response)
+	if err != nil {
+		return Credentials{}, errors.Wrapf(err, "Invalid response of credential helper docker-credential-%s", helper)
+	}
+
+	if response.Username == identityTokenUsername {
+		return Credentials{IdentityToken: response.Secret}, nil
+	}
+
+	return Credentials{Username: response.Username, Password: response.Secret}, nil
+}
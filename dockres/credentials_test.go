@@ -0,0 +1,127 @@
+package dockres
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeHelper is a credential helper that knows the credentials of registry.example.com and Docker Hub
+const fakeHelper = `#!/bin/sh
+read server
+case "$server" in
+  registry.example.com) echo '{"ServerURL": "registry.example.com", "Username": "helper", "Secret": "helper-secret"}' ;;
+  https://index.docker.io/v1/) echo '{"ServerURL": "https://index.docker.io/v1/", "Username": "<token>", "Secret": "identity"}' ;;
+  broken.example.com) echo 'keychain locked' >&2; exit 2 ;;
+  *) echo 'credentials not found in native keychain'; exit 1 ;;
+esac
+`
+
+func credentialsTestDir(t *testing.T, config string) string {
+	dir, err := ioutil.TempDir("", "dockmoor")
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, dockerConfigFileName), []byte(config), 0600))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "docker-credential-fake"), []byte(fakeHelper), 0700))
+	return dir
+}
+
+func withEnv(t *testing.T, key string, value string) func() {
+	org, ok := os.LookupEnv(key)
+	assert.Nil(t, os.Setenv(key, value))
+	return func() {
+		if ok {
+			os.Setenv(key, org)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestDockerConfigCredentialStore_Auths(t *testing.T) {
+	dir := credentialsTestDir(t, `{
+  "auths": {
+    "https://index.docker.io/v1/": {"auth": "aHViOmh1Yi1zZWNyZXQ="},
+    "registry.example.com": {"username": "user", "password": "secret"},
+    "token.example.com": {"identitytoken": "identity"},
+    "broken.example.com": {"auth": "bm8tY29sb24="}
+  }
+}`)
+	defer os.RemoveAll(dir)
+	defer withEnv(t, "DOCKER_CONFIG", dir)()
+
+	store, err := DockerConfigCredentialStoreNew()
+	assert.Nil(t, err)
+
+	expectations := map[string]Credentials{
+		"registry-1.docker.io": {Username: "hub", Password: "hub-secret"},
+		"registry.example.com": {Username: "user", Password: "secret"},
+		"token.example.com":    {IdentityToken: "identity"},
+		"unknown.example.com":  {},
+	}
+	for host, expected := range expectations {
+		credentials, err := store.Credentials(host)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, credentials, host)
+	}
+
+	_, err = store.Credentials("broken.example.com")
+	assert.EqualError(t, err, "Invalid auth of 'broken.example.com' in docker configuration: expected username:password")
+}
+
+func TestDockerConfigCredentialStore_Helpers(t *testing.T) {
+	dir := credentialsTestDir(t, `{
+  "auths": {
+    "fallback.example.com": {"auth": "dXNlcjpzZWNyZXQ="},
+    "other.example.com": {"auth": "dXNlcjpzZWNyZXQ="}
+  },
+  "credsStore": "fake",
+  "credHelpers": {"other.example.com": "missing"}
+}`)
+	defer os.RemoveAll(dir)
+	defer withEnv(t, "DOCKER_CONFIG", dir)()
+	defer withEnv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))()
+
+	store, err := DockerConfigCredentialStoreNew()
+	assert.Nil(t, err)
+
+	expectations := map[string]Credentials{
+		"registry.example.com": {Username: "helper", Password: "helper-secret"},
+		"registry-1.docker.io": {IdentityToken: "identity"},
+		"fallback.example.com": {Username: "user", Password: "secret"},
+		"unknown.example.com":  {},
+	}
+	for host, expected := range expectations {
+		credentials, err := store.Credentials(host)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, credentials, host)
+	}
+
+	_, err = store.Credentials("broken.example.com")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Credential helper docker-credential-fake failed for 'broken.example.com'")
+	assert.Contains(t, err.Error(), "keychain locked")
+
+	_, err = store.Credentials("other.example.com")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Credential helper docker-credential-missing failed")
+}
+
+func TestDockerConfigCredentialStore_WithoutConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+	defer withEnv(t, "DOCKER_CONFIG", dir)()
+
+	store, err := DockerConfigCredentialStoreNew()
+	assert.Nil(t, err)
+
+	credentials, err := store.Credentials("registry.example.com")
+	assert.Nil(t, err)
+	assert.True(t, credentials.IsEmpty())
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, dockerConfigFileName), []byte("{"), 0600))
+	_, err = DockerConfigCredentialStoreNew()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid docker configuration")
+}
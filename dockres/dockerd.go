@@ -0,0 +1,158 @@
+package dockres
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+var _ Resolver = (*dockerdResolver)(nil)
+
+type dockerdResolver struct {
+	client  *http.Client
+	baseURL string
+}
+
+// DockerdResolverNew creates a Resolver that uses the images known to the local docker daemon.
+// The daemon is located using the DOCKER_HOST environment variable, just like the docker cli does.
+func DockerdResolverNew() (Resolver, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = defaultDockerHost
+	}
+
+	hostURL, err := url.Parse(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid DOCKER_HOST '%s'", host)
+	}
+
+	switch hostURL.Scheme {
+	case "unix":
+		socket := hostURL.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		return dockerdResolverNew(&http.Client{Transport: transport}, "http://docker"), nil
+	case "tcp", "http":
+		return dockerdResolverNew(http.DefaultClient, "http://"+hostURL.Host), nil
+	default:
+		return nil, errors.Errorf("Unsupported DOCKER_HOST '%s'", host)
+	}
+}
+
+func dockerdResolverNew(client *http.Client, baseURL string) *dockerdResolver {
+	return &dockerdResolver{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+type dockerdImageInspect struct {
+	RepoDigests []string
+}
+
+type dockerdImageSummary struct {
+	RepoTags []string
+}
+
+func (resolver *dockerdResolver) ResolveDigest(ref dockref.Reference) (digest.Digest, error) {
+	named := ref.Named()
+	if named == nil {
+		return "", errors.Errorf("Cannot resolve '%s': reference has no name", ref.Original())
+	}
+
+	if ref.Tag() == "" && ref.DigestString() != "" {
+		// pinned without a tag, there is nothing to resolve
+		return ref.Digest(), nil
+	}
+
+	tag := ref.Tag()
+	if tag == "" {
+		tag = "latest"
+	}
+	image := reference.FamiliarName(named) + ":" + tag
+
+	var inspect dockerdImageInspect
+	err := resolver.getJSON("/images/"+image+"/json", &inspect)
+	if err != nil {
+		return "", errors.Wrapf(err, "Could not inspect image '%s'", image)
+	}
+
+	for _, repoDigest := range inspect.RepoDigests {
+		repoRef, err := reference.ParseNormalizedNamed(repoDigest)
+		if err != nil {
+			continue
+		}
+
+		digested, ok := repoRef.(reference.Digested)
+		if ok && repoRef.Name() == named.Name() {
+			return digested.Digest(), nil
+		}
+	}
+
+	return "", errors.Errorf("Image '%s' has no digest for repository %s", image, named.Name())
+}
+
+func (resolver *dockerdResolver) ListTags(ref dockref.Reference) ([]string, error) {
+	named := ref.Named()
+	if named == nil {
+		return nil, errors.Errorf("Cannot list tags of '%s': reference has no name", ref.Original())
+	}
+
+	var images []dockerdImageSummary
+	err := resolver.getJSON("/images/json", &images)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not list images")
+	}
+
+	tags := make([]string, 0)
+	for _, image := range images {
+		for _, repoTag := range image.RepoTags {
+			repoRef, err := reference.ParseNormalizedNamed(repoTag)
+			if err != nil {
+				continue
+			}
+
+			tagged, ok := repoRef.(reference.Tagged)
+			if ok && repoRef.Name() == named.Name() {
+				tags = append(tags, tagged.Tag())
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+func (resolver *dockerdResolver) FetchManifest(ref dockref.Reference) (Manifest, error) {
+	return Manifest{}, errors.Errorf("Cannot fetch manifest of '%s': not supported by the docker daemon", ref.Original())
+}
+
+func (resolver *dockerdResolver) getJSON(path string, v interface{}) error {
+	response, err := resolver.client.Get(resolver.baseURL + path)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return errors.New("not known to the docker daemon")
+	}
+	if response.StatusCode != http.StatusOK {
+		return errors.New(response.Status)
+	}
+
+	return json.NewDecoder(response.Body).Decode(v)
+}
@@ -0,0 +1,133 @@
+package dockres
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const nginxDigest = "sha256:db5acc22920799fe387a903437eb89387607e5b3f63cf0f4472ac182d7bad644"
+const otherDigest = "sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"
+
+func fakeDockerd() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/images/nginx:1.15/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"RepoDigests": ["example.com/nginx@` + otherDigest + `", "nginx@` + nginxDigest + `"]}`))
+	})
+	mux.HandleFunc("/images/nginx:latest/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"RepoDigests": ["nginx@` + otherDigest + `"]}`))
+	})
+	mux.HandleFunc("/images/local:latest/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"RepoDigests": []}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestDockerdResolver_ResolveDigest(t *testing.T) {
+	server := fakeDockerd()
+	defer server.Close()
+
+	resolver := dockerdResolverNew(server.Client(), server.URL)
+
+	t.Run("Uses digest of matching repository", func(t *testing.T) {
+		ref, _ := dockref.FromOriginal("nginx:1.15")
+		dig, err := resolver.ResolveDigest(ref)
+		assert.Nil(t, err)
+		assert.Equal(t, digest.Digest(nginxDigest), dig)
+	})
+
+	t.Run("Untagged references resolve latest", func(t *testing.T) {
+		ref, _ := dockref.FromOriginal("nginx")
+		dig, err := resolver.ResolveDigest(ref)
+		assert.Nil(t, err)
+		assert.Equal(t, digest.Digest(otherDigest), dig)
+	})
+
+	t.Run("Digest-only references resolve their digest", func(t *testing.T) {
+		ref, _ := dockref.FromOriginal("unknown@" + nginxDigest)
+		dig, err := resolver.ResolveDigest(ref)
+		assert.Nil(t, err)
+		assert.Equal(t, digest.Digest(nginxDigest), dig)
+	})
+
+	t.Run("Fails for unknown images", func(t *testing.T) {
+		ref, _ := dockref.FromOriginal("unknown:1.0")
+		_, err := resolver.ResolveDigest(ref)
+		assert.Error(t, err)
+	})
+
+	t.Run("Fails for images without digest", func(t *testing.T) {
+		ref, _ := dockref.FromOriginal("local")
+		_, err := resolver.ResolveDigest(ref)
+		assert.Error(t, err)
+	})
+
+	t.Run("Fails for unnamed references", func(t *testing.T) {
+		ref, _ := dockref.FromOriginal("d21b79794850b4b15d8d332b451d95351d14c951542942a816eea69c9e04b240")
+		_, err := resolver.ResolveDigest(ref)
+		assert.Error(t, err)
+	})
+}
+
+func TestPin(t *testing.T) {
+	server := fakeDockerd()
+	defer server.Close()
+
+	resolver := dockerdResolverNew(server.Client(), server.URL)
+
+	ref, _ := dockref.FromOriginal("nginx:1.15")
+	pinned, err := Pin(resolver, ref)
+	assert.Nil(t, err)
+	assert.Equal(t, "nginx:1.15@"+nginxDigest, pinned.Original())
+}
+
+func TestDockerdResolverNew_DockerHost(t *testing.T) {
+	old := os.Getenv("DOCKER_HOST")
+	defer os.Setenv("DOCKER_HOST", old)
+
+	for _, host := range []string{"", "unix:///var/run/docker.sock", "tcp://127.0.0.1:2375"} {
+		t.Run("Accepts '"+host+"'", func(t *testing.T) {
+			os.Setenv("DOCKER_HOST", host)
+			resolver, err := DockerdResolverNew()
+			assert.Nil(t, err)
+			assert.NotNil(t, resolver)
+		})
+	}
+
+	t.Run("Rejects unknown schemes", func(t *testing.T) {
+		os.Setenv("DOCKER_HOST", "ssh://somewhere")
+		resolver, err := DockerdResolverNew()
+		assert.Nil(t, resolver)
+		assert.Error(t, err)
+	})
+}
+
+func TestDockerdResolver_ListTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[
+			{"RepoTags": ["nginx:1.15", "example.com/nginx:1.16"]},
+			{"RepoTags": ["nginx:latest", "alpine:3.8"]},
+			{"RepoTags": null}
+		]`))
+	}))
+	defer server.Close()
+
+	resolver := dockerdResolverNew(server.Client(), server.URL)
+
+	ref, _ := dockref.FromOriginal("nginx:1.15")
+	tags, err := resolver.ListTags(ref)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15", "latest"}, tags)
+}
+
+func TestDockerdResolver_FetchManifestIsNotSupported(t *testing.T) {
+	resolver := dockerdResolverNew(http.DefaultClient, "http://docker")
+
+	ref, _ := dockref.FromOriginal("nginx:1.15")
+	_, err := resolver.FetchManifest(ref)
+	assert.Error(t, err)
+}
@@ -0,0 +1,435 @@
+package dockres
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dockerHubDomain = "docker.io"
+const dockerHubRegistry = "registry-1.docker.io"
+
+var manifestAcceptHeader = strings.Join([]string{
+	MediaTypeDockerManifestList,
+	MediaTypeOCIIndex,
+	MediaTypeDockerManifest,
+	MediaTypeOCIManifest,
+}, ", ")
+
+var _ Resolver = (*registryResolver)(nil)
+
+type registryResolver struct {
+	client      *http.Client
+	scheme      string
+	credentials CredentialStore
+
+	authorizationsMutex sync.Mutex
+	authorizations      map[string]string
+}
+
+// RegistryResolverNew creates a Resolver that queries registries implementing the Docker Registry HTTP API v2.
+// Registries are authenticated with the credentials of the docker cli, requests fail after timeout.
+func RegistryResolverNew(timeout time.Duration) (Resolver, error) {
+	credentials, err := DockerConfigCredentialStoreNew()
+	if err != nil {
+		return nil, err
+	}
+	return registryResolverNew(&http.Client{Timeout: timeout}, credentials), nil
+}
+
+func registryResolverNew(client *http.Client, credentials CredentialStore) *registryResolver {
+	return &registryResolver{
+		client:         client,
+		scheme:         "https",
+		credentials:    credentials,
+		authorizations: make(map[string]string),
+	}
+}
+
+func (resolver *registryResolver) ResolveDigest(ref dockref.Reference) (digest.Digest, error) {
+	if ref.Named() == nil {
+		return "", errors.Errorf("Cannot resolve '%s': reference has no name", ref.Original())
+	}
+
+	if ref.Tag() == "" && ref.DigestString() != "" {
+		// pinned without a tag, there is nothing to resolve
+		return ref.Digest(), nil
+	}
+
+	response, err := resolver.requestManifest(http.MethodHead, ref)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	dig := digest.Digest(response.Header.Get("Docker-Content-Digest"))
+	if dig.Validate() == nil {
+		return dig, nil
+	}
+
+	// not all registries report the digest on HEAD requests
+	manifest, err := resolver.FetchManifest(ref)
+	if err != nil {
+		return "", err
+	}
+
+	return manifest.Digest, nil
+}
+
+func (resolver *registryResolver) FetchManifest(ref dockref.Reference) (Manifest, error) {
+	if ref.Named() == nil {
+		return Manifest{}, errors.Errorf("Cannot fetch manifest of '%s': reference has no name", ref.Original())
+	}
+
+	response, err := resolver.requestManifest(http.MethodGet, ref)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return Manifest{}, errors.Wrapf(err, "Could not read manifest of '%s'", ref.Original())
+	}
+
+	var body struct {
+		MediaType string               `json:"mediaType"`
+		Manifests []ManifestDescriptor `json:"manifests"`
+	}
+	err = json.Unmarshal(content, &body)
+	if err != nil {
+		return Manifest{}, errors.Wrapf(err, "Could not decode manifest of '%s'", ref.Original())
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if body.MediaType != "" {
+		mediaType = body.MediaType
+	}
+
+	manifest := Manifest{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(content),
+		Content:   content,
+	}
+
+	if manifest.IsList() {
+		manifest.Manifests = body.Manifests
+	}
+
+	return manifest, nil
+}
+
+func (resolver *registryResolver) ListTags(ref dockref.Reference) ([]string, error) {
+	if ref.Named() == nil {
+		return nil, errors.Errorf("Cannot list tags of '%s': reference has no name", ref.Original())
+	}
+
+	tags := make([]string, 0)
+	next := resolver.repositoryURL(ref, "tags/list")
+	for next != "" {
+		response, err := resolver.request(http.MethodGet, next, ref, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(response.Body).Decode(&body)
+		response.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not decode tags of '%s'", ref.Original())
+		}
+
+		tags = append(tags, body.Tags...)
+
+		next, err = nextLink(response)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+func (resolver *registryResolver) requestManifest(method string, ref dockref.Reference) (*http.Response, error) {
+	tagOrDigest := ref.Tag()
+	if tagOrDigest == "" {
+		tagOrDigest = ref.DigestString()
+	}
+	if tagOrDigest == "" {
+		tagOrDigest = "latest"
+	}
+
+	header := http.Header{}
+	header.Set("Accept", manifestAcceptHeader)
+
+	return resolver.request(method, resolver.repositoryURL(ref, "manifests/"+tagOrDigest), ref, header)
+}
+
+func (resolver *registryResolver) repositoryURL(ref dockref.Reference, suffix string) string {
+	return fmt.Sprintf("%s://%s/v2/%s/%s", resolver.scheme, registryHost(ref.Domain()), ref.Path(), suffix)
+}
+
+func registryHost(domain string) string {
+	if domain == dockerHubDomain {
+		return dockerHubRegistry
+	}
+	return domain
+}
+
+// request sends an authenticated request, answering basic and token challenges of the registry
+func (resolver *registryResolver) request(method string, requestURL string, ref dockref.Reference, header http.Header) (*http.Response, error) {
+	scope := "repository:" + ref.Path() + ":pull"
+	host := registryHost(ref.Domain())
+
+	response, err := resolver.send(method, requestURL, header, resolver.authorization(host, scope))
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusUnauthorized {
+		challenge := response.Header.Get("WWW-Authenticate")
+		response.Body.Close()
+
+		authorization, err := resolver.authorize(challenge, host, scope)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not authenticate for '%s'", ref.Original())
+		}
+		resolver.setAuthorization(host, scope, authorization)
+
+		response, err = resolver.send(method, requestURL, header, authorization)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, errors.Errorf("Request for '%s' failed: %s", ref.Original(), response.Status)
+	}
+
+	return response, nil
+}
+
+func (resolver *registryResolver) send(method string, requestURL string, header http.Header, authorization string) (*http.Response, error) {
+	request, err := http.NewRequest(method, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		request.Header[key] = values
+	}
+
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+
+	return resolver.client.Do(request)
+}
+
+func (resolver *registryResolver) authorization(host string, scope string) string {
+	resolver.authorizationsMutex.Lock()
+	defer resolver.authorizationsMutex.Unlock()
+	return resolver.authorizations[host+" "+scope]
+}
+
+func (resolver *registryResolver) setAuthorization(host string, scope string, authorization string) {
+	resolver.authorizationsMutex.Lock()
+	defer resolver.authorizationsMutex.Unlock()
+	resolver.authorizations[host+" "+scope] = authorization
+}
+
+// authorize answers the challenge of the registry and returns the value of the Authorization header
+func (resolver *registryResolver) authorize(challenge string, host string, scope string) (string, error) {
+	credentials := Credentials{}
+	if resolver.credentials != nil {
+		var err error
+		credentials, err = resolver.credentials.Credentials(host)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if credentials.Username == "" {
+			return "", errors.Errorf("No credentials for '%s' in docker configuration", host)
+		}
+		auth := base64.StdEncoding.EncodeToString([]byte(credentials.Username + ":" + credentials.Password))
+		return "Basic " + auth, nil
+	case "bearer":
+		token, err := resolver.fetchToken(challenge, params, scope, credentials)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", errors.Errorf("Unsupported authentication challenge '%s'", challenge)
+	}
+}
+
+// fetchToken requests a token from the realm of the challenge. With an identity token the token is requested
+// using OAuth2, otherwise with basic authentication, or anonymously when there are no credentials
+func (resolver *registryResolver) fetchToken(challenge string, params map[string]string, scope string, credentials Credentials) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.Errorf("Authentication challenge without realm: '%s'", challenge)
+	}
+
+	realmURL, err := url.Parse(realm)
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid realm '%s'", realm)
+	}
+
+	query := realmURL.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	if challengeScope, ok := params["scope"]; ok {
+		scope = challengeScope
+	}
+	query.Set("scope", scope)
+
+	var request *http.Request
+	if credentials.IdentityToken != "" {
+		query.Set("grant_type", "refresh_token")
+		query.Set("refresh_token", credentials.IdentityToken)
+		query.Set("client_id", "dockmoor")
+		request, err = http.NewRequest(http.MethodPost, realmURL.String(), strings.NewReader(query.Encode()))
+		if err != nil {
+			return "", err
+		}
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		realmURL.RawQuery = query.Encode()
+		request, err = http.NewRequest(http.MethodGet, realmURL.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if credentials.Username != "" {
+			request.SetBasicAuth(credentials.Username, credentials.Password)
+		}
+	}
+
+	response, err := resolver.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", errors.Errorf("Token request failed: %s", response.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		return "", errors.Wrap(err, "Could not decode token")
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+
+	return "", errors.New("Token response contains no token")
+}
+
+// parseChallenge splits a WWW-Authenticate header like
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+// into its scheme and parameters
+func parseChallenge(challenge string) (scheme string, params map[string]string) {
+	params = make(map[string]string)
+
+	challenge = strings.TrimSpace(challenge)
+	i := strings.IndexByte(challenge, ' ')
+	if i < 0 {
+		return challenge, params
+	}
+
+	scheme = challenge[:i]
+	rest := challenge[i+1:]
+
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, rest = readQuoted(rest[1:])
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		}
+
+		params[key] = value
+	}
+
+	return
+}
+
+func readQuoted(s string) (value string, rest string) {
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				builder.WriteByte(s[i])
+			}
+		case '"':
+			return builder.String(), s[i+1:]
+		default:
+			builder.WriteByte(s[i])
+		}
+	}
+	return builder.String(), ""
+}
+
+// nextLink returns the absolute url of the next page announced in the Link header, if any
+func nextLink(response *http.Response) (string, error) {
+	link := response.Header.Get("Link")
+	if link == "" {
+		return "", nil
+	}
+
+	start := strings.IndexByte(link, '<')
+	end := strings.IndexByte(link, '>')
+	if start < 0 || end < start || !strings.Contains(link[end:], `rel="next"`) {
+		return "", nil
+	}
+
+	next, err := response.Request.URL.Parse(link[start+1 : end])
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid Link header '%s'", link)
+	}
+
+	return next.String(), nil
+}
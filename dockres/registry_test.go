@@ -0,0 +1,311 @@
+package dockres
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const fakeToken = "s3cr3t"
+
+const manifestList = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+  "manifests": [
+    {
+      "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+      "digest": "sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf",
+      "size": 948,
+      "platform": {"architecture": "amd64", "os": "linux"}
+    },
+    {
+      "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+      "digest": "sha256:db5acc22920799fe387a903437eb89387607e5b3f63cf0f4472ac182d7bad644",
+      "size": 948,
+      "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}
+    }
+  ]
+}`
+
+const ociIndex = `{
+  "schemaVersion": 2,
+  "manifests": [
+    {
+      "mediaType": "application/vnd.oci.image.manifest.v1+json",
+      "digest": "sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf",
+      "size": 948,
+      "platform": {"architecture": "amd64", "os": "linux"}
+    }
+  ]
+}`
+
+const imageManifest = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+  "config": {
+    "mediaType": "application/vnd.docker.container.image.v1+json",
+    "size": 7023,
+    "digest": "sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"
+  },
+  "layers": []
+}`
+
+type fakeRegistry struct {
+	*httptest.Server
+	tokenRequests int
+}
+
+func fakeRegistryNew() *fakeRegistry {
+	registry := &fakeRegistry{}
+	mux := http.NewServeMux()
+
+	authorized := func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+fakeToken {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/token",service="fake registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			handler(w, r)
+		}
+	}
+
+	manifest := func(mediaType string, content string, withDigestHeader bool) http.HandlerFunc {
+		return authorized(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", mediaType)
+			if withDigestHeader {
+				w.Header().Set("Docker-Content-Digest", string(digest.FromString(content)))
+			}
+			if r.Method == http.MethodGet {
+				w.Write([]byte(content))
+			}
+		})
+	}
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		registry.tokenRequests++
+		if r.URL.Query().Get("service") != "fake registry" || r.URL.Query().Get("scope") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"token": "` + fakeToken + `"}`))
+	})
+	mux.HandleFunc("/v2/multiarch/manifests/1.0", manifest(MediaTypeDockerManifestList, manifestList, true))
+	mux.HandleFunc("/v2/oci/manifests/latest", manifest(MediaTypeOCIIndex, ociIndex, false))
+	mux.HandleFunc("/v2/single/manifests/1.0", manifest(MediaTypeDockerManifest+"; charset=utf-8", imageManifest, false))
+	mux.HandleFunc("/v2/multiarch/tags/list", authorized(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/multiarch/tags/list?n=2&last=1.1>; rel="next"`)
+			w.Write([]byte(`{"name": "multiarch", "tags": ["1.0", "1.1"]}`))
+			return
+		}
+		w.Write([]byte(`{"name": "multiarch", "tags": ["2.0"]}`))
+	}))
+
+	registry.Server = httptest.NewTLSServer(mux)
+	return registry
+}
+
+func (registry *fakeRegistry) ref(t *testing.T, repository string) dockref.Reference {
+	u, _ := url.Parse(registry.URL)
+	ref, err := dockref.FromOriginal(u.Host + "/" + repository)
+	assert.Nil(t, err)
+	return ref
+}
+
+func TestRegistryResolver_ResolveDigest(t *testing.T) {
+	registry := fakeRegistryNew()
+	defer registry.Close()
+
+	resolver := registryResolverNew(registry.Client(), nil)
+
+	t.Run("Uses Docker-Content-Digest header", func(t *testing.T) {
+		dig, err := resolver.ResolveDigest(registry.ref(t, "multiarch:1.0"))
+		assert.Nil(t, err)
+		assert.Equal(t, digest.FromString(manifestList), dig)
+	})
+
+	t.Run("Falls back to digest of content", func(t *testing.T) {
+		dig, err := resolver.ResolveDigest(registry.ref(t, "oci"))
+		assert.Nil(t, err)
+		assert.Equal(t, digest.FromString(ociIndex), dig)
+	})
+
+	t.Run("Keeps digest of untagged pinned references", func(t *testing.T) {
+		ref := registry.ref(t, "multiarch@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf")
+		dig, err := resolver.ResolveDigest(ref)
+		assert.Nil(t, err)
+		assert.Equal(t, ref.Digest(), dig)
+	})
+
+	t.Run("Fails for unknown images", func(t *testing.T) {
+		_, err := resolver.ResolveDigest(registry.ref(t, "unknown:1.0"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Reuses tokens", func(t *testing.T) {
+		before := registry.tokenRequests
+		_, err := resolver.ResolveDigest(registry.ref(t, "multiarch:1.0"))
+		assert.Nil(t, err)
+		assert.Equal(t, before, registry.tokenRequests)
+	})
+}
+
+func TestRegistryResolver_FetchManifest(t *testing.T) {
+	registry := fakeRegistryNew()
+	defer registry.Close()
+
+	resolver := registryResolverNew(registry.Client(), nil)
+
+	t.Run("Manifest list", func(t *testing.T) {
+		manifest, err := resolver.FetchManifest(registry.ref(t, "multiarch:1.0"))
+		assert.Nil(t, err)
+		assert.Equal(t, MediaTypeDockerManifestList, manifest.MediaType)
+		assert.True(t, manifest.IsList())
+		assert.Equal(t, digest.FromString(manifestList), manifest.Digest)
+		assert.Len(t, manifest.Manifests, 2)
+		assert.Equal(t, "arm", manifest.Manifests[1].Platform.Architecture)
+		assert.Equal(t, "v7", manifest.Manifests[1].Platform.Variant)
+	})
+
+	t.Run("OCI index without mediaType in body", func(t *testing.T) {
+		manifest, err := resolver.FetchManifest(registry.ref(t, "oci:latest"))
+		assert.Nil(t, err)
+		assert.Equal(t, MediaTypeOCIIndex, manifest.MediaType)
+		assert.True(t, manifest.IsList())
+		assert.Len(t, manifest.Manifests, 1)
+	})
+
+	t.Run("Image manifest", func(t *testing.T) {
+		manifest, err := resolver.FetchManifest(registry.ref(t, "single:1.0"))
+		assert.Nil(t, err)
+		assert.Equal(t, MediaTypeDockerManifest, manifest.MediaType)
+		assert.False(t, manifest.IsList())
+		assert.Equal(t, imageManifest, string(manifest.Content))
+	})
+}
+
+func TestRegistryResolver_ListTags(t *testing.T) {
+	registry := fakeRegistryNew()
+	defer registry.Close()
+
+	resolver := registryResolverNew(registry.Client(), nil)
+
+	tags, err := resolver.ListTags(registry.ref(t, "multiarch"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.0", "1.1", "2.0"}, tags)
+}
+
+func TestRegistryResolver_BasicChallengeWithoutCredentials(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="private"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	resolver := registryResolverNew(server.Client(), nil)
+	u, _ := url.Parse(server.URL)
+	ref, _ := dockref.FromOriginal(u.Host + "/private:1.0")
+
+	_, err := resolver.ResolveDigest(ref)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No credentials for '"+u.Host+"' in docker configuration")
+}
+
+func TestRegistryHost(t *testing.T) {
+	assert.Equal(t, "registry-1.docker.io", registryHost("docker.io"))
+	assert.Equal(t, "example.com:5000", registryHost("example.com:5000"))
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull,push"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull,push",
+	}, params)
+
+	scheme, params = parseChallenge(`Basic realm="a \"quoted\" realm"`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Equal(t, `a "quoted" realm`, params["realm"])
+}
+
+type staticCredentials map[string]Credentials
+
+func (credentials staticCredentials) Credentials(host string) (Credentials, error) {
+	return credentials[host], nil
+}
+
+func TestRegistryResolver_BasicChallenge(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="private"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", string(digest.FromString(imageManifest)))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	ref, _ := dockref.FromOriginal(u.Host + "/private:1.0")
+
+	resolver := registryResolverNew(server.Client(), staticCredentials{u.Host: {Username: "user", Password: "secret"}})
+	dig, err := resolver.ResolveDigest(ref)
+	assert.Nil(t, err)
+	assert.Equal(t, digest.FromString(imageManifest), dig)
+}
+
+func TestRegistryResolver_TokenRequestWithCredentials(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			username, password, _ := r.BasicAuth()
+			basic := r.Method == http.MethodGet && username == "user" && password == "secret"
+			oauth := r.Method == http.MethodPost && r.PostForm.Get("grant_type") == "refresh_token" &&
+				r.PostForm.Get("refresh_token") == "identity" && r.PostForm.Get("scope") == "repository:private:pull"
+			if !basic && !oauth {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token": "` + fakeToken + `"}`))
+		default:
+			if r.Header.Get("Authorization") != "Bearer "+fakeToken {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="private"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", string(digest.FromString(imageManifest)))
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	ref, _ := dockref.FromOriginal(u.Host + "/private:1.0")
+
+	expectations := map[string]Credentials{
+		"Basic authentication": {Username: "user", Password: "secret"},
+		"Identity token":       {IdentityToken: "identity"},
+	}
+	for name, credentials := range expectations {
+		t.Run(name, func(t *testing.T) {
+			resolver := registryResolverNew(server.Client(), staticCredentials{u.Host: credentials})
+			dig, err := resolver.ResolveDigest(ref)
+			assert.Nil(t, err)
+			assert.Equal(t, digest.FromString(imageManifest), dig)
+		})
+	}
+
+	t.Run("Wrong credentials", func(t *testing.T) {
+		resolver := registryResolverNew(server.Client(), staticCredentials{u.Host: {Username: "user", Password: "wrong"}})
+		_, err := resolver.ResolveDigest(ref)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,61 @@
+package dockres
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// Resolver looks up digests, tags and manifests of image references
+type Resolver interface {
+	// ResolveDigest returns the digest the reference currently points to
+	ResolveDigest(ref dockref.Reference) (digest.Digest, error)
+	// ListTags returns all tags known for the repository of the reference
+	ListTags(ref dockref.Reference) ([]string, error)
+	// FetchManifest returns the manifest the reference currently points to
+	FetchManifest(ref dockref.Reference) (Manifest, error)
+}
+
+// Manifest is an image manifest, manifest list or OCI index
+type Manifest struct {
+	MediaType string
+	Digest    digest.Digest
+	Content   []byte
+
+	// Manifests contains the platform specific manifests when the manifest is a list or index
+	Manifests []ManifestDescriptor
+}
+
+// IsList reports whether the manifest is a manifest list or OCI index
+func (m Manifest) IsList() bool {
+	return m.MediaType == MediaTypeDockerManifestList || m.MediaType == MediaTypeOCIIndex
+}
+
+type ManifestDescriptor struct {
+	MediaType string        `json:"mediaType"`
+	Digest    digest.Digest `json:"digest"`
+	Size      int64         `json:"size"`
+	Platform  *Platform     `json:"platform,omitempty"`
+}
+
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Pin returns a copy of ref that is pinned to the digest the resolver reports for it.
+func Pin(resolver Resolver, ref dockref.Reference) (dockref.Reference, error) {
+	dig, err := resolver.ResolveDigest(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return ref.WithDigest(dig)
+}